@@ -1,46 +1,124 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"net"
+	"net/url"
 	"os"
+	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"text/template"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 
+	"github.com/kubearmor/sidekick/outputs"
 	"github.com/kubearmor/sidekick/types"
 )
 
 func getConfig() *types.Configuration {
-	c := &types.Configuration{
-		Customfields:    make(map[string]string),
-		Templatedfields: make(map[string]string),
-		TLSServer:       types.TLSServer{NoTLSPaths: make([]string, 0)},
-		Grafana:         types.GrafanaOutputConfig{CustomHeaders: make(map[string]string)},
-		Loki:            types.LokiOutputConfig{CustomHeaders: make(map[string]string)},
-		Elasticsearch:   types.ElasticsearchOutputConfig{CustomHeaders: make(map[string]string)},
-		OpenObserve:     types.OpenObserveConfig{CustomHeaders: make(map[string]string)},
-		Webhook:         types.WebhookOutputConfig{CustomHeaders: make(map[string]string)},
-		Alertmanager:    types.AlertmanagerOutputConfig{ExtraLabels: make(map[string]string), ExtraAnnotations: make(map[string]string), CustomSeverityMap: make(map[types.PriorityType]string)},
-		CloudEvents:     types.CloudEventsOutputConfig{Extensions: make(map[string]string)},
-		GCP:             types.GcpOutputConfig{PubSub: types.GcpPubSub{CustomAttributes: make(map[string]string)}},
-	}
+	c := newConfiguration()
+
+	var configFile string
+	flag.StringVar(&configFile, "c", "", "config file")
+	flag.StringVar(&configFile, "config-file", "", "config file")
+	flag.StringVar(&redriveFile, "redrive", "", "dead-letter file whose events are sent again to their outputs")
+	flag.Parse()
 
 	v := viper.New()
 	v.SetDefault("Log", false)
 	v.SetDefault("ListenAddress", "")
 	v.SetDefault("ListenPort", 2801)
 	v.SetDefault("Debug", false)
+	v.SetDefault("ShutdownTimeout", 30)
 	v.SetDefault("BracketReplacer", "")
 	v.SetDefault("MutualTlsFilesPath", "/etc/certs")
 	v.SetDefault("MutualTLSClient.CertFile", "")
 	v.SetDefault("MutualTLSClient.KeyFile", "")
 	v.SetDefault("MutualTLSClient.CaCertFile", "")
 
+	v.SetDefault("Relay.Address", "")
+	v.SetDefault("Relay.Service", "")
+	v.SetDefault("Relay.Namespace", "kubearmor")
+	v.SetDefault("Relay.Port", 32767)
+	v.SetDefault("Relay.Kubeconfig", "")
+	v.SetDefault("Relay.TLS", false)
+	v.SetDefault("Relay.CaCertFile", "")
+	v.SetDefault("Relay.CertFile", "")
+	v.SetDefault("Relay.KeyFile", "")
+	v.SetDefault("Relay.ServerName", "")
+	v.SetDefault("Relay.CheckCert", true)
+	v.SetDefault("Relay.AlertFilter", "all")
+	v.SetDefault("Relay.LogFilter", "all")
+
+	v.SetDefault("Replay.Path", "")
+	v.SetDefault("Replay.Mode", "fast")
+	v.SetDefault("Replay.Rate", 10)
+
+	v.SetDefault("Buffers.Alerts.Size", outputs.DefaultAlertBufferSize)
+	v.SetDefault("Buffers.Alerts.Overflow", outputs.OverflowDropNewest)
+	v.SetDefault("Buffers.Logs.Size", outputs.DefaultLogBufferSize)
+	v.SetDefault("Buffers.Logs.Overflow", outputs.OverflowDropNewest)
+	v.SetDefault("Buffers.Outputs.Size", outputs.DefaultOutputBufferSize)
+	v.SetDefault("Buffers.Outputs.Overflow", outputs.OverflowDropNewest)
+
+	v.SetDefault("Retry.MaxAttempts", 3)
+	v.SetDefault("Retry.BaseBackoff", 500)
+	v.SetDefault("Retry.MaxBackoff", 30000)
+	v.SetDefault("Retry.Jitter", 0.2)
+	v.SetDefault("Retry.RetryableCodes", "429, 500, 502, 503, 504")
+	v.SetDefault("Retry.NetworkErrors", true)
+
+	v.SetDefault("Queue.Enabled", false)
+	v.SetDefault("Queue.Directory", "/var/lib/kubearmor-sidekick/queue")
+	v.SetDefault("Queue.SegmentSize", 16)
+	v.SetDefault("Queue.MaxSize", 1024)
+	v.SetDefault("Queue.Fsync", outputs.FsyncInterval)
+	v.SetDefault("Queue.FsyncInterval", 1000)
+
+	v.SetDefault("DeadLetter.Target", "")
+	v.SetDefault("DeadLetter.File", "/var/lib/kubearmor-sidekick/deadletter.jsonl")
+	v.SetDefault("DeadLetter.MaxSize", 100)
+	v.SetDefault("DeadLetter.MaxBackups", 3)
+
+	v.SetDefault("CircuitBreaker.Failures", 0)
+	v.SetDefault("CircuitBreaker.OpenTimeout", 30000)
+
+	v.SetDefault("HTTPClient.Timeout", 10000)
+	v.SetDefault("HTTPClient.DialTimeout", 5000)
+	v.SetDefault("HTTPClient.TLSHandshakeTimeout", 5000)
+	v.SetDefault("HTTPClient.IdleConnTimeout", 90000)
+	v.SetDefault("HTTPClient.MaxIdleConnsPerHost", 10)
+	v.SetDefault("HTTPClient.Proxy", "")
+
+	v.SetDefault("Workers.Workers", 1)
+	v.SetDefault("Workers.MaxInFlight", 0)
+	v.SetDefault("Workers.OrderingKey", "")
+
+	v.SetDefault("Batch.MaxSize", 500)
+	v.SetDefault("Batch.MaxBytes", 1000000)
+	v.SetDefault("Batch.Linger", 1000)
+
+	v.SetDefault("Rules.Include", "")
+	v.SetDefault("Rules.Exclude", "")
+	v.SetDefault("Dedup.Window", 0)
+	v.SetDefault("Dedup.Fields", "PolicyName, NamespaceName, PodName, ProcessName, Resource")
+	v.SetDefault("RateLimit.Rate", 0)
+	v.SetDefault("RateLimit.Burst", 10)
+	v.SetDefault("RateLimit.Overflow", outputs.RateLimitQueue)
+	v.SetDefault("RateLimit.SummaryInterval", 60)
+	v.SetDefault("Sampling.Rate", 1)
+	v.SetDefault("Sampling.Every", 0)
+
+	v.SetDefault("SeverityMapping.Severities", "1:debug, 2:informational, 3:notice, 4:notice, 5:warning, 6:warning, 7:error, 8:critical, 9:alert, 10:emergency")
+	v.SetDefault("SeverityMapping.LogPriority", "informational")
+	v.SetDefault("SeverityMapping.DefaultPriority", "warning")
+
 	v.SetDefault("TLSServer.Deploy", false)
 	v.SetDefault("TLSServer.CertFile", "/etc/certs/server/server.crt")
 	v.SetDefault("TLSServer.KeyFile", "/etc/certs/server/server.key")
@@ -88,6 +166,7 @@ func getConfig() *types.Configuration {
 
 	v.SetDefault("Datadog.APIKey", "")
 	v.SetDefault("Datadog.Host", "https://api.datadoghq.com")
+	v.SetDefault("Datadog.LogsHost", "https://http-intake.logs.datadoghq.com")
 	v.SetDefault("Datadog.MinimumPriority", "")
 	v.SetDefault("Datadog.MutualTLS", false)
 	v.SetDefault("Datadog.CheckCert", true)
@@ -106,6 +185,7 @@ func getConfig() *types.Configuration {
 	v.SetDefault("Alertmanager.ExpiresAfter", 0)
 	v.SetDefault("Alertmanager.DropEventDefaultPriority", "critical")
 	v.SetDefault("Alertmanager.DropEventThresholds", "10000:critical, 1000:critical, 100:critical, 10:warning, 1:warning")
+	v.SetDefault("Alertmanager.DropEventWindow", 60)
 
 	v.SetDefault("Elasticsearch.HostPort", "")
 	v.SetDefault("Elasticsearch.Index", "kubearmor")
@@ -462,6 +542,13 @@ func getConfig() *types.Configuration {
 	v.SetDefault("Dynatrace.CheckCert", true)
 	v.SetDefault("Dynatrace.MinimumPriority", "")
 
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+		if err := v.ReadInConfig(); err != nil {
+			log.Printf("[ERROR] : Error when reading config file : %v\n", err)
+		}
+	}
+
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
@@ -473,7 +560,7 @@ func getConfig() *types.Configuration {
 	v.GetStringMapString("AlertManager.ExtraAnnotations")
 	v.GetStringMapString("AlertManager.CustomSeverityMap")
 	v.GetStringMapString("GCP.PubSub.CustomAttributes")
-	if err := v.Unmarshal(c); err != nil {
+	if err := v.Unmarshal(c, decodePriorities); err != nil {
 		log.Printf("[ERROR] : Error unmarshalling config : %s", err)
 	}
 
@@ -481,6 +568,327 @@ func getConfig() *types.Configuration {
 		c.TLSServer.NoTLSPaths = strings.Split(value, ",")
 	}
 
+	setEnvFields(c)
+	// the instances inherit the settings of the configuration
+	c.Instances = getInstances(v)
+	for _, instance := range c.Instances {
+		setEnvFields(instance.Config)
+	}
+
+	if c.ListenPort == 0 || c.ListenPort > 65535 {
+		log.Fatalf("[ERROR] : Bad listening port number\n")
+	}
+
+	if c.TLSServer.NoTLSPort == 0 || c.TLSServer.NoTLSPort > 65535 {
+		log.Fatalf("[ERROR] : Bad noTLS server port number\n")
+	}
+
+	if ip := net.ParseIP(c.ListenAddress); c.ListenAddress != "" && ip == nil {
+		log.Fatalf("[ERROR] : Failed to parse ListenAddress")
+	}
+
+	c.Replay.Mode = strings.ToLower(c.Replay.Mode)
+	switch c.Replay.Mode {
+	case ReplayModeOriginal, ReplayModeFast:
+	case ReplayModeFixed:
+		if c.Replay.Rate <= 0 {
+			log.Fatalf("[ERROR] : Replay - Rate must be positive\n")
+		}
+	default:
+		log.Fatalf("[ERROR] : Replay - Bad mode '%v', it must be one of original, fixed or fast\n", c.Replay.Mode)
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		log.Fatalf("[ERROR] : ShutdownTimeout must be positive\n")
+	}
+
+	checkBuffer("Alerts", &c.Buffers.Alerts)
+	checkBuffer("Logs", &c.Buffers.Logs)
+	checkBuffer("Outputs", &c.Buffers.Outputs)
+
+	c.Retry.Outputs = nil
+	checkRetry("default", &c.Retry)
+	// the policies of the outputs inherit the settings of the Retry block
+	if names := getOutputOverrides(v, c, "Retry"); len(names) != 0 {
+		c.Retry.Outputs = make(map[string]types.RetryConfig, len(names))
+		for _, name := range names {
+			retry := c.Retry
+			if err := v.UnmarshalKey("Retry.Outputs."+name, &retry); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling retry policy of %v : %s", name, err)
+			}
+			retry.Outputs = nil
+			checkRetry(name, &retry)
+			c.Retry.Outputs[name] = retry
+		}
+	}
+
+	c.Queue.Outputs = nil
+	checkQueue("default", &c.Queue)
+	// the queues of the outputs inherit the settings of the Queue block
+	if names := getOutputOverrides(v, c, "Queue"); len(names) != 0 {
+		c.Queue.Outputs = make(map[string]types.QueueConfig, len(names))
+		for _, name := range names {
+			queue := c.Queue
+			if err := v.UnmarshalKey("Queue.Outputs."+name, &queue); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling queue of %v : %s", name, err)
+			}
+			queue.Outputs = nil
+			checkQueue(name, &queue)
+			c.Queue.Outputs[name] = queue
+		}
+	}
+
+	c.CircuitBreaker.Outputs = nil
+	checkBreaker("default", &c.CircuitBreaker)
+	// the breakers of the outputs inherit the settings of the CircuitBreaker block
+	if names := getOutputOverrides(v, c, "CircuitBreaker"); len(names) != 0 {
+		c.CircuitBreaker.Outputs = make(map[string]types.BreakerConfig, len(names))
+		for _, name := range names {
+			breaker := c.CircuitBreaker
+			if err := v.UnmarshalKey("CircuitBreaker.Outputs."+name, &breaker); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling circuit breaker of %v : %s", name, err)
+			}
+			breaker.Outputs = nil
+			checkBreaker(name, &breaker)
+			c.CircuitBreaker.Outputs[name] = breaker
+		}
+	}
+
+	c.HTTPClient.Outputs = nil
+	checkHTTPClient("default", &c.HTTPClient)
+	// the HTTP clients of the outputs inherit the settings of the HTTPClient block
+	if names := getOutputOverrides(v, c, "HTTPClient"); len(names) != 0 {
+		c.HTTPClient.Outputs = make(map[string]types.HTTPClientConfig, len(names))
+		for _, name := range names {
+			httpClient := c.HTTPClient
+			if err := v.UnmarshalKey("HTTPClient.Outputs."+name, &httpClient); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling HTTP client of %v : %s", name, err)
+			}
+			httpClient.Outputs = nil
+			checkHTTPClient(name, &httpClient)
+			c.HTTPClient.Outputs[name] = httpClient
+		}
+	}
+
+	c.Workers.Outputs = nil
+	checkWorkers("default", &c.Workers)
+	// the workers of the outputs inherit the settings of the Workers block
+	if names := getOutputOverrides(v, c, "Workers"); len(names) != 0 {
+		c.Workers.Outputs = make(map[string]types.WorkersConfig, len(names))
+		for _, name := range names {
+			workers := c.Workers
+			if err := v.UnmarshalKey("Workers.Outputs."+name, &workers); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling workers of %v : %s", name, err)
+			}
+			workers.Outputs = nil
+			checkWorkers(name, &workers)
+			c.Workers.Outputs[name] = workers
+		}
+	}
+
+	c.Batch.Outputs = nil
+	checkBatch("default", &c.Batch)
+	// the batches of the outputs inherit the settings of the Batch block
+	if names := getOutputOverrides(v, c, "Batch"); len(names) != 0 {
+		c.Batch.Outputs = make(map[string]types.BatchConfig, len(names))
+		for _, name := range names {
+			batch := c.Batch
+			if err := v.UnmarshalKey("Batch.Outputs."+name, &batch); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling batch of %v : %s", name, err)
+			}
+			batch.Outputs = nil
+			checkBatch(name, &batch)
+			c.Batch.Outputs[name] = batch
+		}
+	}
+
+	c.Rules.Outputs = nil
+	checkRules("default", c.Rules)
+	// the rules of the outputs inherit the rules of the Rules block
+	if names := getOutputOverrides(v, c, "Rules"); len(names) != 0 {
+		c.Rules.Outputs = make(map[string]types.RulesConfig, len(names))
+		for _, name := range names {
+			rules := c.Rules
+			if err := v.UnmarshalKey("Rules.Outputs."+name, &rules); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling rules of %v : %s", name, err)
+			}
+			rules.Outputs = nil
+			checkRules(name, rules)
+			c.Rules.Outputs[name] = rules
+		}
+	}
+
+	c.Dedup.Outputs = nil
+	checkDedup("default", &c.Dedup)
+	// the deduplication of the outputs inherits the settings of the Dedup block
+	if names := getOutputOverrides(v, c, "Dedup"); len(names) != 0 {
+		c.Dedup.Outputs = make(map[string]types.DedupConfig, len(names))
+		for _, name := range names {
+			dedup := c.Dedup
+			if err := v.UnmarshalKey("Dedup.Outputs."+name, &dedup); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling deduplication of %v : %s", name, err)
+			}
+			dedup.Outputs = nil
+			checkDedup(name, &dedup)
+			c.Dedup.Outputs[name] = dedup
+		}
+	}
+
+	c.RateLimit.Outputs = nil
+	checkRateLimit("default", &c.RateLimit)
+	// the rate limits of the outputs inherit the settings of the RateLimit block
+	if names := getOutputOverrides(v, c, "RateLimit"); len(names) != 0 {
+		c.RateLimit.Outputs = make(map[string]types.RateLimitConfig, len(names))
+		for _, name := range names {
+			rateLimit := c.RateLimit
+			if err := v.UnmarshalKey("RateLimit.Outputs."+name, &rateLimit); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling rate limit of %v : %s", name, err)
+			}
+			rateLimit.Outputs = nil
+			checkRateLimit(name, &rateLimit)
+			c.RateLimit.Outputs[name] = rateLimit
+		}
+	}
+
+	c.Sampling.Outputs = nil
+	checkSampling("default", c.Sampling)
+	// the sampling of the outputs inherits the settings of the Sampling block
+	if names := getOutputOverrides(v, c, "Sampling"); len(names) != 0 {
+		c.Sampling.Outputs = make(map[string]types.SamplingConfig, len(names))
+		for _, name := range names {
+			sampling := c.Sampling
+			if err := v.UnmarshalKey("Sampling.Outputs."+name, &sampling); err != nil {
+				log.Fatalf("[ERROR] : Error unmarshalling sampling of %v : %s", name, err)
+			}
+			sampling.Outputs = nil
+			checkSampling(name, sampling)
+			c.Sampling.Outputs[name] = sampling
+		}
+	}
+
+	c.DeadLetter.Target = strings.ToLower(strings.TrimSpace(c.DeadLetter.Target))
+	if c.DeadLetter.Target == outputs.DeadLetterFile {
+		if c.DeadLetter.File == "" {
+			log.Fatalf("[ERROR] : DeadLetter - File must be set\n")
+		}
+		if c.DeadLetter.MaxSize <= 0 || c.DeadLetter.MaxBackups < 0 {
+			log.Fatalf("[ERROR] : DeadLetter - MaxSize must be positive and MaxBackups not negative\n")
+		}
+	}
+
+	// the relays of the list inherit the settings of the Relay block
+	if relays, ok := v.Get("Relays").([]interface{}); ok && len(relays) != 0 {
+		c.Relays = make([]types.RelayConfig, len(relays))
+		for i := range c.Relays {
+			c.Relays[i] = c.Relay
+		}
+		if err := v.UnmarshalKey("Relays", &c.Relays); err != nil {
+			log.Fatalf("[ERROR] : Error unmarshalling relays : %s", err)
+		}
+	} else {
+		c.Relays = []types.RelayConfig{c.Relay}
+	}
+
+	relayNames := make(map[string]bool)
+	relays := make([]types.RelayConfig, 0, len(c.Relays))
+	for i := range c.Relays {
+		relay := &c.Relays[i]
+		if relay.Name == "" {
+			relay.Name = getRelayDefaultName(*relay, i)
+		}
+		if relayNames[relay.Name] {
+			log.Fatalf("[ERROR] : Relay %v - The name is used by several relays\n", relay.Name)
+		}
+		relayNames[relay.Name] = true
+
+		if relay.Port == 0 || relay.Port > 65535 {
+			log.Fatalf("[ERROR] : Relay %v - Bad relay port number\n", relay.Name)
+		}
+
+		if (relay.CertFile == "") != (relay.KeyFile == "") {
+			log.Fatalf("[ERROR] : Relay %v - CertFile and KeyFile must be set together\n", relay.Name)
+		}
+
+		relay.AlertFilter = checkRelayFilter(relay.Name, relay.AlertFilter)
+		relay.LogFilter = checkRelayFilter(relay.Name, relay.LogFilter)
+		if relay.AlertFilter == RelayFilterNone && (relay.LogFilter == RelayFilterNone || !c.Log) {
+			// the events may only be pushed to the HTTP listener
+			log.Printf("[INFO]  : Relay %v - Both the alert and log streams are disabled, the relay is not used\n", relay.Name)
+			continue
+		}
+		relays = append(relays, *relay)
+	}
+	c.Relays = relays
+	if c.Replay.Path != "" {
+		log.Printf("[INFO]  : Replay - The events are replayed from %v, the relays are not used\n", c.Replay.Path)
+		c.Relays = nil
+	}
+
+	if c.Prometheus.ExtraLabels != "" {
+		c.Prometheus.ExtraLabelsList = strings.Split(strings.ReplaceAll(c.Prometheus.ExtraLabels, " ", ""), ",")
+	}
+
+	c.SeverityMapping.SeveritiesMap = make(map[string]types.PriorityType)
+	if c.SeverityMapping.Severities != "" {
+		for _, mapping := range strings.Split(c.SeverityMapping.Severities, ",") {
+			severity, priority, found := strings.Cut(mapping, ":")
+			severity, priority = strings.ToLower(strings.TrimSpace(severity)), strings.TrimSpace(priority)
+			if !found || severity == "" {
+				log.Printf("[ERROR] : SeverityMapping - Fail to parse mapping '%v'", mapping)
+				continue
+			}
+			p := types.Priority(priority)
+			if p == types.Default {
+				log.Printf("[ERROR] : SeverityMapping - Priority '%v' is not a valid kubearmor priority level", priority)
+				continue
+			}
+			c.SeverityMapping.SeveritiesMap[severity] = p
+		}
+	}
+	c.SeverityMapping.LogPriority = checkPriority(c.SeverityMapping.LogPriority)
+	c.SeverityMapping.DefaultPriority = checkPriority(c.SeverityMapping.DefaultPriority)
+
+	checkOutputs(c)
+	for _, instance := range c.Instances {
+		checkOutputs(instance.Config)
+	}
+	return c
+}
+
+// decodePriorities decodes the priorities of the configuration from their
+// names, like the keys of Alertmanager.CustomSeverityMap
+var decodePriorities = viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+	mapstructure.DecodeHookFuncType(func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to != reflect.TypeOf(types.PriorityType(0)) {
+			return data, nil
+		}
+		return types.Priority(data.(string)), nil
+	}),
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.StringToSliceHookFunc(","),
+))
+
+// newConfiguration returns a configuration with the maps set from the
+// environment variables allocated
+func newConfiguration() *types.Configuration {
+	return &types.Configuration{
+		Customfields:    make(map[string]string),
+		Templatedfields: make(map[string]string),
+		TLSServer:       types.TLSServer{NoTLSPaths: make([]string, 0)},
+		Grafana:         types.GrafanaOutputConfig{CustomHeaders: make(map[string]string)},
+		Loki:            types.LokiOutputConfig{CustomHeaders: make(map[string]string)},
+		Elasticsearch:   types.ElasticsearchOutputConfig{CustomHeaders: make(map[string]string)},
+		OpenObserve:     types.OpenObserveConfig{CustomHeaders: make(map[string]string)},
+		Webhook:         types.WebhookOutputConfig{CustomHeaders: make(map[string]string)},
+		Alertmanager:    types.AlertmanagerOutputConfig{ExtraLabels: make(map[string]string), ExtraAnnotations: make(map[string]string), CustomSeverityMap: make(map[types.PriorityType]string)},
+		CloudEvents:     types.CloudEventsOutputConfig{Extensions: make(map[string]string)},
+		GCP:             types.GcpOutputConfig{PubSub: types.GcpPubSub{CustomAttributes: make(map[string]string)}},
+	}
+}
+
+// setEnvFields sets the fields of the configuration from the environment
+// variables viper can't decode
+func setEnvFields(c *types.Configuration) {
 	if value, present := os.LookupEnv("CUSTOMFIELDS"); present {
 		customfields := strings.Split(value, ",")
 		for _, label := range customfields {
@@ -550,6 +958,18 @@ func getConfig() *types.Configuration {
 		}
 	}
 
+	if value, present := os.LookupEnv("ALERTMANAGER_CUSTOMSEVERITYMAP"); present {
+		for _, mapping := range strings.Split(value, ",") {
+			priority, severity, found := strings.Cut(mapping, ":")
+			priority, severity = strings.TrimSpace(priority), strings.TrimSpace(severity)
+			if !found || types.Priority(priority) == types.Default {
+				log.Printf("[ERROR] : AlertManager - Fail to parse custom severity '%v'", mapping)
+				continue
+			}
+			c.Alertmanager.CustomSeverityMap[types.Priority(priority)] = severity
+		}
+	}
+
 	if value, present := os.LookupEnv("ALERTMANAGER_EXTRAANNOTATIONS"); present {
 		extraAnnotations := strings.Split(value, ",")
 		for _, annotationData := range extraAnnotations {
@@ -578,7 +998,67 @@ func getConfig() *types.Configuration {
 			}
 		}
 	}
+}
 
+// getInstances returns the named instances of the outputs listed in the
+// Instances block, their configuration is the configuration overridden by the
+// blocks they set.
+func getInstances(v *viper.Viper) []types.InstanceConfig {
+	list, ok := v.Get("Instances").([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+	instanceNameRegex := regexp.MustCompile("^[a-zA-Z0-9_-]+$")
+	names := make(map[string]bool)
+	instances := make([]types.InstanceConfig, 0, len(list))
+	for i, item := range list {
+		settings, ok := item.(map[string]interface{})
+		if !ok {
+			log.Fatalf("[ERROR] : Instance %v - Bad settings\n", i)
+		}
+		blocks := make(map[string]interface{}, len(settings))
+		var name string
+		for key, value := range settings {
+			if strings.EqualFold(key, "Name") {
+				name = fmt.Sprint(value)
+				continue
+			}
+			blocks[key] = value
+		}
+		if !instanceNameRegex.MatchString(name) || strings.EqualFold(name, outputs.DefaultInstance) {
+			log.Fatalf("[ERROR] : Instance %v - Bad name '%v', it must be made of letters, digits, - and _ and not be %v\n", i, name, outputs.DefaultInstance)
+		}
+		if names[strings.ToLower(name)] {
+			log.Fatalf("[ERROR] : Instance %v - The name is used by several instances\n", name)
+		}
+		names[strings.ToLower(name)] = true
+
+		// AllSettings returns new maps, the instances don't share them
+		inherited := v.AllSettings()
+		delete(inherited, "instances")
+		iv := viper.New()
+		if err := iv.MergeConfigMap(inherited); err != nil {
+			log.Fatalf("[ERROR] : Instance %v - %v\n", name, err)
+		}
+		if err := iv.MergeConfigMap(blocks); err != nil {
+			log.Fatalf("[ERROR] : Instance %v - %v\n", name, err)
+		}
+		config := newConfiguration()
+		if err := iv.Unmarshal(config, decodePriorities); err != nil {
+			log.Fatalf("[ERROR] : Instance %v - Error unmarshalling config : %s", name, err)
+		}
+		instance := types.InstanceConfig{Name: name, Config: config}
+		for block := range blocks {
+			instance.Blocks = append(instance.Blocks, strings.ToLower(block))
+		}
+		sort.Strings(instance.Blocks)
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+// checkOutputs checks and completes the settings of the outputs
+func checkOutputs(c *types.Configuration) {
 	if c.AWS.SecurityLake.Interval < 5 {
 		c.AWS.SecurityLake.Interval = 5
 	}
@@ -586,26 +1066,10 @@ func getConfig() *types.Configuration {
 		c.AWS.SecurityLake.Interval = 60
 	}
 
-	if c.ListenPort == 0 || c.ListenPort > 65536 {
-		log.Fatalf("[ERROR] : Bad listening port number\n")
-	}
-
-	if c.TLSServer.NoTLSPort == 0 || c.TLSServer.NoTLSPort > 65536 {
-		log.Fatalf("[ERROR] : Bad noTLS server port number\n")
-	}
-
-	if ip := net.ParseIP(c.ListenAddress); c.ListenAddress != "" && ip == nil {
-		log.Fatalf("[ERROR] : Failed to parse ListenAddress")
-	}
-
 	if c.Loki.ExtraLabels != "" {
 		c.Loki.ExtraLabelsList = strings.Split(strings.ReplaceAll(c.Loki.ExtraLabels, " ", ""), ",")
 	}
 
-	if c.Prometheus.ExtraLabels != "" {
-		c.Prometheus.ExtraLabelsList = strings.Split(strings.ReplaceAll(c.Prometheus.ExtraLabels, " ", ""), ",")
-	}
-
 	if c.Alertmanager.DropEventThresholds != "" {
 		c.Alertmanager.DropEventThresholdsList = make([]types.ThresholdConfig, 0)
 		thresholds := strings.Split(strings.ReplaceAll(c.Alertmanager.DropEventThresholds, " ", ""), ",")
@@ -642,8 +1106,16 @@ func getConfig() *types.Configuration {
 	c.Mattermost.MinimumPriority = checkPriority(c.Mattermost.MinimumPriority)
 	c.Teams.MinimumPriority = checkPriority(c.Teams.MinimumPriority)
 	c.Datadog.MinimumPriority = checkPriority(c.Datadog.MinimumPriority)
+	c.Discord.MinimumPriority = checkPriority(c.Discord.MinimumPriority)
 	c.Alertmanager.MinimumPriority = checkPriority(c.Alertmanager.MinimumPriority)
 	c.Alertmanager.DropEventDefaultPriority = checkPriority(c.Alertmanager.DropEventDefaultPriority)
+	if _, found := c.Alertmanager.CustomSeverityMap[types.Default]; found {
+		log.Printf("[ERROR] : AlertManager - Custom severity of an unknown priority, it is ignored")
+		delete(c.Alertmanager.CustomSeverityMap, types.Default)
+	}
+	if c.Alertmanager.DropEventWindow <= 0 {
+		log.Fatalf("[ERROR] : AlertManager - DropEventWindow must be positive\n")
+	}
 	c.Elasticsearch.MinimumPriority = checkPriority(c.Elasticsearch.MinimumPriority)
 	c.Influxdb.MinimumPriority = checkPriority(c.Influxdb.MinimumPriority)
 	c.Loki.MinimumPriority = checkPriority(c.Loki.MinimumPriority)
@@ -673,6 +1145,8 @@ func getConfig() *types.Configuration {
 	c.Openfaas.MinimumPriority = checkPriority(c.Openfaas.MinimumPriority)
 	c.Tekton.MinimumPriority = checkPriority(c.Tekton.MinimumPriority)
 	c.Fission.MinimumPriority = checkPriority(c.Fission.MinimumPriority)
+	c.Grafana.MinimumPriority = checkPriority(c.Grafana.MinimumPriority)
+	c.GrafanaOnCall.MinimumPriority = checkPriority(c.GrafanaOnCall.MinimumPriority)
 	c.Rabbitmq.MinimumPriority = checkPriority(c.Rabbitmq.MinimumPriority)
 	c.Wavefront.MinimumPriority = checkPriority(c.Wavefront.MinimumPriority)
 	c.Yandex.S3.MinimumPriority = checkPriority(c.Yandex.S3.MinimumPriority)
@@ -696,7 +1170,6 @@ func getConfig() *types.Configuration {
 	c.Mattermost.MessageFormatTemplate = getMessageFormatTemplate("Mattermost", c.Mattermost.MessageFormat)
 	c.Googlechat.MessageFormatTemplate = getMessageFormatTemplate("Googlechat", c.Googlechat.MessageFormat)
 	c.Cliq.MessageFormatTemplate = getMessageFormatTemplate("Cliq", c.Cliq.MessageFormat)
-	return c
 }
 
 func checkPriority(prio string) string {
@@ -708,6 +1181,216 @@ func checkPriority(prio string) string {
 	return ""
 }
 
+func checkRelayFilter(relay, filter string) string {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	switch filter {
+	case RelayFilterAll, RelayFilterPolicy, RelayFilterSystem, RelayFilterNone:
+		return filter
+	}
+	log.Fatalf("[ERROR] : Relay %v - Bad filter '%v', it must be one of all, policy, system or none\n", relay, filter)
+	return ""
+}
+
+// checkBuffer validates the size and the overflow policy of a buffer
+func checkBuffer(name string, buffer *types.BufferConfig) {
+	if buffer.Size <= 0 {
+		log.Fatalf("[ERROR] : Buffers - %v size must be positive\n", name)
+	}
+	buffer.Overflow = strings.ToLower(strings.TrimSpace(buffer.Overflow))
+	switch buffer.Overflow {
+	case outputs.OverflowBlock, outputs.OverflowDropNewest, outputs.OverflowDropOldest:
+		return
+	}
+	log.Fatalf("[ERROR] : Buffers - Bad %v overflow '%v', it must be one of block, drop-newest or drop-oldest\n", name, buffer.Overflow)
+}
+
+// checkRetry validates a retry policy and parses its retryable codes
+func checkRetry(name string, retry *types.RetryConfig) {
+	if retry.MaxAttempts < 1 {
+		log.Fatalf("[ERROR] : Retry %v - MaxAttempts must be at least 1\n", name)
+	}
+	if retry.BaseBackoff < 0 || retry.MaxBackoff < retry.BaseBackoff {
+		log.Fatalf("[ERROR] : Retry %v - BaseBackoff must be positive and lower than MaxBackoff\n", name)
+	}
+	if retry.Jitter < 0 || retry.Jitter > 1 {
+		log.Fatalf("[ERROR] : Retry %v - Jitter must be between 0 and 1\n", name)
+	}
+	retry.RetryableCodesList = nil
+	for _, i := range strings.Split(retry.RetryableCodes, ",") {
+		if i = strings.TrimSpace(i); i == "" {
+			continue
+		}
+		code, err := strconv.Atoi(i)
+		if err != nil || code < 100 || code > 599 {
+			log.Fatalf("[ERROR] : Retry %v - Bad retryable code '%v'\n", name, i)
+		}
+		retry.RetryableCodesList = append(retry.RetryableCodesList, code)
+	}
+}
+
+// checkQueue validates the settings of a queue
+func checkQueue(name string, queue *types.QueueConfig) {
+	if queue.Directory == "" {
+		log.Fatalf("[ERROR] : Queue %v - Directory must be set\n", name)
+	}
+	if queue.SegmentSize <= 0 || queue.MaxSize < queue.SegmentSize {
+		log.Fatalf("[ERROR] : Queue %v - SegmentSize must be positive and lower than MaxSize\n", name)
+	}
+	queue.Fsync = strings.ToLower(strings.TrimSpace(queue.Fsync))
+	switch queue.Fsync {
+	case outputs.FsyncAlways, outputs.FsyncNever:
+	case outputs.FsyncInterval:
+		if queue.FsyncInterval <= 0 {
+			log.Fatalf("[ERROR] : Queue %v - FsyncInterval must be positive\n", name)
+		}
+	default:
+		log.Fatalf("[ERROR] : Queue %v - Bad fsync '%v', it must be one of always, interval or never\n", name, queue.Fsync)
+	}
+}
+
+// checkBreaker validates the settings of a circuit breaker
+func checkBreaker(name string, breaker *types.BreakerConfig) {
+	if breaker.Failures < 0 {
+		log.Fatalf("[ERROR] : CircuitBreaker %v - Failures must not be negative\n", name)
+	}
+	if breaker.Failures > 0 && breaker.OpenTimeout <= 0 {
+		log.Fatalf("[ERROR] : CircuitBreaker %v - OpenTimeout must be positive\n", name)
+	}
+}
+
+// checkHTTPClient validates the timeouts and the proxy of an HTTP client
+func checkHTTPClient(name string, httpClient *types.HTTPClientConfig) {
+	if httpClient.Timeout < 0 || httpClient.DialTimeout < 0 || httpClient.TLSHandshakeTimeout < 0 || httpClient.IdleConnTimeout < 0 || httpClient.MaxIdleConnsPerHost < 0 {
+		log.Fatalf("[ERROR] : HTTPClient %v - The timeouts and MaxIdleConnsPerHost must not be negative\n", name)
+	}
+	if httpClient.Proxy == "" {
+		return
+	}
+	if u, err := url.Parse(httpClient.Proxy); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		log.Fatalf("[ERROR] : HTTPClient %v - Bad proxy '%v', it must be an http or https URL\n", name, httpClient.Proxy)
+	}
+}
+
+// checkWorkers validates the settings of the workers of an output
+func checkWorkers(name string, workers *types.WorkersConfig) {
+	if workers.Workers <= 0 || workers.MaxInFlight < 0 {
+		log.Fatalf("[ERROR] : Workers %v - Workers must be positive and MaxInFlight not negative\n", name)
+	}
+	workers.OrderingKey = strings.ToLower(strings.TrimSpace(workers.OrderingKey))
+	switch workers.OrderingKey {
+	case "", outputs.OrderingPod, outputs.OrderingNamespace, outputs.OrderingHost:
+	default:
+		log.Fatalf("[ERROR] : Workers %v - Bad ordering key '%v', it must be one of pod, namespace or host\n", name, workers.OrderingKey)
+	}
+}
+
+// checkBatch validates the settings of the batches of an output
+func checkBatch(name string, batch *types.BatchConfig) {
+	if batch.MaxSize <= 0 || batch.MaxBytes < 0 {
+		log.Fatalf("[ERROR] : Batch %v - MaxSize must be positive and MaxBytes not negative\n", name)
+	}
+	if batch.MaxSize > 1 && batch.Linger <= 0 {
+		log.Fatalf("[ERROR] : Batch %v - Linger must be positive\n", name)
+	}
+}
+
+// checkDedup validates the settings of the deduplication of an output
+func checkDedup(name string, dedup *types.DedupConfig) {
+	if dedup.Window < 0 {
+		log.Fatalf("[ERROR] : Dedup %v - Window must not be negative\n", name)
+	}
+	dedup.FieldsList = nil
+	for _, field := range strings.Split(dedup.Fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			dedup.FieldsList = append(dedup.FieldsList, field)
+		}
+	}
+	if dedup.Window > 0 && len(dedup.FieldsList) == 0 {
+		log.Fatalf("[ERROR] : Dedup %v - Fields must be set\n", name)
+	}
+}
+
+// checkRateLimit validates the settings of the rate limit of an output
+func checkRateLimit(name string, rateLimit *types.RateLimitConfig) {
+	if rateLimit.Rate < 0 {
+		log.Fatalf("[ERROR] : RateLimit %v - Rate must not be negative\n", name)
+	}
+	if rateLimit.Burst <= 0 {
+		log.Fatalf("[ERROR] : RateLimit %v - Burst must be positive\n", name)
+	}
+	rateLimit.Overflow = strings.ToLower(rateLimit.Overflow)
+	switch rateLimit.Overflow {
+	case outputs.RateLimitQueue, outputs.RateLimitDrop:
+	case outputs.RateLimitSummary:
+		if rateLimit.SummaryInterval <= 0 {
+			log.Fatalf("[ERROR] : RateLimit %v - SummaryInterval must be positive\n", name)
+		}
+	default:
+		log.Fatalf("[ERROR] : RateLimit %v - Bad overflow '%v', it must be one of queue, drop or summary\n", name, rateLimit.Overflow)
+	}
+}
+
+// checkSampling validates the settings of the sampling of an output
+func checkSampling(name string, sampling types.SamplingConfig) {
+	if sampling.Rate < 0 || sampling.Rate > 1 {
+		log.Fatalf("[ERROR] : Sampling %v - Rate must be between 0 and 1\n", name)
+	}
+	if sampling.Every < 0 {
+		log.Fatalf("[ERROR] : Sampling %v - Every must not be negative\n", name)
+	}
+	if sampling.Every > 1 && sampling.Rate < 1 {
+		log.Fatalf("[ERROR] : Sampling %v - Rate and Every can't be set together\n", name)
+	}
+}
+
+// checkRules compiles the rules of an output to report their errors at startup
+func checkRules(name string, rules types.RulesConfig) {
+	if _, err := outputs.CompileRules(rules); err != nil {
+		log.Fatalf("[ERROR] : Rules %v - %v\n", name, err)
+	}
+}
+
+// getOutputOverrides returns the lowercase names of the outputs set in the
+// Outputs map of the block, the outputs of the instances are named
+// <output>:<instance>. It exits if one of them is unknown.
+func getOutputOverrides(v *viper.Viper, c *types.Configuration, block string) []string {
+	overrides := v.GetStringMap(block + ".Outputs")
+	if len(overrides) == 0 {
+		return nil
+	}
+	outputNames := make(map[string]bool)
+	for _, r := range outputs.Registrations() {
+		outputNames[strings.ToLower(r.Name)] = true
+		for _, instance := range c.Instances {
+			outputNames[strings.ToLower(outputs.InstanceName(r.Name, instance.Name))] = true
+		}
+	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		name = strings.ToLower(name)
+		if !outputNames[name] {
+			log.Fatalf("[ERROR] : %v - Unknown output '%v'\n", block, name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getRelayDefaultName names a relay after its address or service, the relay
+// found by discovery is named "default" like the default KubeArmor cluster.
+func getRelayDefaultName(relay types.RelayConfig, i int) string {
+	switch {
+	case relay.Address != "":
+		return relay.Address
+	case relay.Service != "":
+		return relay.Service
+	case i == 0:
+		return "default"
+	}
+	return fmt.Sprintf("relay-%d", i)
+}
+
 func getMessageFormatTemplate(output, temp string) *template.Template {
 	if temp != "" {
 		var err error
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	pb "github.com/kubearmor/KubeArmor/protobuf"
+
+	"github.com/kubearmor/sidekick/outputs"
+	"github.com/kubearmor/sidekick/types"
+)
+
+// maxEventsBodySize is the maximum size of the body of a request to eventsHandler
+const maxEventsBodySize = 10 << 20
+
+// eventsResult is the body returned by eventsHandler
+type eventsResult struct {
+	Accepted int      `json:"accepted"`
+	Rejected int      `json:"rejected"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// eventsHandler accepts KubeArmor events pushed as JSON: a single event, an
+// array or NDJSON. The events are either in the format of the relay (as
+// exported by karmor) or in the format sent by the outputs, and are fanned
+// out to the outputs like the events streamed from the relay.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Please send with post http method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var result eventsResult
+	reject := func(err error) {
+		result.Rejected++
+		result.Errors = append(result.Errors, err.Error())
+		stats.Requests.Add(outputs.Rejected, 1)
+		promStats.Inputs.With(map[string]string{"source": "requests", "status": outputs.Rejected}).Inc()
+	}
+
+	events, err := readEvents(http.MaxBytesReader(w, r.Body, maxEventsBodySize))
+	for _, raw := range events {
+		stats.Requests.Add(outputs.Total, 1)
+		kubearmorpayload, err := newKubearmorPayload(raw)
+		if err == nil {
+			err = sendEvent(kubearmorpayload, false)
+		}
+		if err != nil {
+			reject(err)
+			continue
+		}
+		result.Accepted++
+		stats.Requests.Add(outputs.Accepted, 1)
+		promStats.Inputs.With(map[string]string{"source": "requests", "status": outputs.Accepted}).Inc()
+	}
+	if err != nil {
+		// the events after a malformed one are lost
+		stats.Requests.Add(outputs.Total, 1)
+		reject(err)
+	}
+
+	status := http.StatusOK
+	if result.Accepted == 0 {
+		status = http.StatusBadRequest
+		if result.Rejected == 0 {
+			result.Rejected++
+			result.Errors = append(result.Errors, "no event in the body")
+		}
+	}
+	if config.Debug {
+		log.Printf("[DEBUG] : Events request, %v accepted, %v rejected\n", result.Accepted, result.Rejected)
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// #nosec G104 nothing to be done if the following fails
+	json.NewEncoder(w).Encode(result)
+}
+
+// sendEvent fans the event out to the outputs. With wait, it waits for room
+// in the queues of the outputs, otherwise the outputs with a full queue miss it.
+func sendEvent(kubearmorpayload types.KubearmorPayload, wait bool) error {
+	switch {
+	case kubearmorpayload.EventType == outputs.LogEventType && !config.Log:
+		return errors.New("logs are disabled")
+	case kubearmorpayload.EventType == outputs.LogEventType && wait:
+		outputs.BroadcastLogWait(kubearmorpayload)
+	case kubearmorpayload.EventType == outputs.LogEventType:
+		outputs.BroadcastLog(kubearmorpayload)
+	case wait:
+		outputs.BroadcastAlertWait(kubearmorpayload)
+	default:
+		outputs.BroadcastAlert(kubearmorpayload)
+	}
+	return nil
+}
+
+// readEvents splits the body in raw JSON events, it returns the events read
+// before an error.
+func readEvents(body io.Reader) ([]json.RawMessage, error) {
+	var events []json.RawMessage
+	d := json.NewDecoder(body)
+	for {
+		var raw json.RawMessage
+		if err := d.Decode(&raw); err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return events, fmt.Errorf("malformed JSON: %w", err)
+		}
+
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+			var array []json.RawMessage
+			if err := json.Unmarshal(raw, &array); err != nil {
+				return events, fmt.Errorf("malformed JSON: %w", err)
+			}
+			events = append(events, array...)
+			continue
+		}
+		events = append(events, raw)
+	}
+}
+
+// newKubearmorPayload decodes and validates a raw JSON event
+func newKubearmorPayload(raw json.RawMessage) (types.KubearmorPayload, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return types.KubearmorPayload{}, fmt.Errorf("malformed event: %w", err)
+	}
+
+	// format sent by the outputs
+	if _, ok := fields["Detail"]; ok {
+		var kubearmorpayload types.KubearmorPayload
+		if err := json.Unmarshal(raw, &kubearmorpayload); err != nil {
+			return types.KubearmorPayload{}, fmt.Errorf("malformed event: %w", err)
+		}
+		if kubearmorpayload.EventType != outputs.AlertEventType && kubearmorpayload.EventType != outputs.LogEventType {
+			return types.KubearmorPayload{}, fmt.Errorf("unknown EventType '%v'", kubearmorpayload.EventType)
+		}
+		if kubearmorpayload.OutputFields == nil {
+			return types.KubearmorPayload{}, errors.New("missing Detail")
+		}
+		return kubearmorpayload, nil
+	}
+
+	// format of the relay
+	var eventType string
+	if t, ok := fields["Type"]; !ok || json.Unmarshal(t, &eventType) != nil || eventType == "" {
+		return types.KubearmorPayload{}, errors.New("missing Type")
+	}
+	if _, ok := fields["PolicyName"]; ok || strings.HasPrefix(eventType, "Matched") {
+		var alert pb.Alert
+		if err := json.Unmarshal(raw, &alert); err != nil {
+			return types.KubearmorPayload{}, fmt.Errorf("malformed alert: %w", err)
+		}
+		return outputs.NewAlertPayload(&alert), nil
+	}
+	var l pb.Log
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return types.KubearmorPayload{}, fmt.Errorf("malformed log: %w", err)
+	}
+	return outputs.NewLogPayload(&l), nil
+}
+
+// pingHandler is a simple handler to test if daemon is UP.
+func pingHandler(w http.ResponseWriter, r *http.Request) {
+	// #nosec G104 nothing to be done if the following fails
+	w.Write([]byte("pong\n"))
+}
+
+// healthHandler is the liveness probe, it answers as long as the daemon is UP.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	// #nosec G104 nothing to be done if the following fails
+	w.Write([]byte(`{"status": "ok"}`))
+}
+
+// readiness is the body returned by readyHandler
+type readiness struct {
+	Status string            `json:"status"`
+	Relays map[string]string `json:"relays"`
+	// Outputs is the state of the circuit breakers of the outputs
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// readyHandler is the readiness probe, it fails while the gRPC streams from
+// none of the relays are connected. The state of the circuit breakers of the
+// outputs is reported, without changing the status.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	ready := readiness{Status: "not ready", Relays: make(map[string]string)}
+	for _, b := range outputs.BreakerStats() {
+		if ready.Outputs == nil {
+			ready.Outputs = make(map[string]string)
+		}
+		ready.Outputs[b.Output] = b.State.String()
+	}
+	status := http.StatusServiceUnavailable
+	if len(relayStreams) == 0 {
+		// the events are only pushed to the HTTP listener
+		ready.Status = "ok"
+		status = http.StatusOK
+	}
+	for _, s := range relayStreams {
+		if !s.connected.Load() {
+			ready.Relays[s.relay.Name] = "disconnected"
+			continue
+		}
+		ready.Relays[s.relay.Name] = "connected"
+		ready.Status = "ok"
+		status = http.StatusOK
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// #nosec G104 nothing to be done if the following fails
+	json.NewEncoder(w).Encode(ready)
+}
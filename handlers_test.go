@@ -0,0 +1,113 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/outputs"
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestReadyHandler(t *testing.T) {
+	defer func() { relayStreams = nil }()
+
+	relayStreams = []*relayStream{
+		{relay: types.RelayConfig{Name: "cluster1"}},
+		{relay: types.RelayConfig{Name: "cluster2"}},
+	}
+	w := httptest.NewRecorder()
+	readyHandler(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.JSONEq(t, `{"status": "not ready", "relays": {"cluster1": "disconnected", "cluster2": "disconnected"}}`, w.Body.String())
+
+	relayStreams[1].connected.Store(true)
+	w = httptest.NewRecorder()
+	readyHandler(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status": "ok", "relays": {"cluster1": "disconnected", "cluster2": "connected"}}`, w.Body.String())
+}
+
+func TestNewServers(t *testing.T) {
+	config := &types.Configuration{ListenPort: 2801}
+	server, noTLSServer := newServers(config)
+	require.Nil(t, noTLSServer)
+	require.Equal(t, ":2801", server.Addr)
+
+	for _, path := range []string{"/events", "/ping", "/healthz", "/ready", "/metrics", "/debug/vars"} {
+		w := httptest.NewRecorder()
+		server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.NotEqual(t, http.StatusNotFound, w.Code, path)
+	}
+
+	config.TLSServer = types.TLSServer{Deploy: true, NoTLSPort: 2810, NoTLSPaths: []string{"/metrics", "/healthz"}}
+	server, noTLSServer = newServers(config)
+	require.NotNil(t, noTLSServer)
+	require.Equal(t, ":2810", noTLSServer.Addr)
+
+	w := httptest.NewRecorder()
+	noTLSServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	require.Equal(t, "pong\n", w.Body.String())
+}
+
+func TestEventsHandler(t *testing.T) {
+	config = &types.Configuration{Log: false}
+	stats = &types.Statistics{Requests: new(expvar.Map).Init()}
+	promStats = &types.PromStatistics{Inputs: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_inputs"}, []string{"source", "status"})}
+	defer func() { config, stats, promStats = nil, nil, nil }()
+
+	outputs.Initvariable(types.BuffersConfig{})
+	alerts := outputs.NewBuffer[types.KubearmorPayload](types.BufferConfig{Size: 10}, 0, outputs.OutputAlertsStage, "test")
+	defer alerts.Close()
+	outputs.AlertLock.Lock()
+	outputs.AlertStructs["test"] = outputs.AlertStruct{Broadcast: alerts}
+	outputs.AlertLock.Unlock()
+
+	body := strings.Join([]string{
+		`{"Timestamp": 1631542902, "ClusterName": "default", "HostName": "node1", "NamespaceName": "wordpress-mysql", "PolicyName": "block-ls", "Severity": "5", "Type": "MatchedPolicy"}`,
+		`{"Timestamp": 1631542902, "HostName": "node1", "EventType": "Alert", "Detail": {"PolicyName": "block-ls"}}`,
+		`{"Timestamp": 1631542902, "HostName": "node1", "Type": "ContainerLog"}`,
+		`{"HostName": "node1"}`,
+	}, "\n")
+	w := httptest.NewRecorder()
+	eventsHandler(w, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"accepted": 2, "rejected": 2, "errors": ["logs are disabled", "missing Type"]}`, w.Body.String())
+
+	alert := <-alerts.C
+	require.Equal(t, outputs.AlertEventType, alert.EventType)
+	require.Equal(t, "default", alert.ClusterName)
+	require.Equal(t, "block-ls", alert.OutputFields["PolicyName"])
+	require.Equal(t, "wordpress-mysql", alert.OutputFields["NamespaceName"])
+	alert = <-alerts.C
+	require.Equal(t, "block-ls", alert.OutputFields["PolicyName"])
+
+	require.Equal(t, "4", stats.Requests.Get(outputs.Total).String())
+	require.Equal(t, "2", stats.Requests.Get(outputs.Accepted).String())
+	require.Equal(t, "2", stats.Requests.Get(outputs.Rejected).String())
+
+	w = httptest.NewRecorder()
+	eventsHandler(w, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`[{"EventType": "Alert", "Detail": {}}, {"EventType": "Unknown", "Detail": {}}]`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"accepted": 1, "rejected": 1, "errors": ["unknown EventType 'Unknown'"]}`, w.Body.String())
+	<-alerts.C
+
+	w = httptest.NewRecorder()
+	eventsHandler(w, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"EventType": `)))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	eventsHandler(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
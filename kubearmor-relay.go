@@ -2,9 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"expvar"
 	"fmt"
 	"net"
 	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/kubearmor/KubeArmor/protobuf"
@@ -12,69 +19,206 @@ import (
 	"github.com/kubearmor/sidekick/types"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
-func GetLogsFromKubearmorRelay() {
+// Filters of the relay streams
+const (
+	RelayFilterAll    string = "all"
+	RelayFilterPolicy string = "policy"
+	RelayFilterSystem string = "system"
+	// RelayFilterNone disables the stream
+	RelayFilterNone string = "none"
+)
+
+// relayBackoff is the delay between two connections to a relay
+var relayBackoff = outputs.Backoff{Base: time.Second, Max: time.Minute, Jitter: 0.5}
+
+// relayStream supervises the streams from one relay.
+type relayStream struct {
+	relay types.RelayConfig
+	// connected reports whether the streams are up, it is used by the
+	// readiness probe.
+	connected atomic.Bool
+	stats     *expvar.Map
+}
+
+func newRelayStreams(relays []types.RelayConfig, stats *types.Statistics) []*relayStream {
+	r := make([]*relayStream, 0, len(relays))
+	for _, relay := range relays {
+		r = append(r, &relayStream{relay: relay, stats: stats.Relays[relay.Name]})
+	}
+	return r
+}
+
+// GetLogsFromKubearmorRelay streams the alerts and logs from the relays to the
+// outputs, every relay with its own supervised connection, until the context
+// is canceled. It returns once the streams are closed and the events left in
+// the buffers are handed to the outputs.
+func GetLogsFromKubearmorRelay(ctx context.Context) {
+	// the buffers are flushed once the streams stop filling them
+	buffersCtx, flushBuffers := context.WithCancel(context.Background())
+	var buffers sync.WaitGroup
 	lc := outputs.Client{}
-	var err error
-	//get url
-	//connect to url
-	//conn := ConnKubeArmorRelay(url, "32767")
-	conn, err := ConnectRetry(func() (*grpc.ClientConn, error) {
-		url := GetKubearmorRelayURL()
-		return ConnKubeArmorRelay(url, "32767")
-	}, 6, 10*time.Second)
-	if conn == nil || err != nil {
-		fmt.Println("Unable to Connect to Relay Server.|", err, "| Shutting down......")
-		os.Exit(1)
+	buffers.Add(1)
+	go func() {
+		defer buffers.Done()
+		lc.AddAlertFromBuffChan(buffersCtx)
+	}()
+	if config.Log {
+		buffers.Add(1)
+		go func() {
+			defer buffers.Done()
+			lc.AddLogFromBuffChan(buffersCtx)
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range relayStreams {
+		wg.Add(1)
+		go func(r *relayStream) {
+			defer wg.Done()
+			r.supervise(ctx)
+		}(r)
+	}
+	wg.Wait()
+
+	flushBuffers()
+	buffers.Wait()
+}
+
+// supervise keeps the streams from the relay up. When they fail, the relay is
+// discovered and dialed again with an exponential backoff, the outputs keep
+// running meanwhile.
+func (r *relayStream) supervise(ctx context.Context) {
+	for attempt := 0; ; {
+		connected, err := r.watch(ctx, attempt > 0)
+		r.setConnected(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			// the streams were up, the next failure starts a new backoff
+			attempt = 0
+		}
+		attempt++
+		delay := relayBackoff.Delay(attempt)
+		log.Error().Msgf("Relay %v streams failed: %v, reconnecting in %v", r.relay.Name, err, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// watch connects to the relay and reads the streams until one of them fails.
+// It reports whether the streams were up, with the error which ended them.
+func (r *relayStream) watch(ctx context.Context, reconnect bool) (bool, error) {
+	relay := r.relay
+	conn, err := dialKubearmorRelay(ctx, relay)
+	if reconnect {
+		r.countReconnect(err)
 	}
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	lc.Conn = conn
-	//create log and alert watcher
+	lc := outputs.Client{
+		Conn:        conn,
+		RelayName:   relay.Name,
+		ClusterName: relay.ClusterName,
+		Stats:       stats,
+		PromStats:   promStats,
+		RelayStats:  r.stats,
+	}
 	client := pb.NewLogServiceClient(conn)
-	alertreq := pb.RequestMessage{}
-	alertreq.Filter = "all"
 
-	lc.AlertStream, err = client.WatchAlerts(context.Background(), &alertreq)
-	if err != nil {
-		log.Error().Msg("unable to stream systems logs: " + err.Error())
-		return
+	watchAlerts := relay.AlertFilter != RelayFilterNone
+	// the log stream is not read when logs are disabled
+	watchLogs := config.Log && relay.LogFilter != RelayFilterNone
+
+	if watchAlerts {
+		lc.AlertStream, err = client.WatchAlerts(ctx, &pb.RequestMessage{Filter: relay.AlertFilter})
+		if err != nil {
+			return false, fmt.Errorf("unable to stream alerts: %w", err)
+		}
+	}
+	if watchLogs {
+		lc.LogStream, err = client.WatchLogs(ctx, &pb.RequestMessage{Filter: relay.LogFilter})
+		if err != nil {
+			return false, fmt.Errorf("unable to stream logs: %w", err)
+		}
 	}
+	r.setConnected(true)
 
-	//create a buffer to accept alerts
-	lc.WgServer.Add(1)
-	go lc.WatchAlerts()
-	go lc.AddAlertFromBuffChan()
+	errs := make(chan error, 2)
+	if watchAlerts {
+		lc.WgServer.Add(1)
+		go func() { errs <- lc.WatchAlerts() }()
+	}
+	if watchLogs {
+		lc.WgServer.Add(1)
+		go func() { errs <- lc.WatchLogs() }()
+	}
 
-	logreq := pb.RequestMessage{}
-	logreq.Filter = "all"
+	// the first stream to fail cancels the other one
+	err = <-errs
+	cancel()
+	lc.WgServer.Wait()
+
+	return true, err
+}
 
-	lc.LogStream, err = client.WatchLogs(context.Background(), &logreq)
+func dialKubearmorRelay(ctx context.Context, relay types.RelayConfig) (*grpc.ClientConn, error) {
+	creds, err := relayCredentials(relay)
 	if err != nil {
-		log.Error().Msg("unable to stream systems logs: " + err.Error())
-		return
+		return nil, err
 	}
-	lc.WgServer.Add(1)
-	//create a buffer to accept logs
-	go lc.WatchLogs()
-	go lc.AddLogFromBuffChan()
+	url, err := relayHost(relay)
+	if err != nil {
+		return nil, err
+	}
+	return ConnKubeArmorRelay(ctx, url, strconv.Itoa(relay.Port), creds)
+}
 
-	lc.WgServer.Wait()
+func (r *relayStream) setConnected(connected bool) {
+	r.connected.Store(connected)
 
-	if err := lc.DestroyClient(); err != nil {
-		fmt.Println("Failed to destroy the grpc client")
+	var v int64
+	if connected {
+		v = 1
 	}
+	c := new(expvar.Int)
+	c.Set(v)
+	r.stats.Set("connected", c)
+	promStats.RelayConnected.With(map[string]string{"relay": r.relay.Name}).Set(float64(v))
+}
 
+func (r *relayStream) countReconnect(err error) {
+	status := outputs.OK
+	if err != nil {
+		status = outputs.Error
+	}
+	r.stats.Add("reconnects", 1)
+	promStats.RelayReconnects.With(map[string]string{"relay": r.relay.Name, "status": status}).Inc()
 }
 
-func GetKubearmorRelayURL() string {
-	client := ConnectK8sClient()
-	if client == nil {
-		log.Error().Msg("error is: Unable to create k8s client")
+// GetKubearmorRelayURL returns the IP of a running relay pod, found with the
+// Kubernetes API.
+func GetKubearmorRelayURL(kubeconfig string) string {
+	client, err := ConnectK8sClient(kubeconfig)
+	if err != nil {
+		log.Error().Msg("Unable to create k8s client: " + err.Error())
 		return ""
 	}
 	pods, err := client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{
@@ -102,38 +246,79 @@ func GetKubearmorRelayURL() string {
 	return ""
 }
 
-func ConnectK8sClient() *kubernetes.Clientset {
-	config, _ := rest.InClusterConfig()
-	clientset, _ := kubernetes.NewForConfig(config)
+// ConnectK8sClient returns a Kubernetes client using the in-cluster
+// configuration, or the kubeconfig file when running out of the cluster.
+func ConnectK8sClient(kubeconfig string) (*kubernetes.Clientset, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		restConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
 
-	return clientset
+// relayHost returns the host of the relay: the configured address, the DNS
+// name of the configured Service, or the IP of a discovered relay pod.
+func relayHost(relay types.RelayConfig) (string, error) {
+	switch {
+	case relay.Address != "":
+		return relay.Address, nil
+	case relay.Service != "":
+		return fmt.Sprintf("%s.%s.svc", relay.Service, relay.Namespace), nil
+	}
+	url := GetKubearmorRelayURL(relay.Kubeconfig)
+	if url == "" {
+		return "", errors.New("unable to find the relay")
+	}
+	return url, nil
 }
 
-func ConnectRetry(fn func() (*grpc.ClientConn, error), maxRetries int, delay time.Duration) (*grpc.ClientConn, error) {
-	var conn *grpc.ClientConn
-	var err error
+// relayCredentials returns the transport credentials of the connection to the relay
+func relayCredentials(relay types.RelayConfig) (credentials.TransportCredentials, error) {
+	if !relay.TLS {
+		return insecure.NewCredentials(), nil
+	}
 
-	for i := 0; i < maxRetries; i++ {
-		conn, err = fn()
-		if err == nil {
-			return conn, nil // Success
+	tlsConfig := &tls.Config{
+		ServerName: relay.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if !relay.CheckCert {
+		// #nosec G402 This is only set as a result of explicit configuration
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if relay.CaCertFile != "" {
+		caCert, err := os.ReadFile(relay.CaCertFile)
+		if err != nil {
+			return nil, err
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificate found in %v", relay.CaCertFile)
 		}
-		log.Info().Msgf("Retry attempt %d failed with error: %s", i+1, err)
-		time.Sleep(delay)
+		tlsConfig.RootCAs = caCertPool
 	}
-
-	return nil, fmt.Errorf("after %d attempts, last error: %s", maxRetries, err)
+	if relay.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(relay.CertFile, relay.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
 }
 
-func ConnKubeArmorRelay(url string, port string) (*grpc.ClientConn, error) {
+func ConnKubeArmorRelay(ctx context.Context, url string, port string, creds credentials.TransportCredentials) (*grpc.ClientConn, error) {
 	addr := net.JoinHostPort(url, port)
 	log.Info().Msg(fmt.Sprint("url is ", url))
 
 	// Check for kubearmor-relay with 30s timeout
-	ctx, cf1 := context.WithTimeout(context.Background(), time.Second*30)
+	ctx, cf1 := context.WithTimeout(ctx, time.Second*30)
 	defer cf1()
 	// Blocking grpc Dial: in case of a bad connection, fails with timeout
-	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(creds), grpc.WithBlock())
 	if err != nil {
 		log.Error().Msg("Error connecting kubearmor relay: " + err.Error())
 		return nil, err
@@ -143,263 +328,8 @@ func ConnKubeArmorRelay(url string, port string) (*grpc.ClientConn, error) {
 	return conn, nil
 }
 
-func createReceiveBuffer() {
-	KubearmorPayload := types.KubearmorPayload{}
-
-	if config.PolicyReport.Enabled {
-		go policyReportClient.WatchPolicyAlerts()
-	}
-
-	//done
-	if config.Slack.WebhookURL != "" {
-		go slackClient.WatchSlackAlerts()
-		go slackClient.WatchSlackLogs()
-	}
-
-	if config.Cliq.WebhookURL != "" {
-		go cliqClient.WatchCliqPostAlerts()
-		go cliqClient.WatchCliqPostLogs()
-	}
-
-	if config.Rocketchat.WebhookURL != "" {
-		go rocketchatClient.WatchRocketchatPostAlerts()
-		go rocketchatClient.WatchRocketchatPostLogs()
-	}
-
-	if config.Mattermost.WebhookURL != "" {
-		go mattermostClient.MattermostPost(KubearmorPayload)
-	}
-
-	if config.Teams.WebhookURL != "" {
-		go teamsClient.WatchTeamsPostAlerts()
-		go teamsClient.WatchTeamsPostLogs()
-	}
-
-	if config.Datadog.APIKey != "" {
-		go datadogClient.WatchDatadogPostLogs()
-		go datadogClient.WatchDatadogPostAlerts()
-	}
-
-	if config.Discord.WebhookURL != "" {
-		go discordClient.WatchDiscordAlerts()
-		go discordClient.WatchDiscordLogs()
-	}
-
-	//done
-	if config.Alertmanager.HostPort != "" {
-		go alertmanagerClient.WatchAlertmanagerPostAlerts()
-		go alertmanagerClient.WatchLogmanagerPostAlerts()
-	}
-
-	if config.Elasticsearch.HostPort != "" {
-		go elasticsearchClient.WatchElasticsearchPostLogs()
-		go elasticsearchClient.WatchElasticsearchPostAlerts()
-	}
-
-	if config.Influxdb.HostPort != "" {
-		go influxdbClient.WatchInfluxdbPostAlerts()
-		go influxdbClient.WatchInfluxdbPostLogs()
-	}
-
-	if config.Loki.HostPort != "" {
-		go lokiClient.LokiPost(KubearmorPayload)
-	}
-
-	if config.Nats.HostPort != "" {
-		go natsClient.WatchNatsPublishAlerts()
-		go natsClient.WatchNatsPublishLogs()
-	}
-
-	if config.Stan.HostPort != "" && config.Stan.ClusterID != "" && config.Stan.ClientID != "" {
-		go stanClient.StanPublish(KubearmorPayload)
-	}
-
-	if config.AWS.Lambda.FunctionName != "" {
-		go awsClient.WatchInvokeLambdaAlerts()
-		go awsClient.WatchInvokeLambdaLogs()
-	}
-
-	if config.AWS.SQS.URL != "" {
-		go awsClient.WatchSendMessageAlerts()
-		go awsClient.WatchSendMessageLogs()
-	}
-
-	if config.AWS.SNS.TopicArn != "" {
-		go awsClient.WatchPublishTopicAlerts()
-		go awsClient.WatchPublishTopicLogs()
-	}
-
-	if config.AWS.CloudWatchLogs.LogGroup != "" {
-		go awsClient.WatchSendCloudWatchLogAlerts()
-		go awsClient.WatchSendCloudWatchLogLogs()
-	}
-
-	if config.AWS.S3.Bucket != "" {
-		go awsClient.WatchUploadS3Alerts()
-		go awsClient.WatchUploadS3Logs()
-	}
-
-	if config.AWS.SecurityLake.Bucket != "" && config.AWS.SecurityLake.Region != "" && config.AWS.SecurityLake.AccountID != "" && config.AWS.SecurityLake.Prefix != "" {
-		go awsClient.WatchEnqueueSecurityLakeAlerts()
-		go awsClient.WatchEnqueueSecurityLakeLogs()
-	}
-
-	if config.AWS.Kinesis.StreamName != "" {
-		go awsClient.WatchPutRecordAlerts()
-		go awsClient.WatchPutRecordLogs()
-	}
-
-	if config.SMTP.HostPort != "" {
-		go smtpClient.WatchSendMailAlerts()
-		go smtpClient.WatchSendMailLogs()
-	}
-
-	if config.Opsgenie.APIKey != "" {
-		go opsgenieClient.OpsgeniePost(KubearmorPayload)
-	}
-
-	if config.Webhook.Address != "" {
-		go webhookClient.WebhookPost(KubearmorPayload)
-	}
-
-	if config.NodeRed.Address != "" {
-		go noderedClient.NodeRedPost(KubearmorPayload)
-	}
-
-	if config.CloudEvents.Address != "" {
-		go cloudeventsClient.WatchCloudEventsSendAlerts()
-		go cloudeventsClient.WatchCloudEventsSendLogs()
-
-	}
-
-	if config.Azure.EventHub.Name != "" {
-		go azureClient.WatchEventHubPostlerts()
-		go azureClient.WatchEventHubPostLogs()
-	}
-
-	if config.GCP.PubSub.ProjectID != "" && config.GCP.PubSub.Topic != "" {
-		go gcpClient.GCPPublishTopic(KubearmorPayload)
-	}
-
-	if config.GCP.CloudFunctions.Name != "" {
-		go gcpClient.GCPCallCloudFunction(KubearmorPayload)
-	}
-
-	if config.GCP.CloudRun.Endpoint != "" {
-		go gcpCloudRunClient.CloudRunFunctionPost(KubearmorPayload)
-	}
-
-	if config.GCP.Storage.Bucket != "" {
-		go gcpClient.UploadGCS(KubearmorPayload)
-	}
-
-	if config.Googlechat.WebhookURL != "" {
-		go googleChatClient.GooglechatPost(KubearmorPayload)
-	}
-
-	if config.Kafka.HostPort != "" {
-		go kafkaClient.WatchKafkaProduceAlerts()
-		go kafkaClient.WatchKafkaProduceLogs()
-	}
-
-	if config.KafkaRest.Address != "" {
-		go kafkaRestClient.KafkaRestPost(KubearmorPayload)
-	}
-
-	if config.Pagerduty.RoutingKey != "" {
-		go pagerdutyClient.PagerdutyPost(KubearmorPayload)
-	}
-
-	if config.Kubeless.Namespace != "" && config.Kubeless.Function != "" {
-		go kubelessClient.KubelessCall(KubearmorPayload)
-	}
-
-	if config.Openfaas.FunctionName != "" {
-		go openfaasClient.OpenfaasCall(KubearmorPayload)
-	}
-
-	if config.Tekton.EventListener != "" {
-		go tektonClient.TektonPost(KubearmorPayload)
-	}
-	//done
-	if config.Rabbitmq.URL != "" && config.Rabbitmq.Queue != "" {
-		go rabbitmqClient.WatchRabbitmqPublishAlerts()
-	}
-
-	if config.Wavefront.EndpointHost != "" && config.Wavefront.EndpointType != "" {
-		go wavefrontClient.WavefrontPost(KubearmorPayload)
-	}
-
-	if config.Grafana.HostPort != "" {
-		go grafanaClient.WatchGrafanaPostAlerts()
-		go grafanaClient.WatchGrafanaPostLogs()
-
-	}
-
-	if config.GrafanaOnCall.WebhookURL != "" {
-		go grafanaOnCallClient.WatchGrafanaOnCallPostAlerts()
-		go grafanaOnCallClient.WatchGrafanaOnCallPostLogs()
-	}
-
-	if config.WebUI.URL != "" {
-		go webUIClient.WebUIPost(KubearmorPayload)
-	}
-
-	if config.Fission.Function != "" {
-		go fissionClient.FissionCall(KubearmorPayload)
-	}
-
-	if config.Yandex.S3.Bucket != "" {
-		go yandexClient.UploadYandexS3(KubearmorPayload)
-	}
-
-	if config.Yandex.DataStreams.StreamName != "" {
-		go yandexClient.UploadYandexDataStreams(KubearmorPayload)
-	}
-
-	fmt.Println("before Syslog -> ", config.Syslog.Host)
-	if config.Syslog.Host != "" {
-		fmt.Println("Syslog -> ", config.Syslog.Host)
-		go syslogClient.WatchSyslogsAlerts()
-		go syslogClient.WatchSyslogLogs()
-	}
-
-	if config.MQTT.Broker != "" {
-		go mqttClient.WatchMQTTPublishAlerts()
-		go mqttClient.WatchMQTTPublishLogs()
-	}
-
-	if config.Zincsearch.HostPort != "" {
-		go zincsearchClient.ZincsearchPost(KubearmorPayload)
-	}
-
-	if config.Gotify.HostPort != "" {
-		go gotifyClient.GotifyPost(KubearmorPayload)
-	}
-
-	if config.Spyderbat.OrgUID != "" {
-		go spyderbatClient.SpyderbatPost(KubearmorPayload)
-	}
-
-	if config.TimescaleDB.Host != "" {
-		go timescaleDBClient.WatchTimescaleDBPostAlerts()
-		go timescaleDBClient.WatchTimescaleDBPostLogs()
-	}
-
-	if config.Redis.Address != "" {
-		go redisClient.WatchRedisPostAlerts()
-		go redisClient.WatchRedisPostLogs()
-	}
-
-	if config.Telegram.ChatID != "" {
-		go telegramClient.TelegramPost(KubearmorPayload)
-	}
-
-	if config.N8N.Address != "" {
-		go n8nClient.N8NPost(KubearmorPayload)
-	}
-
-	if config.OpenObserve.HostPort != "" {
-		go openObserveClient.OpenObservePost(KubearmorPayload)
+func createReceiveBuffer(ctx context.Context) {
+	for _, o := range enabledOutputs {
+		dispatcher.Dispatch(ctx, o)
 	}
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestRelayHost(t *testing.T) {
+	host, err := relayHost(types.RelayConfig{Address: "10.0.0.1", Service: "kubearmor"})
+	require.Nil(t, err)
+	require.Equal(t, "10.0.0.1", host)
+
+	host, err = relayHost(types.RelayConfig{Service: "kubearmor", Namespace: "kubearmor"})
+	require.Nil(t, err)
+	require.Equal(t, "kubearmor.kubearmor.svc", host)
+}
+
+func TestRelayCredentials(t *testing.T) {
+	creds, err := relayCredentials(types.RelayConfig{})
+	require.Nil(t, err)
+	require.Equal(t, "insecure", creds.Info().SecurityProtocol)
+
+	creds, err = relayCredentials(types.RelayConfig{TLS: true, ServerName: "kubearmor"})
+	require.Nil(t, err)
+	require.Equal(t, "tls", creds.Info().SecurityProtocol)
+	require.Equal(t, "kubearmor", creds.Info().ServerName)
+
+	_, err = relayCredentials(types.RelayConfig{TLS: true, CaCertFile: "/nonexistent/ca.crt"})
+	require.NotNil(t, err)
+
+	_, err = relayCredentials(types.RelayConfig{TLS: true, CertFile: "/nonexistent/client.crt", KeyFile: "/nonexistent/client.key"})
+	require.NotNil(t, err)
+}
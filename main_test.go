@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/outputs"
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestShutdown(t *testing.T) {
+	outputs.Initvariable(types.BuffersConfig{})
+	dispatcher = outputs.NewDispatcher(&types.Configuration{}, &types.Statistics{}, &types.PromStatistics{})
+	defer func() { dispatcher = nil }()
+
+	inputs := make(chan struct{})
+	close(inputs)
+	stopped := false
+	require.Nil(t, shutdown(context.Background(), nil, inputs, func() { stopped = true }))
+	require.True(t, stopped)
+
+	// the inputs never stop, the drain can't complete
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, shutdown(ctx, nil, make(chan struct{}), func() {}), context.DeadlineExceeded)
+}
@@ -3,11 +3,34 @@ package outputs
 import (
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "AlertManager",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Alertmanager.HostPort != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Alertmanager.MinimumPriority },
+		New:             newAlertmanagerClient,
+		Send:            (*Client).AlertmanagerPost,
+	})
+}
+
+func newAlertmanagerClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	c, err := NewClient("AlertManager", config.Alertmanager.HostPort+config.Alertmanager.Endpoint, config.Alertmanager.MutualTLS, config.Alertmanager.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+	if err != nil {
+		return nil, err
+	}
+	c.alertmanagerRates = newEventRates(time.Duration(config.Alertmanager.DropEventWindow) * time.Second)
+	return c, nil
+}
+
 type alertmanagerPayload struct {
 	Labels      map[string]string `json:"labels,omitempty"`
 	Annotations map[string]string `json:"annotations,omitempty"`
@@ -25,7 +48,85 @@ var defaultSeverityMap = map[types.PriorityType]string{
 	types.Emergency:     "critical",
 }
 
-func newAlertmanagerPayload(KubearmorPayload types.KubearmorPayload, config *types.Configuration) []alertmanagerPayload {
+// eventRates counts the events by key over fixed windows
+type eventRates struct {
+	window time.Duration
+	lock   sync.Mutex
+	counts map[string]*eventRate
+	// swept is when the windows closed were last removed
+	swept time.Time
+}
+
+type eventRate struct {
+	start time.Time
+	count int64
+}
+
+func newEventRates(window time.Duration) *eventRates {
+	return &eventRates{window: window, counts: make(map[string]*eventRate), swept: time.Now()}
+}
+
+// Add counts an event of the key and returns the number of events of the key
+// in the current window, the event included.
+func (r *eventRates) Add(key string, now time.Time) int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if now.Sub(r.swept) >= r.window {
+		for k, rate := range r.counts {
+			if now.Sub(rate.start) >= r.window {
+				delete(r.counts, k)
+			}
+		}
+		r.swept = now
+	}
+	rate, ok := r.counts[key]
+	if !ok || now.Sub(rate.start) >= r.window {
+		rate = &eventRate{start: now}
+		r.counts[key] = rate
+	}
+	rate.count++
+	return rate.count
+}
+
+// alertmanagerRateKey returns the policy and the pod of an alert, whose
+// alerts are counted together. The logs have no policy, they are counted
+// apart, by operation and pod.
+func alertmanagerRateKey(kubearmorpayload types.KubearmorPayload) string {
+	fields := kubearmorpayload.OutputFields
+	if kubearmorpayload.EventType == LogEventType {
+		return fmt.Sprintf("log/%v/%v/%v", fields["Operation"], fields["NamespaceName"], fields["PodName"])
+	}
+	return fmt.Sprintf("alert/%v/%v/%v", fields["PolicyName"], fields["NamespaceName"], fields["PodName"])
+}
+
+// alertmanagerPriority returns the priority of the highest threshold the
+// number of events of the policy and the pod reached in the window, false if
+// it is under the lowest threshold and the event is dropped. The priority is
+// DropEventDefaultPriority without thresholds.
+func alertmanagerPriority(config types.AlertmanagerOutputConfig, count int64) (types.PriorityType, bool) {
+	if len(config.DropEventThresholdsList) == 0 {
+		return types.Priority(config.DropEventDefaultPriority), true
+	}
+	// the thresholds are sorted in descending order
+	for _, threshold := range config.DropEventThresholdsList {
+		if count >= threshold.Value {
+			return threshold.Priority, true
+		}
+	}
+	return types.Default, false
+}
+
+// alertmanagerSeverity returns the severity label of the priority, from
+// CustomSeverityMap or else the default mapping.
+func alertmanagerSeverity(config types.AlertmanagerOutputConfig, priority types.PriorityType) string {
+	if severity, ok := config.CustomSeverityMap[priority]; ok {
+		return severity
+	}
+	return defaultSeverityMap[priority]
+}
+
+func newAlertmanagerPayload(KubearmorPayload types.KubearmorPayload, priority types.PriorityType, config *types.Configuration) []alertmanagerPayload {
 	var amPayload alertmanagerPayload
 	amPayload.Labels = make(map[string]string)
 	amPayload.Annotations = make(map[string]string)
@@ -43,6 +144,8 @@ func newAlertmanagerPayload(KubearmorPayload types.KubearmorPayload, config *typ
 	}
 
 	amPayload.Labels["source"] = "Kubearmor"
+	amPayload.Labels["priority"] = priority.String()
+	amPayload.Labels["severity"] = alertmanagerSeverity(config.Alertmanager, priority)
 
 	if config.Alertmanager.ExpiresAfter != 0 {
 		timestamp := time.Unix(KubearmorPayload.Timestamp, 0)
@@ -62,66 +165,35 @@ func newAlertmanagerPayload(KubearmorPayload types.KubearmorPayload, config *typ
 	return a
 }
 
-// AlertmanagerPost posts event to AlertManager
-func (c *Client) AlertmanagerPost(kubearmorpayload types.KubearmorPayload) {
+// AlertmanagerPost posts event to AlertManager, with the priority reached by
+// the rate of the events of its policy and pod. The events under the lowest
+// threshold are dropped.
+func (c *Client) AlertmanagerPost(kubearmorpayload types.KubearmorPayload) error {
+	count := c.alertmanagerRates.Add(alertmanagerRateKey(kubearmorpayload), time.Now())
+	priority, ok := alertmanagerPriority(c.Config.Alertmanager, count)
+	if !ok {
+		c.Stats.Alertmanager.Add(Filtered, 1)
+		c.PromStats.Outputs.With(map[string]string{"destination": "alertmanager", "status": Filtered}).Inc()
+		if c.Config.Debug {
+			log.Printf("[DEBUG] : AlertManager - Event dropped, %v events of %v in the window\n", count, alertmanagerRateKey(kubearmorpayload))
+		}
+		return nil
+	}
+
 	c.Stats.Alertmanager.Add(Total, 1)
 
-	err := c.Post(newAlertmanagerPayload(kubearmorpayload, c.Config))
+	err := c.Post(newAlertmanagerPayload(kubearmorpayload, priority, c.Config))
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:alertmanager", "status:error"})
 		c.Stats.Alertmanager.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "alertmanager", "status": Error}).Inc()
 		log.Printf("[ERROR] : AlertManager - %v\n", err)
-		return
+		return err
 	}
 
 	go c.CountMetric(Outputs, 1, []string{"output:alertmanager", "status:ok"})
 	c.Stats.Alertmanager.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "alertmanager", "status": OK}).Inc()
-}
-
-func (c *Client) WatchAlertmanagerPostAlerts() error {
-	uid := "Alertmaneger"
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.AlertmanagerPost(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchLogmanagerPostAlerts() error {
-	uid := "Alertmaneger"
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.AlertmanagerPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
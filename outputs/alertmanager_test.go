@@ -2,10 +2,15 @@ package outputs
 
 import (
 	"encoding/json"
+	"expvar"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 
 	"github.com/kubearmor/sidekick/types"
@@ -50,7 +55,9 @@ func TestNewAlertmanagerPayloadO(t *testing.T) {
 				"Timestamp": "1631542902",
 				"UID": "1001",
 				"UpdatedTime": "2023-09-13T15:35:02Z",
-				"source": "Kubearmor"
+				"source": "Kubearmor",
+				"priority": "Warning",
+				"severity": "warning"
 			},
 			"Annotations": null,
 			"EndsAt": "0001-01-01T00:00:00Z"
@@ -69,7 +76,7 @@ func TestNewAlertmanagerPayloadO(t *testing.T) {
 	}
 	json.Unmarshal([]byte(defaultThresholds), &config.Alertmanager.DropEventThresholdsList)
 
-	s, err := json.Marshal(newAlertmanagerPayload(f, config))
+	s, err := json.Marshal(newAlertmanagerPayload(f, types.Warning, config))
 	require.Nil(t, err)
 
 	var o1, o2 []alertmanagerPayload
@@ -78,3 +85,76 @@ func TestNewAlertmanagerPayloadO(t *testing.T) {
 	fmt.Println(o2)
 	require.Equal(t, o1, o2)
 }
+
+func TestAlertmanagerPriority(t *testing.T) {
+	config := types.AlertmanagerOutputConfig{DropEventDefaultPriority: "error"}
+	p, ok := alertmanagerPriority(config, 1)
+	require.True(t, ok)
+	require.Equal(t, types.PriorityType(types.Error), p, "the default priority is used without thresholds")
+
+	require.Nil(t, json.Unmarshal([]byte(`[{"priority":"critical", "value":100}, {"priority":"warning", "value":10}, {"priority":"notice", "value":5}]`), &config.DropEventThresholdsList))
+	for count, priority := range map[int64]types.PriorityType{1000: types.Critical, 100: types.Critical, 99: types.Warning, 10: types.Warning, 5: types.Notice} {
+		p, ok := alertmanagerPriority(config, count)
+		require.True(t, ok)
+		require.Equal(t, priority, p, count)
+	}
+	_, ok = alertmanagerPriority(config, 4)
+	require.False(t, ok, "the events under the lowest threshold are dropped")
+
+	config.CustomSeverityMap = map[types.PriorityType]string{types.Critical: "page"}
+	require.Equal(t, "page", alertmanagerSeverity(config, types.Critical))
+	require.Equal(t, "warning", alertmanagerSeverity(config, types.Warning))
+}
+
+func TestEventRates(t *testing.T) {
+	r := newEventRates(time.Minute)
+	now := time.Now()
+	require.Equal(t, int64(1), r.Add("a", now))
+	require.Equal(t, int64(2), r.Add("a", now.Add(time.Second)))
+	require.Equal(t, int64(1), r.Add("b", now.Add(time.Second)))
+	require.Equal(t, int64(1), r.Add("a", now.Add(time.Minute)), "a new window starts once the previous one is over")
+	r.Add("c", now.Add(3*time.Minute))
+	require.Len(t, r.counts, 1, "the windows over are removed")
+}
+
+func TestAlertmanagerPostDrop(t *testing.T) {
+	var labels []map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload []alertmanagerPayload
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&payload))
+		labels = append(labels, payload[0].Labels)
+	}))
+	defer ts.Close()
+
+	config := &types.Configuration{Alertmanager: types.AlertmanagerOutputConfig{
+		HostPort:                ts.URL,
+		DropEventWindow:         60,
+		DropEventThresholdsList: []types.ThresholdConfig{{Value: 3, Priority: types.Critical}, {Value: 2, Priority: types.Warning}},
+		CustomSeverityMap:       map[types.PriorityType]string{types.Critical: "page"},
+	}}
+	stats := &types.Statistics{Alertmanager: new(expvar.Map).Init()}
+	promStats := &types.PromStatistics{Outputs: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_outputs"}, []string{"destination", "status"})}
+	c, err := newAlertmanagerClient(config, stats, promStats, nil, nil)
+	require.Nil(t, err)
+
+	event := types.KubearmorPayload{OutputFields: map[string]interface{}{"PolicyName": "ksp", "NamespaceName": "default", "PodName": "pod"}}
+	for i := 0; i < 3; i++ {
+		require.Nil(t, c.AlertmanagerPost(event))
+	}
+	require.Len(t, labels, 2, "the first event is under the lowest threshold")
+	require.Equal(t, "warning", labels[0]["severity"])
+	require.Equal(t, "page", labels[1]["severity"])
+	require.Equal(t, "1", stats.Alertmanager.Get(Filtered).String())
+
+	// the logs of the pod are counted apart from its alerts, by operation
+	logEvent := types.KubearmorPayload{EventType: LogEventType, OutputFields: map[string]interface{}{"Operation": "Process", "NamespaceName": "default", "PodName": "pod"}}
+	require.Nil(t, c.AlertmanagerPost(logEvent))
+	require.Len(t, labels, 2, "the first log is under the lowest threshold")
+	require.Nil(t, c.AlertmanagerPost(logEvent))
+	require.Len(t, labels, 3)
+	require.Equal(t, "warning", labels[2]["severity"])
+	logEvent.OutputFields = map[string]interface{}{"Operation": "File", "NamespaceName": "default", "PodName": "pod"}
+	require.Nil(t, c.AlertmanagerPost(logEvent))
+	require.Len(t, labels, 3, "the logs of another operation are counted apart")
+	require.Equal(t, "3", stats.Alertmanager.Get(Filtered).String())
+}
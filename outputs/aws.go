@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
@@ -29,6 +30,59 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "AWSLambda",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.AWS.Lambda.FunctionName != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.AWS.Lambda.MinimumPriority },
+		New:             NewAWSClient,
+		Send:            (*Client).InvokeLambda,
+	})
+	RegisterOutput(Registration{
+		Name:            "AWSSQS",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.AWS.SQS.URL != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.AWS.SQS.MinimumPriority },
+		New:             NewAWSClient,
+		Send:            (*Client).SendMessage,
+	})
+	RegisterOutput(Registration{
+		Name:            "AWSSNS",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.AWS.SNS.TopicArn != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.AWS.SNS.MinimumPriority },
+		New:             NewAWSClient,
+		Send:            (*Client).PublishTopic,
+	})
+	RegisterOutput(Registration{
+		Name:            "AWSCloudWatchLogs",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.AWS.CloudWatchLogs.LogGroup != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.AWS.CloudWatchLogs.MinimumPriority },
+		New:             NewAWSClient,
+		Send:            (*Client).SendCloudWatchLog,
+		SendBatch:       (*Client).SendCloudWatchLogs,
+	})
+	RegisterOutput(Registration{
+		Name:            "AWSS3",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.AWS.S3.Bucket != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.AWS.S3.MinimumPriority },
+		New:             NewAWSClient,
+		Send:            (*Client).UploadS3,
+	})
+	RegisterOutput(Registration{
+		Name:            "AWSKinesis",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.AWS.Kinesis.StreamName != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.AWS.Kinesis.MinimumPriority },
+		New:             NewAWSClient,
+		Send:            (*Client).PutRecord,
+		SendBatch:       (*Client).PutRecords,
+	})
+}
+
 // NewAWSClient returns a new output.Client for accessing the AWS API.
 func NewAWSClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 	var region string
@@ -117,7 +171,7 @@ func NewAWSClient(config *types.Configuration, stats *types.Statistics, promStat
 }
 
 // InvokeLambda invokes a lambda function
-func (c *Client) InvokeLambda(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) InvokeLambda(kubearmorpayload types.KubearmorPayload) error {
 	svc := lambda.New(c.AWSSession)
 
 	f, _ := json.Marshal(kubearmorpayload)
@@ -137,7 +191,7 @@ func (c *Client) InvokeLambda(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.AWSLambda.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "awslambda", "status": Error}).Inc()
 		log.Printf("[ERROR] : %v Lambda - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
 	if c.Config.Debug {
@@ -149,10 +203,12 @@ func (c *Client) InvokeLambda(kubearmorpayload types.KubearmorPayload) {
 	go c.CountMetric("outputs", 1, []string{"output:awslambda", "status:ok"})
 	c.Stats.AWSLambda.Add("ok", 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "awslambda", "status": "ok"}).Inc()
+
+	return nil
 }
 
 // SendMessage sends a message to SQS Queue
-func (c *Client) SendMessage(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) SendMessage(kubearmorpayload types.KubearmorPayload) error {
 	svc := sqs.New(c.AWSSession)
 
 	f, _ := json.Marshal(kubearmorpayload)
@@ -170,7 +226,7 @@ func (c *Client) SendMessage(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.AWSSQS.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "awssqs", "status": Error}).Inc()
 		log.Printf("[ERROR] : %v SQS - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
 	if c.Config.Debug {
@@ -181,10 +237,12 @@ func (c *Client) SendMessage(kubearmorpayload types.KubearmorPayload) {
 	go c.CountMetric("outputs", 1, []string{"output:awssqs", "status:ok"})
 	c.Stats.AWSSQS.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "awssqs", "status": "ok"}).Inc()
+
+	return nil
 }
 
 // UploadS3 upload payload to S3
-func (c *Client) UploadS3(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) UploadS3(kubearmorpayload types.KubearmorPayload) error {
 	f, _ := json.Marshal(kubearmorpayload)
 
 	prefix := ""
@@ -204,7 +262,7 @@ func (c *Client) UploadS3(kubearmorpayload types.KubearmorPayload) {
 		go c.CountMetric("outputs", 1, []string{"output:awss3", "status:error"})
 		c.PromStats.Outputs.With(map[string]string{"destination": "awss3", "status": Error}).Inc()
 		log.Printf("[ERROR] : %v S3 - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
 	if resp.SSECustomerAlgorithm != nil {
@@ -215,10 +273,12 @@ func (c *Client) UploadS3(kubearmorpayload types.KubearmorPayload) {
 
 	go c.CountMetric("outputs", 1, []string{"output:awss3", "status:ok"})
 	c.PromStats.Outputs.With(map[string]string{"destination": "awss3", "status": "ok"}).Inc()
+
+	return nil
 }
 
 // PublishTopic sends a message to a SNS Topic
-func (c *Client) PublishTopic(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) PublishTopic(kubearmorpayload types.KubearmorPayload) error {
 	svc := sns.New(c.AWSSession)
 
 	var msg *sns.PublishInput
@@ -261,72 +321,139 @@ func (c *Client) PublishTopic(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.AWSSNS.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "awssns", "status": Error}).Inc()
 		log.Printf("[ERROR] : %v SNS - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
 	log.Printf("[INFO]  : %v SNS - Send to topic OK (%v)\n", c.OutputType, *resp.MessageId)
 	go c.CountMetric("outputs", 1, []string{"output:awssns", "status:ok"})
 	c.Stats.AWSSNS.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "awssns", "status": OK}).Inc()
+
+	return nil
 }
 
 // SendCloudWatchLog sends a message to CloudWatch Log
-func (c *Client) SendCloudWatchLog(kubearmorpayload types.KubearmorPayload) {
-	svc := cloudwatchlogs.New(c.AWSSession)
+func (c *Client) SendCloudWatchLog(kubearmorpayload types.KubearmorPayload) error {
+	rejected, err := c.sendCloudWatchLogs([]types.KubearmorPayload{kubearmorpayload})
+	if err == nil && len(rejected) != 0 {
+		err = rejected[0].Err
+	}
+	return err
+}
 
-	f, _ := json.Marshal(kubearmorpayload)
+// SendCloudWatchLogs sends messages to CloudWatch Log with a single
+// PutLogEvents call, the events CloudWatch rejected are returned in a
+// *BatchError. A failed call is retryable, like the batch it sent.
+func (c *Client) SendCloudWatchLogs(events []types.KubearmorPayload) error {
+	rejected, err := c.sendCloudWatchLogs(events)
+	if err != nil {
+		return &retryableError{err: err}
+	}
+	if len(rejected) != 0 {
+		return &BatchError{Failures: rejected}
+	}
+	return nil
+}
 
-	c.Stats.AWSCloudWatchLogs.Add(Total, 1)
+// sendCloudWatchLogs sends the events sorted by time, as required by
+// PutLogEvents, and returns the events rejected for their time
+func (c *Client) sendCloudWatchLogs(events []types.KubearmorPayload) ([]BatchFailure, error) {
+	svc := cloudwatchlogs.New(c.AWSSession)
 
-	if c.Config.AWS.CloudWatchLogs.LogStream == "" {
-		streamName := "sidekick-logstream"
-		log.Printf("[INFO]  : %v CloudWatchLogs - Log Stream not configured creating one called %s\n", c.OutputType, streamName)
-		inputLogStream := &cloudwatchlogs.CreateLogStreamInput{
-			LogGroupName:  aws.String(c.Config.AWS.CloudWatchLogs.LogGroup),
-			LogStreamName: aws.String(streamName),
-		}
+	c.Stats.AWSCloudWatchLogs.Add(Total, int64(len(events)))
 
-		_, err := svc.CreateLogStream(inputLogStream)
-		if err != nil {
-			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == cloudwatchlogs.ErrCodeResourceAlreadyExistsException {
-				log.Printf("[INFO]  : %v CloudWatchLogs - Log Stream %s already exist, reusing...\n", c.OutputType, streamName)
-			} else {
-				go c.CountMetric("outputs", 1, []string{"output:awscloudwatchlogs", "status:error"})
-				c.Stats.AWSCloudWatchLogs.Add(Error, 1)
-				c.PromStats.Outputs.With(map[string]string{"destination": "awscloudwatchlogs", "status": Error}).Inc()
-				log.Printf("[ERROR] : %v CloudWatchLogs - %v\n", c.OutputType, err.Error())
-				return
-			}
-		}
+	if err := c.createCloudWatchLogStream(svc); err != nil {
+		go c.CountMetric("outputs", int64(len(events)), []string{"output:awscloudwatchlogs", "status:error"})
+		c.Stats.AWSCloudWatchLogs.Add(Error, int64(len(events)))
+		c.PromStats.Outputs.With(map[string]string{"destination": "awscloudwatchlogs", "status": Error}).Add(float64(len(events)))
+		log.Printf("[ERROR] : %v CloudWatchLogs - %v\n", c.OutputType, err.Error())
+		return nil, err
+	}
 
-		c.Config.AWS.CloudWatchLogs.LogStream = streamName
+	order := make([]int, len(events))
+	for i := range order {
+		order[i] = i
 	}
+	sort.SliceStable(order, func(i, j int) bool { return events[order[i]].Timestamp < events[order[j]].Timestamp })
 
-	logevent := &cloudwatchlogs.InputLogEvent{
-		Message:   aws.String(string(f)),
-		Timestamp: aws.Int64(kubearmorpayload.Timestamp / int64(time.Millisecond)),
+	logevents := make([]*cloudwatchlogs.InputLogEvent, len(events))
+	for i, index := range order {
+		f, _ := json.Marshal(events[index])
+		logevents[i] = &cloudwatchlogs.InputLogEvent{
+			Message:   aws.String(string(f)),
+			Timestamp: aws.Int64(time.Unix(events[index].Timestamp, 0).UnixMilli()),
+		}
 	}
 
 	input := &cloudwatchlogs.PutLogEventsInput{
-		LogEvents:     []*cloudwatchlogs.InputLogEvent{logevent},
+		LogEvents:     logevents,
 		LogGroupName:  aws.String(c.Config.AWS.CloudWatchLogs.LogGroup),
 		LogStreamName: aws.String(c.Config.AWS.CloudWatchLogs.LogStream),
 	}
 
-	var err error
 	resp, err := c.putLogEvents(svc, input)
 	if err != nil {
-		go c.CountMetric("outputs", 1, []string{"output:awscloudwatchlogs", "status:error"})
-		c.Stats.AWSCloudWatchLogs.Add(Error, 1)
-		c.PromStats.Outputs.With(map[string]string{"destination": "awscloudwatchlogs", "status": Error}).Inc()
+		go c.CountMetric("outputs", int64(len(events)), []string{"output:awscloudwatchlogs", "status:error"})
+		c.Stats.AWSCloudWatchLogs.Add(Error, int64(len(events)))
+		c.PromStats.Outputs.With(map[string]string{"destination": "awscloudwatchlogs", "status": Error}).Add(float64(len(events)))
 		log.Printf("[ERROR] : %v CloudWatchLogs - %v\n", c.OutputType, err.Error())
-		return
+		return nil, err
+	}
+
+	var rejected []BatchFailure
+	if info := resp.RejectedLogEventsInfo; info != nil {
+		for i, index := range order {
+			var reason string
+			switch {
+			case info.TooOldLogEventEndIndex != nil && int64(i) <= *info.TooOldLogEventEndIndex:
+				reason = "too old"
+			case info.ExpiredLogEventEndIndex != nil && int64(i) <= *info.ExpiredLogEventEndIndex:
+				reason = "expired"
+			case info.TooNewLogEventStartIndex != nil && int64(i) >= *info.TooNewLogEventStartIndex:
+				reason = "too new"
+			default:
+				continue
+			}
+			rejected = append(rejected, BatchFailure{Index: index, Err: fmt.Errorf("log event rejected: %v", reason)})
+		}
+	}
+	if len(rejected) != 0 {
+		go c.CountMetric("outputs", int64(len(rejected)), []string{"output:awscloudwatchlogs", "status:error"})
+		c.Stats.AWSCloudWatchLogs.Add(Error, int64(len(rejected)))
+		c.PromStats.Outputs.With(map[string]string{"destination": "awscloudwatchlogs", "status": Error}).Add(float64(len(rejected)))
+		log.Printf("[ERROR] : %v CloudWatchLogs - %v log events rejected\n", c.OutputType, len(rejected))
 	}
 
+	sent := len(events) - len(rejected)
 	log.Printf("[INFO]  : %v CloudWatchLogs - Send Log OK (%v)\n", c.OutputType, resp.String())
-	go c.CountMetric("outputs", 1, []string{"output:awscloudwatchlogs", "status:ok"})
-	c.Stats.AWSCloudWatchLogs.Add(OK, 1)
-	c.PromStats.Outputs.With(map[string]string{"destination": "awscloudwatchlogs", "status": OK}).Inc()
+	go c.CountMetric("outputs", int64(sent), []string{"output:awscloudwatchlogs", "status:ok"})
+	c.Stats.AWSCloudWatchLogs.Add(OK, int64(sent))
+	c.PromStats.Outputs.With(map[string]string{"destination": "awscloudwatchlogs", "status": OK}).Add(float64(sent))
+
+	return rejected, nil
+}
+
+// createCloudWatchLogStream creates the log stream if none is configured
+func (c *Client) createCloudWatchLogStream(svc *cloudwatchlogs.CloudWatchLogs) error {
+	if c.Config.AWS.CloudWatchLogs.LogStream == "" {
+		streamName := "sidekick-logstream"
+		log.Printf("[INFO]  : %v CloudWatchLogs - Log Stream not configured creating one called %s\n", c.OutputType, streamName)
+		inputLogStream := &cloudwatchlogs.CreateLogStreamInput{
+			LogGroupName:  aws.String(c.Config.AWS.CloudWatchLogs.LogGroup),
+			LogStreamName: aws.String(streamName),
+		}
+
+		_, err := svc.CreateLogStream(inputLogStream)
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != cloudwatchlogs.ErrCodeResourceAlreadyExistsException {
+				return err
+			}
+			log.Printf("[INFO]  : %v CloudWatchLogs - Log Stream %s already exist, reusing...\n", c.OutputType, streamName)
+		}
+
+		c.Config.AWS.CloudWatchLogs.LogStream = streamName
+	}
+	return nil
 }
 
 // PutLogEvents will attempt to execute and handle invalid tokens.
@@ -347,7 +474,7 @@ func (c *Client) putLogEvents(svc *cloudwatchlogs.CloudWatchLogs, input *cloudwa
 }
 
 // PutRecord puts a record in Kinesis
-func (c *Client) PutRecord(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) PutRecord(kubearmorpayload types.KubearmorPayload) error {
 	svc := kinesis.New(c.AWSSession)
 
 	c.Stats.AWSKinesis.Add(Total, 1)
@@ -365,292 +492,85 @@ func (c *Client) PutRecord(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.AWSKinesis.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "awskinesis", "status": Error}).Inc()
 		log.Printf("[ERROR] : %v Kinesis - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
 	log.Printf("[INFO] : %v Kinesis - Put Record OK (%v)\n", c.OutputType, resp.SequenceNumber)
 	go c.CountMetric("outputs", 1, []string{"output:awskinesis", "status:ok"})
 	c.Stats.AWSKinesis.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "awskinesis", "status": "ok"}).Inc()
-}
-
-// lambda
-func (c *Client) WatchInvokeLambdaAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.InvokeLambda(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
 
 	return nil
 }
 
-func (c *Client) WatchInvokeLambdaLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
+// kinesisMaxRecords is the number of records of a PutRecords call
+const kinesisMaxRecords = 500
 
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.InvokeLambda(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
-
-	return nil
-}
-
-// SendMessage
-func (c *Client) WatchSendMessageAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.SendMessage(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchSendMessageLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.SendMessage(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
-
-	return nil
-}
-
-// PublishTopic
-func (c *Client) WatchPublishTopicAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.PublishTopic(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchPublishTopicLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
+// PutRecords puts records in Kinesis, by PutRecords calls of at most 500
+// records. The records Kinesis rejected are returned in a *BatchError, the
+// error of the calls is retryable if they all failed.
+func (c *Client) PutRecords(events []types.KubearmorPayload) error {
+	svc := kinesis.New(c.AWSSession)
 
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.PublishTopic(resp)
+	c.Stats.AWSKinesis.Add(Total, int64(len(events)))
 
-		default:
-			time.Sleep(time.Millisecond * 10)
+	var failures []BatchFailure
+	var callErr error
+	for start := 0; start < len(events); start += kinesisMaxRecords {
+		end := start + kinesisMaxRecords
+		if end > len(events) {
+			end = len(events)
 		}
-	}
-
-	return nil
-}
-
-// SendCloudWatchLog
-func (c *Client) WatchSendCloudWatchLogAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.SendCloudWatchLog(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
+		records := make([]*kinesis.PutRecordsRequestEntry, 0, end-start)
+		for _, kubearmorpayload := range events[start:end] {
+			f, _ := json.Marshal(kubearmorpayload)
+			records = append(records, &kinesis.PutRecordsRequestEntry{
+				Data:         f,
+				PartitionKey: aws.String(uuid.NewString()),
+			})
 		}
-	}
 
-	return nil
-}
-
-func (c *Client) WatchSendCloudWatchLogLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.SendCloudWatchLog(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
+		resp, err := svc.PutRecords(&kinesis.PutRecordsInput{
+			Records:    records,
+			StreamName: aws.String(c.Config.AWS.Kinesis.StreamName),
+		})
+		if err != nil {
+			callErr = err
+			for i := start; i < end; i++ {
+				failures = append(failures, BatchFailure{Index: i, Err: err, Retryable: true})
+			}
+			log.Printf("[ERROR] : %v Kinesis - %v\n", c.OutputType, err.Error())
+			continue
 		}
-	}
-
-	return nil
-}
-
-// UploadS3
-func (c *Client) WatchUploadS3Alerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.UploadS3(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
+		for i, record := range resp.Records {
+			if record.ErrorCode == nil {
+				continue
+			}
+			failures = append(failures, BatchFailure{
+				Index:     start + i,
+				Err:       fmt.Errorf("%v: %v", aws.StringValue(record.ErrorCode), aws.StringValue(record.ErrorMessage)),
+				Retryable: true,
+			})
 		}
 	}
 
-	return nil
-}
-
-func (c *Client) WatchUploadS3Logs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.UploadS3(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
+	sent := len(events) - len(failures)
+	if len(failures) != 0 {
+		go c.CountMetric("outputs", int64(len(failures)), []string{"output:awskinesis", "status:error"})
+		c.Stats.AWSKinesis.Add(Error, int64(len(failures)))
+		c.PromStats.Outputs.With(map[string]string{"destination": "awskinesis", "status": Error}).Add(float64(len(failures)))
 	}
-
-	return nil
-}
-
-func (c *Client) WatchPutRecordAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.PutRecord(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
+	if sent == 0 && callErr != nil {
+		return &retryableError{err: callErr}
 	}
 
-	return nil
-}
-
-func (c *Client) WatchPutRecordLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
+	log.Printf("[INFO]  : %v Kinesis - Put Records OK (%v/%v)\n", c.OutputType, sent, len(events))
+	go c.CountMetric("outputs", int64(sent), []string{"output:awskinesis", "status:ok"})
+	c.Stats.AWSKinesis.Add(OK, int64(sent))
+	c.PromStats.Outputs.With(map[string]string{"destination": "awskinesis", "status": OK}).Add(float64(sent))
 
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.PutRecord(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
+	if len(failures) != 0 {
+		return &BatchError{Failures: failures}
 	}
-
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/DataDog/datadog-go/statsd"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/embano1/memlog"
@@ -19,6 +20,20 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "AWSSecurityLake",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.AWS.SecurityLake.Bucket != "" && config.AWS.SecurityLake.Region != "" && config.AWS.SecurityLake.AccountID != "" && config.AWS.SecurityLake.Prefix != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.AWS.SecurityLake.MinimumPriority },
+		New:             NewSecurityLakeClient,
+		Send:            (*Client).EnqueueSecurityLake,
+		Close:           (*Client).FlushSecurityLake,
+	})
+}
+
 const (
 	sevUnknown = iota
 	sevInformational
@@ -109,7 +124,7 @@ func NewOCSFSecurityFinding(kubearmorpayload types.KubearmorPayload) OCSFSecurit
 		TypeName:     "Security Finding: Generate",
 		// Attacks: getMitreAttacke(kubearmorpayload.Tags),
 		Metadata: OCSFMetadata{
-			Labels: []string{kubearmorpayload.OutputFields["Labels"].(string)},
+			Labels: []string{outputField(kubearmorpayload, "Labels")},
 			Product: OCSFProduct{
 				Name:       "Kubearmor",
 				VendorName: "Accuknox",
@@ -122,10 +137,10 @@ func NewOCSFSecurityFinding(kubearmorpayload types.KubearmorPayload) OCSFSecurit
 		Finding: OCSFFIndingDetails{
 			CreatedTime: kubearmorpayload.Timestamp,
 			Desc:        kubearmorpayload.EventType,
-			Title:       kubearmorpayload.OutputFields["PodName"].(string) + "-" + kubearmorpayload.EventType,
-			UID:         kubearmorpayload.OutputFields["UID"].(string),
+			Title:       outputField(kubearmorpayload, "PodName") + "-" + kubearmorpayload.EventType,
+			UID:         outputField(kubearmorpayload, "UID"),
 		},
-		Message:     kubearmorpayload.EventType + "-" + kubearmorpayload.ClusterName + "-" + kubearmorpayload.OutputFields["PodName"].(string),
+		Message:     kubearmorpayload.EventType + "-" + kubearmorpayload.ClusterName + "-" + outputField(kubearmorpayload, "PodName"),
 		Observables: getObservables(kubearmorpayload.Hostname, kubearmorpayload.OutputFields),
 		Timestamp:   kubearmorpayload.Timestamp,
 		Status:      kubearmorpayload.EventType,
@@ -175,17 +190,41 @@ func getObservables(hostname string, outputFields map[string]interface{}) []OCSF
 // 	return ocsfa
 // }
 
-func (c *Client) EnqueueSecurityLake(kubearmorpayload types.KubearmorPayload) {
+// NewSecurityLakeClient returns a new output.Client for AWS Security Lake and
+// starts the worker uploading the queued events as parquet files.
+func NewSecurityLakeClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	c, err := NewAWSClient(config, stats, promStats, statsdClient, dogstatsdClient)
+	if err != nil {
+		return nil, err
+	}
+
+	config.AWS.SecurityLake.Ctx = context.Background()
+	// the offsets are the ones of the last records read and written, the first
+	// record is at offset 0
+	readOffset, writeOffset := memlog.Offset(-1), memlog.Offset(-1)
+	config.AWS.SecurityLake.ReadOffset, config.AWS.SecurityLake.WriteOffset = &readOffset, &writeOffset
+	config.AWS.SecurityLake.Memlog, err = memlog.New(config.AWS.SecurityLake.Ctx, memlog.WithMaxSegmentSize(10000))
+	if err != nil {
+		return nil, err
+	}
+
+	go c.StartSecurityLakeWorker()
+	return c, nil
+}
+
+func (c *Client) EnqueueSecurityLake(kubearmorpayload types.KubearmorPayload) error {
 	offset, err := c.Config.AWS.SecurityLake.Memlog.Write(c.Config.AWS.SecurityLake.Ctx, []byte(kubearmorpayload.String()))
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:awssecuritylake.", "status:error"})
 		c.Stats.AWSSecurityLake.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "awssecuritylake.", "status": Error}).Inc()
 		log.Printf("[ERROR] : %v SecurityLake - %v\n", c.OutputType, err)
-		return
+		return err
 	}
-	log.Printf("[INFO]  : %v SecurityLake - Event queued (%v)\n", c.OutputType, kubearmorpayload.OutputFields["UID"].(string))
+	log.Printf("[INFO]  : %v SecurityLake - Event queued (%v)\n", c.OutputType, outputField(kubearmorpayload, "UID"))
 	*c.Config.AWS.SecurityLake.WriteOffset = offset
+
+	return nil
 }
 
 func (c *Client) StartSecurityLakeWorker() {
@@ -199,8 +238,33 @@ func (c *Client) StartSecurityLakeWorker() {
 	}
 }
 
+// FlushSecurityLake uploads the events left in the queue, batch after batch,
+// until the queue is empty or the context is done. It is called once no more
+// events are enqueued.
+func (c *Client) FlushSecurityLake(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.securityLakeLock.Lock()
+		// the next batch is read from ReadOffset+1
+		pending := *c.Config.AWS.SecurityLake.ReadOffset+1 <= *c.Config.AWS.SecurityLake.WriteOffset
+		c.securityLakeLock.Unlock()
+		if !pending {
+			return nil
+		}
+		if err := c.processNextBatch(); err != nil && !errors.Is(err, memlog.ErrOutOfRange) {
+			return err
+		}
+	}
+}
+
 func (c *Client) processNextBatch() error {
-	awslake := c.Config.AWS.SecurityLake // assumes no concurrent r/w
+	// the worker and the flush on shutdown read the queue in turn
+	c.securityLakeLock.Lock()
+	defer c.securityLakeLock.Unlock()
+
+	awslake := c.Config.AWS.SecurityLake
 	ctx := awslake.Ctx
 	ml := awslake.Memlog
 
@@ -231,7 +295,7 @@ func (c *Client) processNextBatch() error {
 				err,
 			)
 			log.Printf("[ERROR] : %v SecurityLake - %v\n", c.OutputType, msg)
-			awslake.ReadOffset = &earliest
+			*awslake.ReadOffset = earliest
 			return err
 		}
 
@@ -321,50 +385,3 @@ func (c *Client) writeParquet(uid string, records []memlog.Record) error {
 	}
 	return nil
 }
-
-// EnqueueSecurityLake
-func (c *Client) WatchEnqueueSecurityLakeAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.EnqueueSecurityLake(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchEnqueueSecurityLakeLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.EnqueueSecurityLake(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
-
-	return nil
-}
@@ -8,11 +8,21 @@ import (
 
 	eventhub "github.com/Azure/azure-event-hubs-go/v3"
 	"github.com/DataDog/datadog-go/statsd"
-	"github.com/google/uuid"
 
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "EventHub",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Azure.EventHub.Name != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Azure.EventHub.MinimumPriority },
+		New:             NewEventHubClient,
+		Send:            (*Client).EventHubPost,
+	})
+}
+
 // NewEventHubClient returns a new output.Client for accessing the Azure Event Hub.
 func NewEventHubClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 	return &Client{
@@ -26,7 +36,7 @@ func NewEventHubClient(config *types.Configuration, stats *types.Statistics, pro
 }
 
 // EventHubPost posts event to Azure Event Hub
-func (c *Client) EventHubPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) EventHubPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.AzureEventHub.Add(Total, 1)
 
 	log.Printf("[INFO] : %v EventHub - Try sending event", c.OutputType)
@@ -34,7 +44,7 @@ func (c *Client) EventHubPost(kubearmorpayload types.KubearmorPayload) {
 	if err != nil {
 		c.setEventHubErrorMetrics()
 		log.Printf("[ERROR] : %v EventHub - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
 	log.Printf("[INFO]  : %v EventHub - Hub client created\n", c.OutputType)
@@ -43,7 +53,7 @@ func (c *Client) EventHubPost(kubearmorpayload types.KubearmorPayload) {
 	if err != nil {
 		c.setEventHubErrorMetrics()
 		log.Printf("[ERROR] : Cannot marshal payload: %v", err.Error())
-		return
+		return err
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
@@ -53,7 +63,7 @@ func (c *Client) EventHubPost(kubearmorpayload types.KubearmorPayload) {
 	if err != nil {
 		c.setEventHubErrorMetrics()
 		log.Printf("[ERROR] : %v EventHub - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
 	// Setting the success status
@@ -61,6 +71,8 @@ func (c *Client) EventHubPost(kubearmorpayload types.KubearmorPayload) {
 	c.Stats.AzureEventHub.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "azureeventhub", "status": OK}).Inc()
 	log.Printf("[INFO]  : %v EventHub - Publish OK", c.OutputType)
+
+	return nil
 }
 
 // setEventHubErrorMetrics set the error stats
@@ -69,50 +81,3 @@ func (c *Client) setEventHubErrorMetrics() {
 	c.Stats.AzureEventHub.Add(Error, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "azureeventhub", "status": Error}).Inc()
 }
-
-// EnqueueSecurityLake
-func (c *Client) WatchEventHubPostlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.EventHubPost(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchEventHubPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.EventHubPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
-
-	return nil
-}
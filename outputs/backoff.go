@@ -0,0 +1,33 @@
+package outputs
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes exponentially growing delays between retries.
+type Backoff struct {
+	// Base is the delay before the first retry.
+	Base time.Duration
+	// Max caps the delay.
+	Max time.Duration
+	// Jitter is the fraction of the delay, between 0 and 1, which is randomized
+	// to spread the retries of concurrent clients.
+	Jitter float64
+}
+
+// Delay returns the delay to wait before the given retry attempt, starting at 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Base
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	if b.Jitter > 0 && d > 0 {
+		// #nosec G404 the jitter doesn't need a cryptographically secure random number
+		d -= time.Duration(rand.Float64() * b.Jitter * float64(d))
+	}
+	return d
+}
@@ -0,0 +1,136 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// BatchFailure is an event of a batch which the destination rejected
+type BatchFailure struct {
+	// Index is the index of the event in the batch
+	Index int
+	Err   error
+	// Retryable is true if the event is worth sending again
+	Retryable bool
+}
+
+// BatchError is returned when the destination rejected only some events of a
+// batch, the other ones were delivered.
+type BatchError struct {
+	Failures []BatchFailure
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Failures) == 0 {
+		return "no event of the batch failed"
+	}
+	return fmt.Sprintf("%v events of the batch failed, first error: %v", len(e.Failures), e.Failures[0].Err)
+}
+
+// batchPolicy returns the batch settings of the output, the default ones if
+// the output has none of its own.
+func batchPolicy(batch types.BatchConfig, name string) types.BatchConfig {
+	if b, ok := batch.Outputs[strings.ToLower(name)]; ok {
+		return b
+	}
+	batch.Outputs = nil
+	return batch
+}
+
+// eventSize returns the size of the event as JSON, used to cap the batches
+func eventSize(kubearmorpayload types.KubearmorPayload) int {
+	b, _ := json.Marshal(kubearmorpayload)
+	return len(b)
+}
+
+// Batcher groups items into batches, a batch is flushed once it holds MaxSize
+// items or MaxBytes, or Linger after its first item was added.
+type Batcher[T any] struct {
+	maxSize  int
+	maxBytes int
+	linger   time.Duration
+	size     func(item T) int
+	flush    func(batch []T)
+	items    chan T
+	done     chan struct{}
+}
+
+// NewBatcher starts a Batcher calling flush with the batches, size returns the
+// size of an item counted against MaxBytes.
+func NewBatcher[T any](config types.BatchConfig, size func(item T) int, flush func(batch []T)) *Batcher[T] {
+	b := &Batcher[T]{
+		maxSize:  config.MaxSize,
+		maxBytes: config.MaxBytes,
+		linger:   time.Duration(config.Linger) * time.Millisecond,
+		size:     size,
+		flush:    flush,
+		items:    make(chan T, config.MaxSize),
+		done:     make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Add adds the item to the current batch, it blocks while a batch is flushed
+// and the next one is full.
+func (b *Batcher[T]) Add(item T) {
+	b.items <- item
+}
+
+// Close flushes the items left and waits for the flush, no item must be added
+// afterwards.
+func (b *Batcher[T]) Close() {
+	close(b.items)
+	<-b.done
+}
+
+func (b *Batcher[T]) run() {
+	defer close(b.done)
+
+	var (
+		batch  []T
+		bytes  int
+		timer  *time.Timer
+		linger <-chan time.Time
+	)
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, linger = nil, nil
+		}
+		if len(batch) == 0 {
+			return
+		}
+		b.flush(batch)
+		batch, bytes = nil, 0
+	}
+
+	for {
+		select {
+		case item, ok := <-b.items:
+			if !ok {
+				flush()
+				return
+			}
+			size := b.size(item)
+			if len(batch) != 0 && b.maxBytes > 0 && bytes+size > b.maxBytes {
+				flush()
+			}
+			batch = append(batch, item)
+			bytes += size
+			if len(batch) >= b.maxSize || (b.maxBytes > 0 && bytes >= b.maxBytes) {
+				flush()
+			} else if timer == nil {
+				timer = time.NewTimer(b.linger)
+				linger = timer.C
+			}
+		case <-linger:
+			timer, linger = nil, nil
+			flush()
+		}
+	}
+}
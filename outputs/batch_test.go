@@ -0,0 +1,221 @@
+package outputs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+type testBatchOutput struct {
+	testOutput
+	batches [][]types.KubearmorPayload
+	// errs are returned by the successive calls of SendBatch
+	errs []error
+}
+
+func (o *testBatchOutput) SendBatch(events []types.KubearmorPayload) error {
+	o.batches = append(o.batches, events)
+	if len(o.errs) == 0 {
+		return nil
+	}
+	err := o.errs[0]
+	o.errs = o.errs[1:]
+	return err
+}
+
+func TestBatcher(t *testing.T) {
+	batches := make(chan []int, 10)
+	b := NewBatcher(types.BatchConfig{MaxSize: 3, MaxBytes: 10, Linger: 50}, func(item int) int { return item }, func(batch []int) {
+		batches <- batch
+	})
+
+	for _, item := range []int{1, 1, 1, 1, 1, 4, 5} {
+		b.Add(item)
+	}
+	require.Equal(t, []int{1, 1, 1}, <-batches, "flushed at MaxSize")
+	require.Equal(t, []int{1, 1, 4}, <-batches, "flushed before exceeding MaxBytes")
+
+	start := time.Now()
+	require.Equal(t, []int{5}, <-batches, "flushed after Linger")
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	b.Add(2)
+	b.Close()
+	require.Equal(t, []int{2}, <-batches, "flushed on close")
+}
+
+func TestSendBatchPartialFailure(t *testing.T) {
+	config := types.DeadLetterConfig{Target: DeadLetterFile, File: filepath.Join(t.TempDir(), "deadletter.jsonl"), MaxSize: 1}
+	deadLetters, err := NewDeadLetterQueue(config, nil)
+	require.Nil(t, err)
+	d := NewDispatcher(&types.Configuration{Retry: types.RetryConfig{MaxAttempts: 3, BaseBackoff: 1, MaxBackoff: 1}}, &types.Statistics{}, &types.PromStatistics{})
+	d.DeadLetters = deadLetters
+
+	o := &testBatchOutput{testOutput: testOutput{name: "bulk"}, errs: []error{
+		&BatchError{Failures: []BatchFailure{
+			{Index: 1, Err: ErrTooManyRequest, Retryable: true},
+			{Index: 2, Err: errors.New("mapper_parsing_exception")},
+		}},
+		&BatchError{Failures: []BatchFailure{{Index: 0, Err: ErrTooManyRequest, Retryable: true}}},
+		&BatchError{Failures: []BatchFailure{{Index: 0, Err: ErrTooManyRequest, Retryable: true}}},
+	}}
+	events := []types.KubearmorPayload{{Hostname: "a"}, {Hostname: "b"}, {Hostname: "c"}}
+	d.sendBatch(context.Background(), o, events)
+	require.Nil(t, deadLetters.Close())
+
+	require.Len(t, o.batches, 3)
+	require.Equal(t, events[1:2], o.batches[1], "only the failed events are sent again")
+	require.Equal(t, events[1:2], o.batches[2])
+
+	l := readDeadLetters(t, config.File)
+	require.Len(t, l, 2)
+	require.Equal(t, "c", l[0].Event.Hostname, "the events not worth sending again are dead-lettered at once")
+	require.Equal(t, 1, l[0].Attempts)
+	require.Equal(t, "b", l[1].Event.Hostname)
+	require.Equal(t, 3, l[1].Attempts)
+}
+
+func TestSendBatchFailure(t *testing.T) {
+	config := types.DeadLetterConfig{Target: DeadLetterFile, File: filepath.Join(t.TempDir(), "deadletter.jsonl"), MaxSize: 1}
+	deadLetters, err := NewDeadLetterQueue(config, nil)
+	require.Nil(t, err)
+	d := NewDispatcher(&types.Configuration{Retry: types.RetryConfig{MaxAttempts: 3, BaseBackoff: 1, MaxBackoff: 1}}, &types.Statistics{}, &types.PromStatistics{})
+	d.DeadLetters = deadLetters
+
+	// a failed batch worth it is sent again, up to the attempts of the policy
+	o := &testBatchOutput{testOutput: testOutput{name: "bulk"}, errs: []error{&retryableError{err: ErrBadGateway}, nil}}
+	events := []types.KubearmorPayload{{Hostname: "a"}, {Hostname: "b"}}
+	d.sendBatch(context.Background(), o, events)
+	require.Len(t, o.batches, 2)
+	require.Equal(t, events, o.batches[1])
+
+	o = &testBatchOutput{testOutput: testOutput{name: "bulk"}, errs: []error{
+		&retryableError{err: ErrBadGateway}, &retryableError{err: ErrBadGateway}, &retryableError{err: ErrBadGateway}, nil,
+	}}
+	d.sendBatch(context.Background(), o, events[:1])
+	require.Len(t, o.batches, 3)
+
+	// the batch is not retried once the context is canceled, whatever the backoff
+	d.Config.Retry.BaseBackoff, d.Config.Retry.MaxBackoff = 60000, 60000
+	o = &testBatchOutput{testOutput: testOutput{name: "bulk"}, errs: []error{&retryableError{err: ErrBadGateway}, nil}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	d.sendBatch(ctx, o, events[1:])
+	require.Less(t, time.Since(start), time.Second)
+	require.Len(t, o.batches, 1)
+	require.Nil(t, deadLetters.Close())
+
+	l := readDeadLetters(t, config.File)
+	require.Len(t, l, 2)
+	require.Equal(t, "a", l[0].Event.Hostname)
+	require.Equal(t, 3, l[0].Attempts)
+	require.Equal(t, "b", l[1].Event.Hostname)
+	require.Equal(t, 1, l[1].Attempts)
+}
+
+func TestDispatchBatches(t *testing.T) {
+	d := NewDispatcher(&types.Configuration{Batch: types.BatchConfig{MaxSize: 1, Outputs: map[string]types.BatchConfig{"bulk": {MaxSize: 2, Linger: 1000}}}}, &types.Statistics{}, &types.PromStatistics{})
+	require.Nil(t, d.newBatcher(context.Background(), &testOutput{name: "bulk"}, nil), "the output has no bulk API")
+	require.Nil(t, d.newBatcher(context.Background(), &testBatchOutput{testOutput: testOutput{name: "other"}}, nil), "the batches of the output hold a single event")
+
+	o := &testBatchOutput{testOutput: testOutput{name: "bulk"}}
+	r := outputRoute{batcher: d.newBatcher(context.Background(), o, nil)}
+	require.NotNil(t, r.batcher)
+	for i := 0; i < 3; i++ {
+		d.forward(context.Background(), o, r, types.KubearmorPayload{})
+	}
+	r.batcher.Close()
+	require.Len(t, o.batches, 2)
+	require.Len(t, o.batches[0], 2)
+	require.Len(t, o.batches[1], 1)
+}
+
+func TestElasticsearchBulk(t *testing.T) {
+	var lines []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/_bulk", r.URL.Path)
+		require.Equal(t, "application/x-ndjson", r.Header.Get(ContentTypeHeaderKey))
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		w.Write([]byte(`{"errors":true,"items":[
+			{"index":{"status":201}},
+			{"index":{"status":429,"error":{"type":"es_rejected_execution_exception"}}},
+			{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`))
+	}))
+	defer ts.Close()
+
+	config := &types.Configuration{Elasticsearch: types.ElasticsearchOutputConfig{HostPort: ts.URL, Index: "kubearmor", Type: "_doc", Suffix: "none"}}
+	stats := &types.Statistics{Elasticsearch: new(expvar.Map).Init()}
+	promStats := &types.PromStatistics{Outputs: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_outputs"}, []string{"destination", "status"})}
+	c, err := newElasticsearchClient(config, stats, promStats, nil, nil)
+	require.Nil(t, err)
+	c.Retry = types.RetryConfig{MaxAttempts: 1, RetryableCodesList: []int{429}}
+
+	err = c.ElasticsearchBulk([]types.KubearmorPayload{{Hostname: "a"}, {Hostname: "b"}, {Hostname: "c"}})
+	var batchErr *BatchError
+	require.ErrorAs(t, err, &batchErr)
+	require.Len(t, batchErr.Failures, 2)
+	require.Equal(t, 1, batchErr.Failures[0].Index)
+	require.True(t, batchErr.Failures[0].Retryable)
+	require.Equal(t, 2, batchErr.Failures[1].Index)
+	require.False(t, batchErr.Failures[1].Retryable)
+	require.Contains(t, batchErr.Failures[1].Err.Error(), "mapper_parsing_exception")
+
+	require.Len(t, lines, 6)
+	require.Equal(t, `{"index":{"_index":"kubearmor"}}`, lines[0])
+	var event types.KubearmorPayload
+	require.Nil(t, json.Unmarshal([]byte(lines[5]), &event))
+	require.Equal(t, "c", event.Hostname)
+	require.Equal(t, "1", stats.Elasticsearch.Get(OK).String())
+	require.Equal(t, "2", stats.Elasticsearch.Get(Error).String())
+}
+
+func TestElasticsearchBulkWithoutRetries(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	config := &types.Configuration{Elasticsearch: types.ElasticsearchOutputConfig{HostPort: ts.URL, Index: "kubearmor", Type: "_doc", Suffix: "none"}}
+	stats := &types.Statistics{Elasticsearch: new(expvar.Map).Init()}
+	promStats := &types.PromStatistics{Outputs: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_outputs"}, []string{"destination", "status"})}
+	c, err := newElasticsearchClient(config, stats, promStats, nil, nil)
+	require.Nil(t, err)
+	c.Retry = types.RetryConfig{MaxAttempts: 3, BaseBackoff: 1, MaxBackoff: 1, RetryableCodesList: []int{503}}
+
+	// the batches are retried by the dispatcher only, the attempts of the
+	// client and of the dispatcher don't add up
+	err = c.ElasticsearchBulk([]types.KubearmorPayload{{Hostname: "a"}})
+	require.True(t, Retryable(err))
+	require.Equal(t, 1, Attempts(err))
+	require.EqualValues(t, 1, requests.Load())
+}
+
+func TestNewLokiBatchPayload(t *testing.T) {
+	event := func(pod string, timestamp int64) types.KubearmorPayload {
+		return types.KubearmorPayload{Timestamp: timestamp, EventType: AlertEventType, OutputFields: map[string]interface{}{"PodName": pod}}
+	}
+	payload := newLokiBatchPayload([]types.KubearmorPayload{event("a", 1), event("b", 2), event("a", 3)}, &types.Configuration{})
+	require.Len(t, payload.Streams, 2, "the events with the same labels are in the same stream")
+	require.Equal(t, "a", payload.Streams[0].Stream["source"])
+	require.Equal(t, []lokiValue{{"1"}, {"3"}}, payload.Streams[0].Values)
+	require.Equal(t, []lokiValue{{"2"}}, payload.Streams[1].Values)
+}
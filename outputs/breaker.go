@@ -0,0 +1,164 @@
+package outputs
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// ErrCircuitOpen is returned instead of sending an event while the circuit
+// breaker of the output is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a circuit breaker, exported as a gauge
+type BreakerState int
+
+// States of the circuit breakers
+const (
+	// BreakerClosed lets the events through
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen lets a single event through to probe the destination
+	BreakerHalfOpen
+	// BreakerOpen short-circuits the events
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return "closed"
+}
+
+// MarshalText writes the name of the state
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Breaker is the circuit breaker of an output. It opens after Failures
+// consecutive failures, then lets a probe through every OpenTimeout and closes
+// once a probe succeeds.
+type Breaker struct {
+	output      string
+	failures    int
+	openTimeout time.Duration
+
+	lock        sync.Mutex
+	state       BreakerState
+	consecutive int
+	openedAt    time.Time
+	now         func() time.Time
+}
+
+// BreakerStat is the state of the circuit breaker of an output
+type BreakerStat struct {
+	Output string       `json:"output"`
+	State  BreakerState `json:"state"`
+}
+
+var (
+	breakersLock sync.Mutex
+	breakers     = make(map[string]*Breaker)
+)
+
+// breakerPolicy returns the circuit breaker settings of the output, the
+// default ones if the output has none of its own.
+func breakerPolicy(breaker types.BreakerConfig, name string) types.BreakerConfig {
+	if b, ok := breaker.Outputs[strings.ToLower(name)]; ok {
+		return b
+	}
+	breaker.Outputs = nil
+	return breaker
+}
+
+// newBreaker returns the circuit breaker of the output, nil if it is disabled.
+// The breaker is reported by BreakerStats.
+func newBreaker(config types.BreakerConfig, output string) *Breaker {
+	if config.Failures <= 0 {
+		return nil
+	}
+	b := &Breaker{
+		output:      output,
+		failures:    config.Failures,
+		openTimeout: time.Duration(config.OpenTimeout) * time.Millisecond,
+		now:         time.Now,
+	}
+	breakersLock.Lock()
+	defer breakersLock.Unlock()
+	breakers[output] = b
+	return b
+}
+
+// Allow reports whether an event can be sent. Once OpenTimeout elapsed, an
+// open breaker becomes half-open and allows a single probe.
+func (b *Breaker) Allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		log.Printf("[INFO]  : %v - Circuit breaker half-open, probing the destination\n", b.output)
+		return true
+	case BreakerHalfOpen:
+		// a probe is in flight
+		return false
+	}
+	return true
+}
+
+// Record updates the breaker with the result of an event allowed through
+func (b *Breaker) Record(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if err == nil {
+		if b.state != BreakerClosed {
+			log.Printf("[INFO]  : %v - Circuit breaker closed\n", b.output)
+		}
+		b.state = BreakerClosed
+		b.consecutive = 0
+		return
+	}
+
+	b.consecutive++
+	if b.state == BreakerHalfOpen || b.consecutive >= b.failures {
+		if b.state != BreakerOpen {
+			log.Printf("[WARN]  : %v - Circuit breaker open after %v failures, probing in %v\n", b.output, b.consecutive, b.openTimeout)
+		}
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// State returns the state of the breaker
+func (b *Breaker) State() BreakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+// BreakerStats returns the state of the circuit breakers of the outputs,
+// sorted by output.
+func BreakerStats() []BreakerStat {
+	breakersLock.Lock()
+	defer breakersLock.Unlock()
+
+	s := make([]BreakerStat, 0, len(breakers))
+	for _, b := range breakers {
+		s = append(s, BreakerStat{Output: b.output, State: b.State()})
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Output < s[j].Output })
+	return s
+}
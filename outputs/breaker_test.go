@@ -0,0 +1,86 @@
+package outputs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestBreaker(t *testing.T) {
+	require.Nil(t, newBreaker(types.BreakerConfig{}, "disabled"))
+
+	b := newBreaker(types.BreakerConfig{Failures: 2, OpenTimeout: 1000}, "breaker")
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	failure := errors.New("timeout")
+
+	require.True(t, b.Allow())
+	b.Record(failure)
+	require.True(t, b.Allow())
+	b.Record(nil)
+	require.True(t, b.Allow())
+	b.Record(failure)
+	require.Equal(t, BreakerClosed, b.State(), "a success resets the failures")
+	require.True(t, b.Allow())
+	b.Record(failure)
+	require.Equal(t, BreakerOpen, b.State())
+	require.False(t, b.Allow())
+
+	// a single probe once the timeout elapsed, a failure opens the breaker again
+	now = now.Add(time.Second)
+	require.True(t, b.Allow())
+	require.Equal(t, BreakerHalfOpen, b.State())
+	require.False(t, b.Allow())
+	b.Record(failure)
+	require.Equal(t, BreakerOpen, b.State())
+	require.False(t, b.Allow())
+
+	now = now.Add(time.Second)
+	require.True(t, b.Allow())
+	b.Record(nil)
+	require.Equal(t, BreakerClosed, b.State())
+	require.True(t, b.Allow())
+
+	var stat []BreakerStat
+	for _, s := range BreakerStats() {
+		if s.Output == "breaker" {
+			stat = append(stat, s)
+		}
+	}
+	require.Equal(t, []BreakerStat{{Output: "breaker", State: BreakerClosed}}, stat)
+}
+
+func TestRegisteredOutputBreaker(t *testing.T) {
+	var sent int
+	o := &registeredOutput{
+		registration: Registration{Name: "Failing", Send: func(c *Client, kubearmorpayload types.KubearmorPayload) error {
+			sent++
+			return ErrBadGateway
+		}},
+		breaker: newBreaker(types.BreakerConfig{Failures: 3, OpenTimeout: 60000}, "Failing"),
+	}
+	for i := 0; i < 3; i++ {
+		require.ErrorIs(t, o.Send(types.KubearmorPayload{}), ErrBadGateway)
+	}
+	err := o.Send(types.KubearmorPayload{})
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, 0, Attempts(err))
+	require.Equal(t, 3, sent)
+}
+
+func TestRegisteredOutputBreakerPermanent(t *testing.T) {
+	o := &registeredOutput{
+		registration: Registration{Name: "Rejecting", Send: func(c *Client, kubearmorpayload types.KubearmorPayload) error {
+			return &permanentError{err: ErrHeaderMissing}
+		}},
+		breaker: newBreaker(types.BreakerConfig{Failures: 1, OpenTimeout: 60000}, "Rejecting"),
+	}
+	for i := 0; i < 3; i++ {
+		require.True(t, Permanent(o.Send(types.KubearmorPayload{})), "the events rejected for good don't open the breaker")
+	}
+	require.Equal(t, BreakerClosed, o.breaker.State())
+}
@@ -0,0 +1,157 @@
+package outputs
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// Overflow policies of the buffers
+const (
+	// OverflowBlock waits for room in the buffer
+	OverflowBlock string = "block"
+	// OverflowDropNewest drops the event pushed to a full buffer
+	OverflowDropNewest string = "drop-newest"
+	// OverflowDropOldest drops the oldest event of a full buffer to make room
+	OverflowDropOldest string = "drop-oldest"
+)
+
+// Stages of the pipeline holding a buffer
+const (
+	RelayAlertsStage  string = "relay-alerts"
+	RelayLogsStage    string = "relay-logs"
+	OutputAlertsStage string = "output-alerts"
+	OutputLogsStage   string = "output-logs"
+)
+
+// Default sizes of the buffers
+const (
+	DefaultAlertBufferSize  int = 1000
+	DefaultLogBufferSize    int = 10000
+	DefaultOutputBufferSize int = 1000
+)
+
+// Buffer is a bounded queue between two stages of the pipeline, which applies
+// its overflow policy when full.
+type Buffer[T any] struct {
+	// C is read by the next stage
+	C        chan T
+	stage    string
+	output   string
+	overflow string
+	dropped  atomic.Uint64
+}
+
+// BufferStat is the state of a buffer
+type BufferStat struct {
+	Stage    string `json:"stage"`
+	Output   string `json:"output,omitempty"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+type bufferStater interface {
+	stat() BufferStat
+}
+
+var (
+	buffersLock sync.Mutex
+	buffers     = make(map[bufferStater]struct{})
+)
+
+// NewBuffer returns a buffer of the stage, for the output if the stage is
+// specific to an output. The buffer is reported by BufferStats until Close
+// is called.
+func NewBuffer[T any](config types.BufferConfig, defaultSize int, stage, output string) *Buffer[T] {
+	size := config.Size
+	if size <= 0 {
+		size = defaultSize
+	}
+	overflow := config.Overflow
+	if overflow == "" {
+		overflow = OverflowDropNewest
+	}
+
+	b := &Buffer[T]{
+		C:        make(chan T, size),
+		stage:    stage,
+		output:   output,
+		overflow: overflow,
+	}
+
+	buffersLock.Lock()
+	defer buffersLock.Unlock()
+	buffers[b] = struct{}{}
+	return b
+}
+
+// Push adds v to the buffer according to the overflow policy, it reports
+// whether v was added.
+func (b *Buffer[T]) Push(v T) bool {
+	switch b.overflow {
+	case OverflowBlock:
+		b.C <- v
+		return true
+	case OverflowDropOldest:
+		for {
+			select {
+			case b.C <- v:
+				return true
+			default:
+			}
+			select {
+			case <-b.C:
+				b.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case b.C <- v:
+			return true
+		default:
+			b.dropped.Add(1)
+			return false
+		}
+	}
+}
+
+// Close stops reporting the buffer in BufferStats, the channel is left open
+// for the pending readers.
+func (b *Buffer[T]) Close() {
+	buffersLock.Lock()
+	defer buffersLock.Unlock()
+	delete(buffers, b)
+}
+
+func (b *Buffer[T]) stat() BufferStat {
+	return BufferStat{
+		Stage:    b.stage,
+		Output:   b.output,
+		Depth:    len(b.C),
+		Capacity: cap(b.C),
+		Dropped:  b.dropped.Load(),
+	}
+}
+
+// BufferStats returns the state of the buffers of the pipeline, sorted by
+// stage and output.
+func BufferStats() []BufferStat {
+	buffersLock.Lock()
+	s := make([]BufferStat, 0, len(buffers))
+	for b := range buffers {
+		s = append(s, b.stat())
+	}
+	buffersLock.Unlock()
+
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].Stage != s[j].Stage {
+			return s[i].Stage < s[j].Stage
+		}
+		return s[i].Output < s[j].Output
+	})
+	return s
+}
@@ -0,0 +1,68 @@
+package outputs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestBufferOverflow(t *testing.T) {
+	b := NewBuffer[int](types.BufferConfig{Size: 2}, 10, OutputAlertsStage, "newest")
+	defer b.Close()
+	require.True(t, b.Push(1))
+	require.True(t, b.Push(2))
+	require.False(t, b.Push(3))
+	require.Equal(t, 1, <-b.C)
+	require.Equal(t, 2, <-b.C)
+
+	b = NewBuffer[int](types.BufferConfig{Size: 2, Overflow: OverflowDropOldest}, 10, OutputAlertsStage, "oldest")
+	defer b.Close()
+	require.True(t, b.Push(1))
+	require.True(t, b.Push(2))
+	require.True(t, b.Push(3))
+	require.Equal(t, 2, <-b.C)
+	require.Equal(t, 3, <-b.C)
+
+	b = NewBuffer[int](types.BufferConfig{Size: 1, Overflow: OverflowBlock}, 10, OutputAlertsStage, "block")
+	defer b.Close()
+	require.True(t, b.Push(1))
+	pushed := make(chan bool)
+	go func() { pushed <- b.Push(2) }()
+	select {
+	case <-pushed:
+		t.Fatal("push to a full buffer must block")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Equal(t, 1, <-b.C)
+	require.True(t, <-pushed)
+	require.Equal(t, 2, <-b.C)
+
+	b = NewBuffer[int](types.BufferConfig{}, 10, RelayLogsStage, "")
+	require.Equal(t, 10, cap(b.C))
+	b.Close()
+}
+
+func TestBufferStats(t *testing.T) {
+	b := NewBuffer[int](types.BufferConfig{Size: 1}, 10, OutputLogsStage, "stats")
+	b.Push(1)
+	b.Push(2)
+
+	stat := func() (BufferStat, bool) {
+		for _, s := range BufferStats() {
+			if s.Stage == OutputLogsStage && s.Output == "stats" {
+				return s, true
+			}
+		}
+		return BufferStat{}, false
+	}
+	s, ok := stat()
+	require.True(t, ok)
+	require.Equal(t, BufferStat{Stage: OutputLogsStage, Output: "stats", Depth: 1, Capacity: 1, Dropped: 1}, s)
+
+	b.Close()
+	_, ok = stat()
+	require.False(t, ok)
+}
@@ -3,11 +3,11 @@ package outputs
 import (
 	"bytes"
 	"compress/gzip"
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -16,6 +16,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	pb "github.com/kubearmor/KubeArmor/protobuf"
 
@@ -91,20 +92,85 @@ const MutualTLSCacertFilename = "/ca.crt"
 const HttpPost = "POST"
 const HttpPut = "PUT"
 
-// Headers to add to the client before sending the request
-type Header struct {
-	Key   string
-	Value string
+// RequestOption sets a parameter of a single request, on top of the defaults
+// of the Client
+type RequestOption func(r *http.Request)
+
+// WithHeader adds an HTTP header to the request.
+func WithHeader(key, value string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Add(key, value)
+	}
+}
+
+// WithHeaders adds HTTP headers to the request.
+func WithHeaders(headers map[string]string) RequestOption {
+	return func(r *http.Request) {
+		for k, v := range headers {
+			r.Header.Add(k, v)
+		}
+	}
+}
+
+// WithBasicAuth adds an HTTP Basic Authentication compliant header to the
+// request.
+func WithBasicAuth(username, password string) RequestOption {
+	// Check out RFC7617 for the specifics on this code.
+	// https://datatracker.ietf.org/doc/html/rfc7617
+	// This might break I18n, but we can cross that bridge when we come to it.
+	userPass := username + ":" + password
+	b64UserPass := base64.StdEncoding.EncodeToString([]byte(userPass))
+	return WithHeader(AuthorizationHeaderKey, "Basic "+b64UserPass)
+}
+
+// WithContentType replaces the Content-Type of the Client for the request.
+func WithContentType(contentType string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set(ContentTypeHeaderKey, contentType)
+	}
+}
+
+// WithEndpointURL sends the request to the URL instead of the EndpointURL of
+// the Client.
+func WithEndpointURL(u *url.URL) RequestOption {
+	return func(r *http.Request) {
+		r.URL = u
+		r.Host = u.Host
+	}
+}
+
+type responseBodyKey struct{}
+
+// WithResponseBody stores the body of the response in body once the request
+// succeeded.
+func WithResponseBody(body *[]byte) RequestOption {
+	return func(r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), responseBodyKey{}, body))
+	}
+}
+
+type noRetriesKey struct{}
+
+// WithoutRetries sends the request once, whatever the retry policy of the
+// client. A failure worth another attempt is returned as such, for the caller
+// to retry it with its own policy.
+func WithoutRetries() RequestOption {
+	return func(r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), noRetriesKey{}, true))
+	}
 }
 
 // Client communicates with the different API.
 type Client struct {
-	OutputType              string
+	OutputType string
+	// Instance is the named instance of the output, empty for the default one
+	Instance                string
 	EndpointURL             *url.URL
 	MutualTLSEnabled        bool
 	CheckCert               bool
-	HeaderList              []Header
 	ContentType             string
+	Retry                   types.RetryConfig
+	HTTPClient              types.HTTPClientConfig
 	Config                  *types.Configuration
 	Stats                   *types.Statistics
 	PromStats               *types.PromStatistics
@@ -113,8 +179,14 @@ type Client struct {
 	DogstatsdClient         *statsd.Client
 	GCPTopicClient          *pubsub.Topic
 	GCPCloudFunctionsClient *gcpfunctions.CloudFunctionsClient
-	// FIXME: this lock requires a per-output usage lock currently if headers are used -- needs to be refactored
-	httpClientLock sync.Mutex
+	// httpClient is created on first use by getHTTPClient
+	httpClient         *http.Client
+	httpClientInitLock sync.Mutex
+	mutualTLSFiles     *mutualTLSFiles
+	// securityLakeLock serializes the uploads of the Security Lake batches
+	securityLakeLock sync.Mutex
+	// alertmanagerRates counts the events of the policies for Alertmanager
+	alertmanagerRates *eventRates
 
 	GCSStorageClient  *storage.Client
 	KafkaProducer     *kafka.Writer
@@ -130,6 +202,11 @@ type Client struct {
 	// connection
 	Conn *grpc.ClientConn
 
+	// relay
+	RelayName   string
+	ClusterName string
+	RelayStats  *expvar.Map
+
 	// alerts
 	AlertStream pb.LogService_WatchAlertsClient
 
@@ -160,21 +237,23 @@ func NewClient(outputType string, defaultEndpointURL string, mutualTLSEnabled bo
 		log.Printf("[ERROR] : %v - %v\n", outputType, err.Error())
 		return nil, ErrClientCreation
 	}
-	return &Client{OutputType: outputType, EndpointURL: endpointURL, MutualTLSEnabled: mutualTLSEnabled, CheckCert: checkCert, HeaderList: []Header{}, ContentType: DefaultContentType, Config: config, Stats: stats, PromStats: promStats, StatsdClient: statsdClient, DogstatsdClient: dogstatsdClient}, nil
+	return &Client{OutputType: outputType, EndpointURL: endpointURL, MutualTLSEnabled: mutualTLSEnabled, CheckCert: checkCert, ContentType: DefaultContentType, Config: config, Stats: stats, PromStats: promStats, StatsdClient: statsdClient, DogstatsdClient: dogstatsdClient}, nil
 }
 
-// Post sends event (payload) to Output with POST http method.
-func (c *Client) Post(payload interface{}) error {
-	return c.sendRequest("POST", payload)
+// Post sends event (payload) to Output with POST http method. The options set
+// the headers, the content type or the URL of this request only, the Client is
+// not modified so it can be shared by concurrent senders.
+func (c *Client) Post(payload interface{}, opts ...RequestOption) error {
+	return c.sendRequest("POST", payload, opts...)
 }
 
 // Put sends event (payload) to Output with PUT http method.
-func (c *Client) Put(payload interface{}) error {
-	return c.sendRequest("PUT", payload)
+func (c *Client) Put(payload interface{}, opts ...RequestOption) error {
+	return c.sendRequest("PUT", payload, opts...)
 }
 
 // Post sends event (payload) to Output.
-func (c *Client) sendRequest(method string, payload interface{}) error {
+func (c *Client) sendRequest(method string, payload interface{}, opts ...RequestOption) error {
 	// defer + recover to catch panic if output doesn't respond
 	defer func() {
 		if err := recover(); err != nil {
@@ -184,6 +263,12 @@ func (c *Client) sendRequest(method string, payload interface{}) error {
 
 	body := new(bytes.Buffer)
 	switch payload.(type) {
+	case []byte:
+		// bulk payloads encoded by the output, as NDJSON or line protocol
+		body.Write(payload.([]byte))
+		if c.Config.Debug {
+			log.Printf("[DEBUG] : %v payload : %v\n", c.OutputType, body)
+		}
 	case influxdbPayload:
 		fmt.Fprintf(body, "%v", payload)
 		if c.Config.Debug {
@@ -210,79 +295,63 @@ func (c *Client) sendRequest(method string, payload interface{}) error {
 		}
 	}
 
-	customTransport := http.DefaultTransport.(*http.Transport).Clone()
+	client := c.getHTTPClient()
 
-	if c.MutualTLSEnabled {
-		// Load client cert
-		var MutualTLSClientCertPath, MutualTLSClientKeyPath, MutualTLSClientCaCertPath string
-		if c.Config.MutualTLSClient.CertFile != "" {
-			MutualTLSClientCertPath = c.Config.MutualTLSClient.CertFile
-		} else {
-			MutualTLSClientCertPath = c.Config.MutualTLSFilesPath + MutualTLSClientCertFilename
-		}
-		if c.Config.MutualTLSClient.KeyFile != "" {
-			MutualTLSClientKeyPath = c.Config.MutualTLSClient.KeyFile
-		} else {
-			MutualTLSClientKeyPath = c.Config.MutualTLSFilesPath + MutualTLSClientKeyFilename
-		}
-		if c.Config.MutualTLSClient.CaCertFile != "" {
-			MutualTLSClientCaCertPath = c.Config.MutualTLSClient.CaCertFile
-		} else {
-			MutualTLSClientCaCertPath = c.Config.MutualTLSFilesPath + MutualTLSCacertFilename
-		}
-		cert, err := tls.LoadX509KeyPair(MutualTLSClientCertPath, MutualTLSClientKeyPath)
-		if err != nil {
-			log.Printf("[ERROR] : %v - %v\n", c.OutputType, err.Error())
-		}
-
-		// Load CA cert
-		caCert, err := ioutil.ReadFile(MutualTLSClientCaCertPath)
-		if err != nil {
-			log.Printf("[ERROR] : %v - %v\n", c.OutputType, err.Error())
-		}
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(caCert)
-		customTransport.TLSClientConfig = &tls.Config{
-			Certificates: []tls.Certificate{cert},
-			RootCAs:      caCertPool,
-			MinVersion:   tls.VersionTLS12,
-		}
-	} else {
-		// With MutualTLS enabled, the check cert flag is ignored
-		if !c.CheckCert {
-			// #nosec G402 This is only set as a result of explicit configuration
-			customTransport.TLSClientConfig = &tls.Config{
-				InsecureSkipVerify: true,
-			}
-		}
-	}
-	client := &http.Client{
-		Transport: customTransport,
-	}
-
-	req, err := http.NewRequest(method, c.EndpointURL.String(), body)
+	req, err := http.NewRequest(method, c.EndpointURL.String(), bytes.NewReader(body.Bytes()))
 	if err != nil {
 		log.Printf("[ERROR] : %v - %v\n", c.OutputType, err.Error())
+		return err
 	}
 
 	req.Header.Add(ContentTypeHeaderKey, c.ContentType)
 	req.Header.Add(UserAgentHeaderKey, UserAgentHeaderValue)
+	for _, opt := range opts {
+		opt(req)
+	}
 
-	for _, headerObj := range c.HeaderList {
-		req.Header.Add(headerObj.Key, headerObj.Value)
+	maxAttempts := c.Retry.MaxAttempts
+	noRetries, _ := req.Context().Value(noRetriesKey{}).(bool)
+	if noRetries {
+		maxAttempts = 1
 	}
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			req.Body, _ = req.GetBody()
+		}
+		status, retryAfter, err := c.doRequest(client, req)
+		retryable := err != nil && c.retryable(status, err)
+		if err == nil || attempt >= maxAttempts || !retryable {
+			if err != nil && status != 0 && !retryable {
+				err = &permanentError{err: err}
+			}
+			if noRetries && retryable {
+				err = &retryableError{err: err}
+			}
+			if err != nil && attempt > 1 {
+				return &retriesError{err: err, attempts: attempt}
+			}
+			return err
+		}
+		delay := c.retryDelay(attempt, retryAfter)
+		c.logRetry(attempt, delay, err)
+		c.countRetry()
+		time.Sleep(delay)
+	}
+}
 
+// doRequest sends the request, it returns the status code of the response, 0
+// if there is none, and the delay asked by its Retry-After header.
+func (c *Client) doRequest(client *http.Client, req *http.Request) (int, time.Duration, error) {
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Printf("[ERROR] : %v - %v\n", c.OutputType, err.Error())
 		go c.CountMetric("outputs", 1, []string{"output:" + strings.ToLower(c.OutputType), "status:connectionrefused"})
-		return err
+		return 0, 0, err
 	}
 	defer resp.Body.Close()
 
-	// Clear out headers - they will be set for the next request.
-	c.HeaderList = []Header{}
 	go c.CountMetric("outputs", 1, []string{"output:" + strings.ToLower(c.OutputType), "status:" + strings.ToLower(http.StatusText(resp.StatusCode))})
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
 
 	switch resp.StatusCode {
 	case http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent: //200, 201, 202, 204
@@ -291,54 +360,42 @@ func (c *Client) sendRequest(method string, payload interface{}) error {
 		if ot := c.OutputType; ot == Kubeless || ot == Openfaas || ot == Fission {
 			log.Printf("[INFO]  : %v - Function Response : %v\n", ot, string(body))
 		}
-		return nil
+		if b, ok := req.Context().Value(responseBodyKey{}).(*[]byte); ok {
+			*b = body
+		}
+		return resp.StatusCode, 0, nil
 	case http.StatusBadRequest: //400
 		body, _ := ioutil.ReadAll(resp.Body)
 		log.Printf("[ERROR] : %v - %v (%v): %v\n", c.OutputType, ErrHeaderMissing, resp.StatusCode, string(body))
-		return ErrHeaderMissing
+		return resp.StatusCode, retryAfter, ErrHeaderMissing
 	case http.StatusUnauthorized: //401
 		body, _ := ioutil.ReadAll(resp.Body)
 		log.Printf("[ERROR] : %v - %v (%v): %v\n", c.OutputType, ErrClientAuthenticationError, resp.StatusCode, string(body))
-		return ErrClientAuthenticationError
+		return resp.StatusCode, retryAfter, ErrClientAuthenticationError
 	case http.StatusForbidden: //403
 		body, _ := ioutil.ReadAll(resp.Body)
 		log.Printf("[ERROR] : %v - %v (%v): %v\n", c.OutputType, ErrForbidden, resp.StatusCode, string(body))
-		return ErrForbidden
+		return resp.StatusCode, retryAfter, ErrForbidden
 	case http.StatusNotFound: //404
 		body, _ := ioutil.ReadAll(resp.Body)
 		log.Printf("[ERROR] : %v - %v (%v): %v\n", c.OutputType, ErrNotFound, resp.StatusCode, string(body))
-		return ErrNotFound
+		return resp.StatusCode, retryAfter, ErrNotFound
 	case http.StatusUnprocessableEntity: //422
 		body, _ := ioutil.ReadAll(resp.Body)
 		log.Printf("[ERROR] : %v - %v (%v): %v\n", c.OutputType, ErrUnprocessableEntityError, resp.StatusCode, string(body))
-		return ErrUnprocessableEntityError
+		return resp.StatusCode, retryAfter, ErrUnprocessableEntityError
 	case http.StatusTooManyRequests: //429
 		body, _ := ioutil.ReadAll(resp.Body)
 		log.Printf("[ERROR] : %v - %v (%v): %v\n", c.OutputType, ErrTooManyRequest, resp.StatusCode, string(body))
-		return ErrTooManyRequest
+		return resp.StatusCode, retryAfter, ErrTooManyRequest
 	case http.StatusInternalServerError: //500
 		log.Printf("[ERROR] : %v - %v (%v)\n", c.OutputType, ErrTooManyRequest, resp.StatusCode)
-		return ErrInternalServer
+		return resp.StatusCode, retryAfter, ErrInternalServer
 	case http.StatusBadGateway: //502
 		log.Printf("[ERROR] : %v - %v (%v)\n", c.OutputType, ErrTooManyRequest, resp.StatusCode)
-		return ErrBadGateway
+		return resp.StatusCode, retryAfter, ErrBadGateway
 	default:
 		log.Printf("[ERROR] : %v - unexpected Response  (%v)\n", c.OutputType, resp.StatusCode)
-		return errors.New(resp.Status)
+		return resp.StatusCode, retryAfter, errors.New(resp.Status)
 	}
 }
-
-// BasicAuth adds an HTTP Basic Authentication compliant header to the Client.
-func (c *Client) BasicAuth(username, password string) {
-	// Check out RFC7617 for the specifics on this code.
-	// https://datatracker.ietf.org/doc/html/rfc7617
-	// This might break I18n, but we can cross that bridge when we come to it.
-	userPass := username + ":" + password
-	b64UserPass := base64.StdEncoding.EncodeToString([]byte(userPass))
-	c.AddHeader(AuthorizationHeaderKey, "Basic "+b64UserPass)
-}
-
-// AddHeader adds an HTTP Header to the Client.
-func (c *Client) AddHeader(key, value string) {
-	c.HeaderList = append(c.HeaderList, Header{Key: key, Value: value})
-}
@@ -16,7 +16,9 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -74,7 +76,7 @@ func TestNewClient(t *testing.T) {
 	stats := &types.Statistics{}
 	promStats := &types.PromStatistics{}
 
-	testClientOutput := Client{OutputType: "test", EndpointURL: u, MutualTLSEnabled: false, CheckCert: true, HeaderList: []Header{}, ContentType: "application/json; charset=utf-8", Config: config, Stats: stats, PromStats: promStats}
+	testClientOutput := Client{OutputType: "test", EndpointURL: u, MutualTLSEnabled: false, CheckCert: true, ContentType: "application/json; charset=utf-8", Config: config, Stats: stats, PromStats: promStats}
 	_, err := NewClient("test", "localhost/%*$¨^!/:;", false, true, config, stats, promStats, nil, nil)
 	require.NotNil(t, err)
 
@@ -122,7 +124,11 @@ func TestPost(t *testing.T) {
 		require.NotEmpty(t, nc)
 
 		errPost := nc.Post("")
-		require.Equal(t, errPost, j)
+		if j == nil {
+			require.Nil(t, errPost)
+			continue
+		}
+		require.ErrorIs(t, errPost, j)
 	}
 }
 
@@ -136,9 +142,7 @@ func TestAddHeader(t *testing.T) {
 	require.Nil(t, err)
 	require.NotEmpty(t, nc)
 
-	nc.AddHeader(headerKey, headerVal)
-
-	nc.Post("")
+	nc.Post("", WithHeader(headerKey, headerVal))
 }
 
 func TestAddBasicAuth(t *testing.T) {
@@ -187,29 +191,62 @@ func TestAddBasicAuth(t *testing.T) {
 	require.Nil(t, err)
 	require.NotEmpty(t, nc)
 
-	nc.BasicAuth(username, password)
-
-	nc.Post("")
+	nc.Post("", WithBasicAuth(username, password))
 }
 
 func TestHeadersResetAfterReq(t *testing.T) {
 	headerKey, headerVal := http.CanonicalHeaderKey("key"), "val"
+	headers := make(chan http.Header, 2)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		passedList := r.Header[headerKey]
-		require.Equal(t, 1, len(passedList), "Expected %v to have 1 element", passedList)
+		headers <- r.Header.Clone()
 	}))
+	defer ts.Close()
 
 	nc, err := NewClient("", ts.URL, false, true, &types.Configuration{}, &types.Statistics{}, &types.PromStatistics{}, nil, nil)
 	require.Nil(t, err)
 	require.NotEmpty(t, nc)
 
-	nc.AddHeader(headerKey, headerVal)
+	require.Nil(t, nc.Post("", WithHeader(headerKey, headerVal), WithHeader("X-First", "1")))
+	require.Nil(t, nc.Post("", WithHeader(headerKey, headerVal)))
 
-	nc.Post("")
+	first := <-headers
+	require.Equal(t, []string{headerVal}, first[headerKey])
+	require.Equal(t, "1", first.Get("X-First"))
+	second := <-headers
+	require.Equal(t, []string{headerVal}, second[headerKey], "Expected %v to have 1 element", second[headerKey])
+	require.Empty(t, second.Values("X-First"), "the headers of the first request must not be sent with the second")
+}
 
-	nc.AddHeader(headerKey, headerVal)
+func TestConcurrentRequests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Values("X-Id")
+		if len(id) != 1 || "/"+id[0] != r.URL.Path || r.Header.Get(ContentTypeHeaderKey) != "text/"+id[0] {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
 
-	nc.Post("")
+	nc, err := NewClient("", ts.URL, false, true, &types.Configuration{}, &types.Statistics{}, &types.PromStatistics{}, nil, nil)
+	require.Nil(t, err)
+	nc.Retry.MaxAttempts = 1
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			u, _ := url.Parse(ts.URL + "/" + id)
+			errs <- nc.Post("", WithEndpointURL(u), WithHeader("X-Id", id), WithContentType("text/"+id))
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.Nil(t, err)
+	}
+	require.Equal(t, ts.URL, nc.EndpointURL.String(), "the client must not be modified")
+	require.Equal(t, DefaultContentType, nc.ContentType)
 }
 
 func TestMutualTlsPost(t *testing.T) {
@@ -4,12 +4,27 @@ import (
 	"bytes"
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Cliq",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Cliq.WebhookURL != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Cliq.MinimumPriority },
+		New:             newCliqClient,
+		Send:            (*Client).CliqPost,
+	})
+}
+
+func newCliqClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Cliq", config.Cliq.WebhookURL, config.Cliq.MutualTLS, config.Cliq.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // Cliq API reference: https://www.zoho.com/cliq/help/restapi/v2/
 
 // Cliq constants
@@ -153,69 +168,22 @@ func newCliqPayload(kubearmorpayload types.KubearmorPayload, config *types.Confi
 }
 
 // CliqPost posts event to cliq
-func (c *Client) CliqPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) CliqPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Cliq.Add(Total, 1)
 
-	c.httpClientLock.Lock()
-	defer c.httpClientLock.Unlock()
-	c.AddHeader(ContentTypeHeaderKey, "application/json")
-	err := c.Post(newCliqPayload(kubearmorpayload, c.Config))
+	err := c.Post(newCliqPayload(kubearmorpayload, c.Config), WithContentType("application/json"))
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:cliq", "status:error"})
 		c.Stats.Cliq.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "cliq", "status": Error}).Inc()
 		log.Printf("[ERROR] : Cliq - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:cliq", "status:ok"})
 	c.Stats.Cliq.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "cliq", "status": OK}).Inc()
-}
-
-func (c *Client) WatchCliqPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.CliqPost(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchCliqPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.CliqPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
@@ -5,14 +5,29 @@ import (
 	"log"
 	"time"
 
+	"github.com/DataDog/datadog-go/statsd"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
-	"github.com/google/uuid"
 
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "CloudEvents",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.CloudEvents.Address != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.CloudEvents.MinimumPriority },
+		New:             newCloudEventsClient,
+		Send:            (*Client).CloudEventsSend,
+	})
+}
+
+func newCloudEventsClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("CloudEvents", config.CloudEvents.Address, config.CloudEvents.MutualTLS, config.CloudEvents.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // CloudEventsSend produces a CloudEvent and sends to the CloudEvents consumers.
-func (c *Client) CloudEventsSend(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) CloudEventsSend(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.CloudEvents.Add(Total, 1)
 
 	if c.CloudEventsClient == nil {
@@ -20,7 +35,7 @@ func (c *Client) CloudEventsSend(kubearmorpayload types.KubearmorPayload) {
 		if err != nil {
 			go c.CountMetric(Outputs, 1, []string{"output:cloudevents", "status:error"})
 			log.Printf("[ERROR] : CloudEvents - NewDefaultClient : %v\n", err)
-			return
+			return err
 		}
 		c.CloudEventsClient = client
 	}
@@ -50,7 +65,7 @@ func (c *Client) CloudEventsSend(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.CloudEvents.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "cloudevents", "status": Error}).Inc()
 		log.Printf("[ERROR] : CloudEvents - %v\n", result)
-		return
+		return result
 	}
 
 	// Setting the success status
@@ -58,50 +73,6 @@ func (c *Client) CloudEventsSend(kubearmorpayload types.KubearmorPayload) {
 	c.Stats.CloudEvents.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "cloudevents", "status": OK}).Inc()
 	log.Printf("[INFO]  : CloudEvents - Send OK\n")
-}
-
-func (c *Client) WatchCloudEventsSendAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.CloudEventsSend(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchCloudEventsSendLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.CloudEventsSend(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
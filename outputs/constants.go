@@ -18,8 +18,15 @@ const (
 	Total    string = "total"
 	Rejected string = "rejected"
 	Accepted string = "accepted"
+	Filtered string = "filtered"
 	Outputs  string = "outputs"
 
+	DeadLettered   string = "deadletter"
+	Deduplicated   string = "deduplicated"
+	Sampled        string = "sampled"
+	RateLimited    string = "ratelimited"
+	ShortCircuited string = "shortcircuited"
+
 	Rule      string = "rule"
 	Priority  string = "priority"
 	Source    string = "source"
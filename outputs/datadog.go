@@ -1,17 +1,38 @@
 package outputs
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
-	"time"
+	"net/url"
+	"strings"
+
+	"github.com/DataDog/datadog-go/statsd"
 
-	"github.com/google/uuid"
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Datadog",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Datadog.APIKey != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Datadog.MinimumPriority },
+		New:             newDatadogClient,
+		Send:            (*Client).DatadogPost,
+		SendBatch:       (*Client).DatadogPostLogs,
+	})
+}
+
+func newDatadogClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Datadog", config.Datadog.Host+DatadogPath+"?api_key="+config.Datadog.APIKey, config.Datadog.MutualTLS, config.Datadog.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 const (
 	// DatadogPath is the path of Datadog's event API
 	DatadogPath string = "/api/v1/events"
+	// DatadogLogsPath is the path of Datadog's logs intake API
+	DatadogLogsPath string = "/api/v2/logs"
 )
 
 type datadogPayload struct {
@@ -53,8 +74,34 @@ func newDatadogPayload(kubearmorpayload types.KubearmorPayload) datadogPayload {
 	return d
 }
 
+type datadogLog struct {
+	Source   string `json:"ddsource"`
+	Tags     string `json:"ddtags,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Service  string `json:"service"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+}
+
+func newDatadogLog(kubearmorpayload types.KubearmorPayload) datadogLog {
+	d := newDatadogPayload(kubearmorpayload)
+	tags := make([]string, len(d.Tags))
+	for i, tag := range d.Tags {
+		tags[i] = strings.TrimSpace(tag)
+	}
+	message, _ := json.Marshal(kubearmorpayload)
+	return datadogLog{
+		Source:   d.SourceType,
+		Tags:     strings.Join(tags, ","),
+		Hostname: kubearmorpayload.Hostname,
+		Service:  d.SourceType,
+		Status:   d.AlertType,
+		Message:  string(message),
+	}
+}
+
 // DatadogPost posts event to Datadog
-func (c *Client) DatadogPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) DatadogPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Datadog.Add(Total, 1)
 
 	err := c.Post(newDatadogPayload(kubearmorpayload))
@@ -63,56 +110,41 @@ func (c *Client) DatadogPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Datadog.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "datadog", "status": Error}).Inc()
 		log.Printf("[ERROR] : Datadog - %v\n", err)
-		return
+		return err
 	}
 
 	go c.CountMetric(Outputs, 1, []string{"output:datadog", "status:ok"})
 	c.Stats.Datadog.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "datadog", "status": OK}).Inc()
-}
-
-func (c *Client) WatchDatadogPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.DatadogPost(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
 
 	return nil
 }
 
-func (c *Client) WatchDatadogPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
+// DatadogPostLogs posts events to the logs intake of Datadog, the events API
+// has no bulk endpoint
+func (c *Client) DatadogPostLogs(events []types.KubearmorPayload) error {
+	c.Stats.Datadog.Add(Total, int64(len(events)))
 
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.DatadogPost(resp)
+	logs := make([]datadogLog, len(events))
+	for i, kubearmorpayload := range events {
+		logs[i] = newDatadogLog(kubearmorpayload)
+	}
 
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
+	endpointURL, err := url.Parse(c.Config.Datadog.LogsHost + DatadogLogsPath)
+	if err == nil {
+		err = c.Post(logs, WithEndpointURL(endpointURL), WithHeader("DD-API-KEY", c.Config.Datadog.APIKey), WithoutRetries())
+	}
+	if err != nil {
+		go c.CountMetric(Outputs, int64(len(events)), []string{"output:datadog", "status:error"})
+		c.Stats.Datadog.Add(Error, int64(len(events)))
+		c.PromStats.Outputs.With(map[string]string{"destination": "datadog", "status": Error}).Add(float64(len(events)))
+		log.Printf("[ERROR] : Datadog - %v\n", err)
+		return err
 	}
 
+	go c.CountMetric(Outputs, int64(len(events)), []string{"output:datadog", "status:ok"})
+	c.Stats.Datadog.Add(OK, int64(len(events)))
+	c.PromStats.Outputs.With(map[string]string{"destination": "datadog", "status": OK}).Add(float64(len(events)))
+
 	return nil
 }
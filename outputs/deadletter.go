@@ -0,0 +1,150 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// DeadLetterFile is the target writing the dead letters to a file
+const DeadLetterFile string = "file"
+
+// DeadLetter is an event an output could not deliver, with the reason of the
+// failure. The dead letters are written as JSON lines.
+type DeadLetter struct {
+	Output   string                 `json:"output"`
+	Error    string                 `json:"error"`
+	Attempts int                    `json:"attempts"`
+	Time     time.Time              `json:"time"`
+	Event    types.KubearmorPayload `json:"event"`
+}
+
+// DeadLetterQueue receives the events the outputs could not deliver
+type DeadLetterQueue interface {
+	Write(deadLetter DeadLetter) error
+	Close() error
+}
+
+// NewDeadLetterQueue returns the dead-letter target of the configuration, a
+// rotating file or one of the outputs, nil if there is none.
+func NewDeadLetterQueue(config types.DeadLetterConfig, outputs []Output) (DeadLetterQueue, error) {
+	switch config.Target {
+	case "":
+		return nil, nil
+	case DeadLetterFile:
+		return openDeadLetterFile(config)
+	}
+	for _, o := range outputs {
+		if strings.EqualFold(o.Name(), config.Target) {
+			return &deadLetterOutput{output: o}, nil
+		}
+	}
+	return nil, fmt.Errorf("the output '%v' is not enabled", config.Target)
+}
+
+// deadLetterFile appends the dead letters to a file, rotated once it reaches
+// MaxSize. The rotated files are suffixed with .1 to .MaxBackups, .1 being the
+// newest.
+type deadLetterFile struct {
+	config types.DeadLetterConfig
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openDeadLetterFile(config types.DeadLetterConfig) (*deadLetterFile, error) {
+	d := &deadLetterFile{config: config}
+	if err := d.open(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *deadLetterFile) open() error {
+	f, err := os.OpenFile(d.config.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	d.file, d.size = f, info.Size()
+	return nil
+}
+
+func (d *deadLetterFile) Write(deadLetter DeadLetter) error {
+	line, err := json.Marshal(deadLetter)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.size > 0 && d.size+int64(len(line)) > int64(d.config.MaxSize)<<20 {
+		if err := d.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := d.file.Write(line)
+	d.size += int64(n)
+	return err
+}
+
+// rotate shifts the rotated files, dropping the oldest one, and starts a new
+// file.
+func (d *deadLetterFile) rotate() error {
+	if err := d.file.Close(); err != nil {
+		return err
+	}
+	if d.config.MaxBackups == 0 {
+		if err := os.Remove(d.config.File); err != nil {
+			return err
+		}
+		return d.open()
+	}
+	for i := d.config.MaxBackups - 1; i > 0; i-- {
+		if err := os.Rename(fmt.Sprintf("%v.%d", d.config.File, i), fmt.Sprintf("%v.%d", d.config.File, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(d.config.File, d.config.File+".1"); err != nil {
+		return err
+	}
+	return d.open()
+}
+
+func (d *deadLetterFile) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.file.Close()
+}
+
+// deadLetterOutput sends the dead letters to an output, the failure is added
+// to the fields of the event.
+type deadLetterOutput struct {
+	output Output
+}
+
+func (d *deadLetterOutput) Write(deadLetter DeadLetter) error {
+	event := deadLetter.Event
+	event.OutputFields = make(map[string]interface{}, len(deadLetter.Event.OutputFields)+4)
+	for k, v := range deadLetter.Event.OutputFields {
+		event.OutputFields[k] = v
+	}
+	event.OutputFields["DeadLetterOutput"] = deadLetter.Output
+	event.OutputFields["DeadLetterError"] = deadLetter.Error
+	event.OutputFields["DeadLetterAttempts"] = deadLetter.Attempts
+	event.OutputFields["DeadLetterTime"] = deadLetter.Time.Format(time.RFC3339Nano)
+	return d.output.Send(event)
+}
+
+func (d *deadLetterOutput) Close() error { return nil }
@@ -0,0 +1,101 @@
+package outputs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+	f, err := os.Open(path)
+	require.Nil(t, err)
+	defer f.Close()
+	var l []DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 2<<20)
+	for scanner.Scan() {
+		var d DeadLetter
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &d))
+		l = append(l, d)
+	}
+	return l
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	config := types.DeadLetterConfig{Target: DeadLetterFile, File: filepath.Join(t.TempDir(), "deadletter.jsonl"), MaxSize: 1, MaxBackups: 1}
+	deadLetters, err := NewDeadLetterQueue(config, nil)
+	require.Nil(t, err)
+
+	stats := &types.Statistics{DeadLetter: new(expvar.Map).Init()}
+	d := NewDispatcher(&types.Configuration{}, stats, &types.PromStatistics{})
+	d.DeadLetters = deadLetters
+
+	o := &testOutput{name: "failing", err: &retriesError{err: errors.New("bad gateway"), attempts: 3}}
+	d.forward(context.Background(), o, outputRoute{}, types.KubearmorPayload{Hostname: "node"})
+	require.Nil(t, deadLetters.Close())
+
+	l := readDeadLetters(t, config.File)
+	require.Len(t, l, 1)
+	require.Equal(t, "failing", l[0].Output)
+	require.Equal(t, "bad gateway", l[0].Error)
+	require.Equal(t, 3, l[0].Attempts)
+	require.False(t, l[0].Time.IsZero())
+	require.Equal(t, "node", l[0].Event.Hostname)
+	require.Equal(t, "1", stats.DeadLetter.Get("failing").String())
+}
+
+func TestDispatcherShortCircuited(t *testing.T) {
+	stats := &types.Statistics{DeadLetter: new(expvar.Map).Init(), ShortCircuited: new(expvar.Map).Init()}
+	d := NewDispatcher(&types.Configuration{}, stats, &types.PromStatistics{})
+
+	o := &testOutput{name: "open", err: ErrCircuitOpen}
+	d.forward(context.Background(), o, outputRoute{}, types.KubearmorPayload{})
+	require.Equal(t, "1", stats.ShortCircuited.Get("open").String(), "the events are counted without dead-letter target")
+	require.Nil(t, stats.DeadLetter.Get("open"))
+}
+
+func TestDeadLetterFileRotation(t *testing.T) {
+	config := types.DeadLetterConfig{Target: DeadLetterFile, File: filepath.Join(t.TempDir(), "deadletter.jsonl"), MaxSize: 1, MaxBackups: 2}
+	deadLetters, err := NewDeadLetterQueue(config, nil)
+	require.Nil(t, err)
+
+	// 400KB dead letters, the 1MB files hold 2 of them
+	for i := 0; i < 7; i++ {
+		require.Nil(t, deadLetters.Write(DeadLetter{Output: "test", Event: types.KubearmorPayload{Hostname: strings.Repeat("x", 400<<10)}}))
+	}
+	require.Nil(t, deadLetters.Close())
+
+	require.Len(t, readDeadLetters(t, config.File), 1)
+	require.Len(t, readDeadLetters(t, config.File+".1"), 2)
+	require.Len(t, readDeadLetters(t, config.File+".2"), 2)
+	_, err = os.Stat(config.File + ".3")
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestDeadLetterOutput(t *testing.T) {
+	target := &testOutput{name: "Target", received: make(chan types.KubearmorPayload, 1)}
+	_, err := NewDeadLetterQueue(types.DeadLetterConfig{Target: "unknown"}, []Output{target})
+	require.NotNil(t, err)
+
+	deadLetters, err := NewDeadLetterQueue(types.DeadLetterConfig{Target: "target"}, []Output{target})
+	require.Nil(t, err)
+	event := types.KubearmorPayload{OutputFields: map[string]interface{}{"PodName": "pod"}}
+	require.Nil(t, deadLetters.Write(DeadLetter{Output: "Slack", Error: "timeout", Attempts: 2, Event: event}))
+
+	p := <-target.received
+	require.Equal(t, "pod", p.OutputFields["PodName"])
+	require.Equal(t, "Slack", p.OutputFields["DeadLetterOutput"])
+	require.Equal(t, "timeout", p.OutputFields["DeadLetterError"])
+	require.Equal(t, 2, p.OutputFields["DeadLetterAttempts"])
+	require.Len(t, event.OutputFields, 1, "the original event must not be modified")
+}
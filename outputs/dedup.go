@@ -0,0 +1,153 @@
+package outputs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// maxDedupTick caps the delay between the checks of the windows, a window is
+// closed at most this late.
+const maxDedupTick = time.Second
+
+// dedupPolicy returns the deduplication settings of the output, the default
+// ones if the output has none of its own.
+func dedupPolicy(dedup types.DedupConfig, name string) types.DedupConfig {
+	if d, ok := dedup.Outputs[strings.ToLower(name)]; ok {
+		return d
+	}
+	dedup.Outputs = nil
+	return dedup
+}
+
+// dedupWindow is the window opened by the first occurrence of an event
+type dedupWindow struct {
+	first     types.KubearmorPayload
+	count     int
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// Deduplicator suppresses the repeats of the events, the events with the same
+// fields, during the window opened by their first occurrence. When a window
+// with repeats closes, a summary of the event is emitted.
+type Deduplicator struct {
+	window  time.Duration
+	fields  []string
+	summary func(kubearmorpayload types.KubearmorPayload)
+	lock    sync.Mutex
+	windows map[string]*dedupWindow
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewDeduplicator starts a Deduplicator calling summary with the summaries of
+// the events once their window closes.
+func NewDeduplicator(config types.DedupConfig, summary func(kubearmorpayload types.KubearmorPayload)) *Deduplicator {
+	d := &Deduplicator{
+		window:  time.Duration(config.Window) * time.Second,
+		fields:  config.FieldsList,
+		summary: summary,
+		windows: make(map[string]*dedupWindow),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Add reports whether the event is the first occurrence of the event in its
+// window and must be sent, its repeats are counted and suppressed.
+func (d *Deduplicator) Add(kubearmorpayload types.KubearmorPayload) bool {
+	key := d.key(kubearmorpayload)
+	now := time.Now()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if w, ok := d.windows[key]; ok {
+		w.count++
+		w.lastSeen = now
+		return false
+	}
+	d.windows[key] = &dedupWindow{first: kubearmorpayload, count: 1, firstSeen: now, lastSeen: now}
+	return true
+}
+
+// Close closes the windows left, their summaries are emitted before it
+// returns. No event must be added afterwards.
+func (d *Deduplicator) Close() {
+	close(d.done)
+	<-d.stopped
+}
+
+func (d *Deduplicator) run() {
+	defer close(d.stopped)
+
+	tick := d.window
+	if tick > maxDedupTick {
+		tick = maxDedupTick
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.closeWindows(now.Add(-d.window))
+		case <-d.done:
+			d.closeWindows(time.Time{})
+			return
+		}
+	}
+}
+
+// closeWindows closes the windows opened before the deadline, every window if
+// it is zero, and emits the summaries of the ones with repeats.
+func (d *Deduplicator) closeWindows(deadline time.Time) {
+	var closed []*dedupWindow
+	d.lock.Lock()
+	for key, w := range d.windows {
+		if deadline.IsZero() || !w.firstSeen.After(deadline) {
+			delete(d.windows, key)
+			if w.count > 1 {
+				closed = append(closed, w)
+			}
+		}
+	}
+	d.lock.Unlock()
+
+	for _, w := range closed {
+		d.summary(dedupSummary(w))
+	}
+}
+
+// key returns the values of the fields identifying the repeats of the event
+func (d *Deduplicator) key(kubearmorpayload types.KubearmorPayload) string {
+	vars := ruleVariables(kubearmorpayload)
+	var key strings.Builder
+	key.WriteString(kubearmorpayload.EventType)
+	for _, field := range d.fields {
+		v, ok := vars[field]
+		if !ok {
+			v = kubearmorpayload.OutputFields[field]
+		}
+		fmt.Fprintf(&key, "\x00%v", v)
+	}
+	return key.String()
+}
+
+// dedupSummary returns the first event of the window, with the number of its
+// occurrences and when the first and last ones were seen.
+func dedupSummary(w *dedupWindow) types.KubearmorPayload {
+	summary := w.first
+	summary.OutputFields = make(map[string]interface{}, len(w.first.OutputFields)+3)
+	for k, v := range w.first.OutputFields {
+		summary.OutputFields[k] = v
+	}
+	summary.OutputFields["DedupCount"] = w.count
+	summary.OutputFields["DedupFirstSeen"] = w.firstSeen.UTC().Format(time.RFC3339)
+	summary.OutputFields["DedupLastSeen"] = w.lastSeen.UTC().Format(time.RFC3339)
+	return summary
+}
@@ -0,0 +1,67 @@
+package outputs
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestDeduplicator(t *testing.T) {
+	summaries := make(chan types.KubearmorPayload, 10)
+	d := NewDeduplicator(types.DedupConfig{Window: 60, FieldsList: []string{"PolicyName", "PodName", "Hostname"}}, func(kubearmorpayload types.KubearmorPayload) {
+		summaries <- kubearmorpayload
+	})
+
+	event := func(policy, pod string) types.KubearmorPayload {
+		return types.KubearmorPayload{EventType: AlertEventType, Hostname: "node", OutputFields: map[string]interface{}{"PolicyName": policy, "PodName": pod, "PID": 1}}
+	}
+	require.True(t, d.Add(event("ksp", "a")))
+	require.False(t, d.Add(event("ksp", "a")), "a repeat is suppressed")
+	require.False(t, d.Add(event("ksp", "a")))
+	require.True(t, d.Add(event("ksp", "b")), "the events of another pod are not repeats")
+	require.True(t, d.Add(types.KubearmorPayload{EventType: LogEventType, Hostname: "node", OutputFields: map[string]interface{}{"PolicyName": "ksp", "PodName": "a"}}))
+
+	d.closeWindows(time.Now().Add(-time.Minute))
+	require.Len(t, summaries, 0, "the windows are still open")
+	d.closeWindows(time.Now())
+	require.Len(t, summaries, 1, "only the windows with repeats are summarized")
+	s := <-summaries
+	require.Equal(t, "a", s.OutputFields["PodName"])
+	require.Equal(t, 3, s.OutputFields["DedupCount"])
+	require.NotEmpty(t, s.OutputFields["DedupFirstSeen"])
+	require.NotEmpty(t, s.OutputFields["DedupLastSeen"])
+
+	require.True(t, d.Add(event("ksp", "a")), "a new window is opened once the previous one closed")
+	require.False(t, d.Add(event("ksp", "a")))
+	d.Close()
+	require.Len(t, summaries, 1, "the windows are summarized on close")
+	require.Equal(t, 2, (<-summaries).OutputFields["DedupCount"])
+}
+
+func TestDispatchDedup(t *testing.T) {
+	stats := &types.Statistics{Deduplicated: new(expvar.Map).Init()}
+	d := NewDispatcher(&types.Configuration{Dedup: types.DedupConfig{
+		Outputs: map[string]types.DedupConfig{"slack": {Window: 60, FieldsList: []string{"PolicyName"}}},
+	}}, stats, &types.PromStatistics{})
+	require.Nil(t, d.newDeduplicator(&testOutput{name: "other"}, outputRoute{}), "the deduplication is disabled")
+
+	o := &testOutput{name: "Slack", received: make(chan types.KubearmorPayload, 3)}
+	r := outputRoute{}
+	r.dedup = d.newDeduplicator(o, r)
+	require.NotNil(t, r.dedup)
+	for i := 0; i < 3; i++ {
+		d.forward(context.Background(), o, r, types.KubearmorPayload{OutputFields: map[string]interface{}{"PolicyName": "ksp"}})
+	}
+	require.Len(t, o.received, 1)
+	require.Equal(t, "2", stats.Deduplicated.Get("Slack").String())
+
+	r.dedup.Close()
+	require.Len(t, o.received, 2)
+	<-o.received
+	require.Equal(t, 3, (<-o.received).OutputFields["DedupCount"])
+}
@@ -3,12 +3,27 @@ package outputs
 import (
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Discord",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Discord.WebhookURL != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Discord.MinimumPriority },
+		New:             newDiscordClient,
+		Send:            (*Client).DiscordPost,
+	})
+}
+
+func newDiscordClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Discord", config.Discord.WebhookURL, config.Discord.MutualTLS, config.Discord.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 type discordPayload struct {
 	Content   string                `json:"content"`
 	AvatarURL string                `json:"avatar_url,omitempty"`
@@ -86,7 +101,7 @@ func newDiscordPayload(kubearmorpayload types.KubearmorPayload, config *types.Co
 }
 
 // DiscordPost posts events to discord
-func (c *Client) DiscordPost(kubearmor types.KubearmorPayload) {
+func (c *Client) DiscordPost(kubearmor types.KubearmorPayload) error {
 	c.Stats.Discord.Add(Total, 1)
 
 	err := c.Post(newDiscordPayload(kubearmor, c.Config))
@@ -95,56 +110,13 @@ func (c *Client) DiscordPost(kubearmor types.KubearmorPayload) {
 		c.Stats.Discord.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "discord", "status": Error}).Inc()
 		log.Printf("[ERROR] : Discord - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:discord", "status:ok"})
 	c.Stats.Discord.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "discord", "status": OK}).Inc()
-}
-
-func (c *Client) WatchDiscordAlerts() error {
-	uid := "Discord"
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	fmt.Println("discord running")
-	for AlertRunning {
-		select {
-		case resp := <-conn:
-			c.DiscordPost(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-	fmt.Println("discord stopped")
-	return nil
-}
-
-func (c *Client) WatchDiscordLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.DiscordPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
@@ -0,0 +1,463 @@
+package outputs
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// Dispatcher fans the events received from the relay out to the outputs.
+type Dispatcher struct {
+	Config    *types.Configuration
+	Stats     *types.Statistics
+	PromStats *types.PromStatistics
+	// DeadLetters receives the events the outputs could not deliver, if set
+	DeadLetters DeadLetterQueue
+
+	wg sync.WaitGroup
+}
+
+// outputRoute is the path of the events of an output which pass its filter,
+// its sampling, its deduplication and its rate limit: its queue on disk, its
+// batcher or its workers, the events are sent directly without them.
+type outputRoute struct {
+	rules   *Rules
+	sampler *Sampler
+	dedup   *Deduplicator
+	limiter *RateLimiter
+	queue   *Queue
+	batcher *Batcher[types.KubearmorPayload]
+	pool    *workerPool
+}
+
+// NewDispatcher returns a Dispatcher using the severity mapping of the configuration.
+func NewDispatcher(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics) *Dispatcher {
+	return &Dispatcher{
+		Config:    config,
+		Stats:     stats,
+		PromStats: promStats,
+	}
+}
+
+// Dispatch subscribes the output to the alert and log streams it supports,
+// to the logs only if Config.Log is set, and forwards every received event to
+// its Send function. When the context is canceled, the output is unsubscribed
+// and the events left in its queues are sent. If the queue on disk of the
+// output is enabled, the events are written to it and sent from it, the events
+// left are kept for the next start. The events the output fails to send are
+// written to DeadLetters. Without queue on disk, the events of the outputs
+// with a bulk API are sent in batches, and the events of the other outputs by
+// their workers, if they have several. The logs are sampled, the repeats of
+// the events are suppressed and the events are rate limited if these are
+// enabled.
+func (d *Dispatcher) Dispatch(ctx context.Context, o Output) {
+	r := outputRoute{rules: d.compileRules(o), sampler: newSampler(samplingPolicy(d.Config.Sampling, o.Name())), queue: d.openQueue(o)}
+	r.batcher = d.newBatcher(ctx, o, r.queue)
+	r.pool = d.newWorkerPool(o, r)
+	r.dedup = d.newDeduplicator(o, r)
+	r.limiter = d.newRateLimiter(ctx, o, r)
+
+	// the output is subscribed before Dispatch returns, so that it receives
+	// the events sent right after
+	var wg sync.WaitGroup
+	for _, eventType := range o.EventTypes() {
+		switch eventType {
+		case AlertEventType:
+			conn := d.subscribeAlerts(o)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				d.watch(ctx, o, r, conn, removeAlertStruct)
+			}()
+		case LogEventType:
+			if d.Config.Log {
+				conn := d.subscribeLogs(o)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer conn.Close()
+					d.watch(ctx, o, r, conn, removeLogStruct)
+				}()
+			}
+		}
+	}
+	if r.queue != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, o, r.queue)
+		}()
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		wg.Wait()
+		// the last summary of the rate limiter goes through the deduplication
+		if r.limiter != nil {
+			r.limiter.Close()
+		}
+		if r.dedup != nil {
+			r.dedup.Close()
+		}
+		if r.pool != nil {
+			r.pool.Close()
+		}
+		if r.batcher != nil {
+			r.batcher.Close()
+		}
+		if r.queue == nil {
+			return
+		}
+		if err := r.queue.Close(); err != nil {
+			log.Printf("[ERROR] : %v - Closing the queue failed: %v\n", o.Name(), err)
+		}
+	}()
+}
+
+// Wait waits for the outputs to send the events left in their queues, once
+// the context given to Dispatch is canceled.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// openQueue opens the queue on disk of the output if it is enabled, the
+// events are sent directly if it can't be opened.
+func (d *Dispatcher) openQueue(o Output) *Queue {
+	config := queuePolicy(d.Config.Queue, o.Name())
+	if !config.Enabled {
+		return nil
+	}
+	q, err := OpenQueue(config, o.Name())
+	if err != nil {
+		log.Printf("[ERROR] : %v - Opening the queue failed, the events are sent directly: %v\n", o.Name(), err)
+		return nil
+	}
+	if pending := q.stat(time.Now()).ReplayPending; pending != 0 {
+		log.Printf("[INFO]  : %v - %v events of the queue to replay\n", o.Name(), pending)
+	}
+	return q
+}
+
+// compileRules returns the rules of the output, which were checked with the
+// configuration.
+func (d *Dispatcher) compileRules(o Output) *Rules {
+	rules, err := CompileRules(rulesPolicy(d.Config.Rules, o.Name()))
+	if err != nil {
+		log.Printf("[ERROR] : %v - Rules - %v\n", o.Name(), err)
+	}
+	return rules
+}
+
+// newBatcher starts the batcher of the output if it has a bulk API and its
+// batches hold more than one event. The events of the queue on disk are sent
+// one at a time. The failed events of the batches are no longer retried once
+// the context is canceled.
+func (d *Dispatcher) newBatcher(ctx context.Context, o Output, q *Queue) *Batcher[types.KubearmorPayload] {
+	bo, ok := o.(BatchOutput)
+	if !ok {
+		return nil
+	}
+	config := batchPolicy(d.Config.Batch, o.Name())
+	if config.MaxSize <= 1 {
+		return nil
+	}
+	if q != nil {
+		log.Printf("[WARN]  : %v - The events of the queue are not sent in batches\n", o.Name())
+		return nil
+	}
+	return NewBatcher(config, eventSize, func(events []types.KubearmorPayload) {
+		d.sendBatch(ctx, bo, events)
+	})
+}
+
+// newDeduplicator starts the deduplication of the events of the output, nil
+// if it is disabled. The summaries of the repeats take the route of the events.
+func (d *Dispatcher) newDeduplicator(o Output, r outputRoute) *Deduplicator {
+	config := dedupPolicy(d.Config.Dedup, o.Name())
+	if config.Window <= 0 || len(config.FieldsList) == 0 {
+		return nil
+	}
+	return NewDeduplicator(config, func(kubearmorpayload types.KubearmorPayload) {
+		d.route(o, r, kubearmorpayload)
+	})
+}
+
+// newRateLimiter returns the rate limiter of the output, nil if it is
+// disabled. The summaries of the events dropped are forwarded like the events,
+// through the filters of the output but not through its rate limit.
+func (d *Dispatcher) newRateLimiter(ctx context.Context, o Output, r outputRoute) *RateLimiter {
+	config := rateLimitPolicy(d.Config.RateLimit, o.Name())
+	if config.Rate <= 0 {
+		return nil
+	}
+	return NewRateLimiter(config, func(kubearmorpayload types.KubearmorPayload) {
+		d.forward(ctx, o, r, kubearmorpayload)
+	})
+}
+
+// newWorkerPool starts the workers of the output, nil if it has a single one.
+// The events of the queue on disk and the batches are sent in order, by a
+// single worker.
+func (d *Dispatcher) newWorkerPool(o Output, r outputRoute) *workerPool {
+	config := workersPolicy(d.Config.Workers, o.Name())
+	if r.queue != nil || r.batcher != nil {
+		if config.Workers > 1 {
+			log.Printf("[WARN]  : %v - The events of the queue and the batches are sent by a single worker\n", o.Name())
+		}
+		return nil
+	}
+	return newWorkerPool(config, func(kubearmorpayload types.KubearmorPayload) {
+		if err := o.Send(kubearmorpayload); err != nil {
+			d.deadLetter(o, kubearmorpayload, err)
+		}
+	})
+}
+
+// subscribeAlerts returns the buffer of the output receiving the alerts
+func (d *Dispatcher) subscribeAlerts(o Output) *Buffer[types.KubearmorPayload] {
+	conn := NewBuffer[types.KubearmorPayload](d.Config.Buffers.Outputs, DefaultOutputBufferSize, OutputAlertsStage, o.Name())
+	addAlertStruct(o.Name(), conn)
+	return conn
+}
+
+// subscribeLogs returns the buffer of the output receiving the logs
+func (d *Dispatcher) subscribeLogs(o Output) *Buffer[types.KubearmorPayload] {
+	conn := NewBuffer[types.KubearmorPayload](d.Config.Buffers.Outputs, DefaultOutputBufferSize, OutputLogsStage, o.Name())
+	addLogStruct(o.Name(), conn)
+	return conn
+}
+
+// watch forwards the events of the buffer until the context is canceled, then
+// it unsubscribes the output and forwards what is left.
+func (d *Dispatcher) watch(ctx context.Context, o Output, r outputRoute, conn *Buffer[types.KubearmorPayload], unsubscribe func(uid string)) {
+	for {
+		select {
+		case <-ctx.Done():
+			unsubscribe(o.Name())
+			for {
+				select {
+				case resp := <-conn.C:
+					d.forward(ctx, o, r, resp)
+				default:
+					return
+				}
+			}
+		case resp := <-conn.C:
+			d.forward(ctx, o, r, resp)
+		}
+	}
+}
+
+// forward routes the event to the output, unless its priority is below the
+// minimum priority of the output, it doesn't match its rules, it is a log left
+// out by the sampling, it is a repeat of an event sent during the
+// deduplication window, or it is over the rate limit of the output.
+func (d *Dispatcher) forward(ctx context.Context, o Output, r outputRoute, kubearmorpayload types.KubearmorPayload) {
+	if d.filtered(o, kubearmorpayload) || !d.matched(o, r.rules, kubearmorpayload) {
+		d.count(o, d.Stats.Filtered, Filtered)
+		return
+	}
+	if !r.sampler.Keep(kubearmorpayload) {
+		d.count(o, d.Stats.Sampled, Sampled)
+		return
+	}
+	if r.dedup != nil && !r.dedup.Add(kubearmorpayload) {
+		d.count(o, d.Stats.Deduplicated, Deduplicated)
+		return
+	}
+	if r.limiter != nil && !r.limiter.Allow(ctx) {
+		d.count(o, d.Stats.RateLimited, RateLimited)
+		return
+	}
+	d.route(o, r, kubearmorpayload)
+}
+
+// count counts an event of the output with the status, in the stats and in
+// the Prometheus metrics
+func (d *Dispatcher) count(o Output, stats *expvar.Map, status string) {
+	if stats != nil {
+		stats.Add(o.Name(), 1)
+	}
+	if d.PromStats != nil && d.PromStats.Outputs != nil {
+		d.PromStats.Outputs.With(outputLabels(o.Name(), status)).Inc()
+	}
+}
+
+// route sends the event to the output, or to its queue, its batcher or its
+// workers if it has them.
+func (d *Dispatcher) route(o Output, r outputRoute, kubearmorpayload types.KubearmorPayload) {
+	switch {
+	case r.queue != nil:
+		if err := r.queue.Append(kubearmorpayload); err != nil {
+			log.Printf("[ERROR] : %v - Queueing the event failed: %v\n", o.Name(), err)
+			d.deadLetter(o, kubearmorpayload, err)
+		}
+	case r.batcher != nil:
+		r.batcher.Add(kubearmorpayload)
+	case r.pool != nil:
+		r.pool.Submit(kubearmorpayload)
+	default:
+		if err := o.Send(kubearmorpayload); err != nil {
+			d.deadLetter(o, kubearmorpayload, err)
+		}
+	}
+}
+
+// sendBatch sends the events in a single request, without the retries of the
+// client. The events of a failed batch worth another attempt, and the events
+// the destination rejected worth it, are sent again with the backoff of the
+// retry policy of the output and up to its attempts, then they are written to
+// DeadLetters like the other failed events. Once the context is canceled, the
+// failed events are written to DeadLetters without waiting for the backoff.
+func (d *Dispatcher) sendBatch(ctx context.Context, o BatchOutput, events []types.KubearmorPayload) {
+	retry := retryPolicy(d.Config.Retry, o.Name())
+	backoff := Backoff{
+		Base:   time.Duration(retry.BaseBackoff) * time.Millisecond,
+		Max:    time.Duration(retry.MaxBackoff) * time.Millisecond,
+		Jitter: retry.Jitter,
+	}
+	for attempt := 1; ; attempt++ {
+		err := o.SendBatch(events)
+		if err == nil {
+			return
+		}
+		var failures []BatchFailure
+		var batchErr *BatchError
+		if errors.As(err, &batchErr) {
+			failures = batchErr.Failures
+		} else {
+			failures = make([]BatchFailure, len(events))
+			for i := range events {
+				failures[i] = BatchFailure{Index: i, Err: err, Retryable: Retryable(err)}
+			}
+		}
+
+		var failed []types.KubearmorPayload
+		var errs []error
+		for _, f := range failures {
+			err := f.Err
+			if attempt > 1 {
+				err = &retriesError{err: f.Err, attempts: attempt}
+			}
+			if f.Retryable && attempt < retry.MaxAttempts {
+				failed = append(failed, events[f.Index])
+				errs = append(errs, err)
+				continue
+			}
+			d.deadLetter(o, events[f.Index], err)
+		}
+		if len(failed) == 0 {
+			return
+		}
+		delay := backoff.Delay(attempt)
+		log.Printf("[WARN]  : %v - %v events of the batch failed, retrying them in %v (attempt %v/%v)\n", o.Name(), len(failed), delay, attempt, retry.MaxAttempts)
+		select {
+		case <-ctx.Done():
+			log.Printf("[WARN]  : %v - Shutting down, the %v failed events of the batch are not retried\n", o.Name(), len(failed))
+			for i, kubearmorpayload := range failed {
+				d.deadLetter(o, kubearmorpayload, errs[i])
+			}
+			return
+		case <-time.After(delay):
+		}
+		events = failed
+	}
+}
+
+// deadLetter writes the event the output could not deliver to the dead-letter
+// target, if there is one. The events short-circuited by the circuit breaker
+// of the output are counted and logged, with or without target.
+func (d *Dispatcher) deadLetter(o Output, kubearmorpayload types.KubearmorPayload, err error) {
+	if errors.Is(err, ErrCircuitOpen) {
+		d.count(o, d.Stats.ShortCircuited, ShortCircuited)
+		if d.DeadLetters == nil {
+			log.Printf("[ERROR] : %v - Circuit breaker open, the event is dropped\n", o.Name())
+		}
+	}
+	if d.DeadLetters == nil {
+		return
+	}
+	deadLetter := DeadLetter{
+		Output:   o.Name(),
+		Error:    err.Error(),
+		Attempts: Attempts(err),
+		Time:     time.Now().UTC(),
+		Event:    kubearmorpayload,
+	}
+	if err := d.DeadLetters.Write(deadLetter); err != nil {
+		log.Printf("[ERROR] : %v - Writing the dead letter failed: %v\n", o.Name(), err)
+		return
+	}
+	d.count(o, d.Stats.DeadLetter, DeadLettered)
+}
+
+// deliver sends the events of the queue to the output in order, an event is
+// removed from the queue once sent. A failed event is sent again after a
+// backoff, up to the attempts of the retry policy of the output, then it is
+// written to DeadLetters and removed, at once if the destination rejected it
+// for good. The events short-circuited by the circuit breaker of the output,
+// like the failed reads of the queue, are tried again after a backoff until
+// the context is canceled.
+func (d *Dispatcher) deliver(ctx context.Context, o Output, q *Queue) {
+	maxAttempts := retryPolicy(d.Config.Retry, o.Name()).MaxAttempts
+	// failures counts the consecutive failures for the backoff, attempts the
+	// attempts to send the oldest event
+	failures, attempts := 0, 0
+	for {
+		kubearmorpayload, err := q.Peek(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[ERROR] : %v - Reading the queue failed: %v\n", o.Name(), err)
+		} else {
+			err = o.Send(kubearmorpayload)
+			attempts += Attempts(err)
+			if err != nil && !errors.Is(err, ErrCircuitOpen) && (Permanent(err) || attempts >= maxAttempts) {
+				if attempts > Attempts(err) {
+					err = &retriesError{err: err, attempts: attempts}
+				}
+				d.deadLetter(o, kubearmorpayload, err)
+				err = nil
+			}
+			if err == nil {
+				failures, attempts = 0, 0
+				if err := q.Ack(); err != nil {
+					log.Printf("[ERROR] : %v - Acknowledging the event failed: %v\n", o.Name(), err)
+				}
+				continue
+			}
+		}
+		failures++
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(queueBackoff.Delay(failures)):
+		}
+	}
+}
+
+// matched reports whether the event matches the rules of the output, the
+// errors of the rules are logged in debug mode.
+func (d *Dispatcher) matched(o Output, rules *Rules, kubearmorpayload types.KubearmorPayload) bool {
+	matched, err := rules.Match(kubearmorpayload)
+	if err != nil && d.Config.Debug {
+		log.Printf("[DEBUG] : %v - Rules - %v\n", o.Name(), err)
+	}
+	return matched
+}
+
+func (d *Dispatcher) filtered(o Output, kubearmorpayload types.KubearmorPayload) bool {
+	minimumPriority := o.MinimumPriority()
+	if minimumPriority == types.Default {
+		return false
+	}
+	return d.Config.SeverityMapping.GetPriority(kubearmorpayload) < minimumPriority
+}
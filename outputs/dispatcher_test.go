@@ -0,0 +1,302 @@
+package outputs
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"runtime/metrics"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+type testOutput struct {
+	name            string
+	eventTypes      []string
+	minimumPriority types.PriorityType
+	received        chan types.KubearmorPayload
+	err             error
+}
+
+func (o *testOutput) Name() string                        { return o.name }
+func (o *testOutput) EventTypes() []string                { return o.eventTypes }
+func (o *testOutput) MinimumPriority() types.PriorityType { return o.minimumPriority }
+func (o *testOutput) Send(kubearmorpayload types.KubearmorPayload) error {
+	if o.err != nil {
+		return o.err
+	}
+	o.received <- kubearmorpayload
+	return nil
+}
+func (o *testOutput) Close(ctx context.Context) error { return nil }
+
+func TestRegistrations(t *testing.T) {
+	names := make(map[string]bool)
+	for _, r := range Registrations() {
+		require.NotEmpty(t, r.Name)
+		require.NotEmpty(t, r.EventTypes, r.Name)
+		require.NotNil(t, r.Enabled, r.Name)
+		require.NotNil(t, r.New, r.Name)
+		require.NotNil(t, r.Send, r.Name)
+		require.False(t, names[r.Name], "%v registered twice", r.Name)
+		names[r.Name] = true
+	}
+
+	require.Empty(t, NewOutputs(&types.Configuration{}, &types.Statistics{}, &types.PromStatistics{}, nil, nil))
+}
+
+func TestDispatch(t *testing.T) {
+	Initvariable(types.BuffersConfig{})
+
+	o := &testOutput{name: "test", eventTypes: []string{AlertEventType}, received: make(chan types.KubearmorPayload, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewDispatcher(&types.Configuration{}, &types.Statistics{}, &types.PromStatistics{})
+	d.Dispatch(ctx, o)
+	defer d.Wait()
+	defer cancel()
+
+	require.Eventually(t, func() bool {
+		AlertLock.RLock()
+		defer AlertLock.RUnlock()
+		_, ok := AlertStructs["test"]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	LogLock.RLock()
+	_, ok := LogStructs["test"]
+	LogLock.RUnlock()
+	require.False(t, ok, "output must not be subscribed to logs")
+
+	AlertLock.RLock()
+	AlertStructs["test"].Broadcast.C <- types.KubearmorPayload{EventType: AlertEventType}
+	AlertLock.RUnlock()
+
+	select {
+	case p := <-o.received:
+		require.Equal(t, AlertEventType, p.EventType)
+	case <-time.After(time.Second):
+		t.Fatal("event was not dispatched to the output")
+	}
+}
+
+func TestDispatchLogs(t *testing.T) {
+	Initvariable(types.BuffersConfig{})
+
+	for _, enabled := range []bool{false, true} {
+		o := &testOutput{name: "logs", eventTypes: []string{AlertEventType, LogEventType}}
+		ctx, cancel := context.WithCancel(context.Background())
+		d := NewDispatcher(&types.Configuration{Log: enabled}, &types.Statistics{}, &types.PromStatistics{})
+		d.Dispatch(ctx, o)
+
+		LogLock.RLock()
+		_, ok := LogStructs["logs"]
+		LogLock.RUnlock()
+		require.Equal(t, enabled, ok, "output must be subscribed to logs only if they are enabled")
+
+		cancel()
+		d.Wait()
+	}
+}
+
+func TestDispatchDrain(t *testing.T) {
+	Initvariable(types.BuffersConfig{})
+
+	// the output sends one event at a time, the others wait in its queue
+	o := &testOutput{name: "drain", eventTypes: []string{AlertEventType}, received: make(chan types.KubearmorPayload)}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewDispatcher(&types.Configuration{}, &types.Statistics{}, &types.PromStatistics{})
+	d.Dispatch(ctx, o)
+
+	require.Eventually(t, func() bool {
+		AlertLock.RLock()
+		defer AlertLock.RUnlock()
+		_, ok := AlertStructs["drain"]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		BroadcastAlertWait(types.KubearmorPayload{EventType: AlertEventType})
+	}
+	<-o.received
+	cancel()
+	<-o.received
+	<-o.received
+	d.Wait()
+
+	AlertLock.RLock()
+	_, ok := AlertStructs["drain"]
+	AlertLock.RUnlock()
+	require.False(t, ok, "output must be unsubscribed")
+}
+
+func TestDispatcherMinimumPriority(t *testing.T) {
+	config := &types.Configuration{
+		SeverityMapping: types.SeverityMappingConfig{
+			SeveritiesMap:   map[string]types.PriorityType{"1": types.Debug, "5": types.Warning, "10": types.Emergency},
+			LogPriority:     "informational",
+			DefaultPriority: "warning",
+		},
+	}
+	stats := &types.Statistics{Filtered: new(expvar.Map).Init()}
+	d := NewDispatcher(config, stats, &types.PromStatistics{})
+
+	o := &testOutput{name: "test", minimumPriority: types.Warning, received: make(chan types.KubearmorPayload, 10)}
+	alert := func(severity interface{}) types.KubearmorPayload {
+		return types.KubearmorPayload{EventType: AlertEventType, OutputFields: map[string]interface{}{"Severity": severity}}
+	}
+
+	d.forward(context.Background(), o, outputRoute{}, alert("1"))
+	d.forward(context.Background(), o, outputRoute{}, alert(5))
+	d.forward(context.Background(), o, outputRoute{}, alert("10"))
+	d.forward(context.Background(), o, outputRoute{}, alert("critical"))
+	d.forward(context.Background(), o, outputRoute{}, alert("unknown"))
+	d.forward(context.Background(), o, outputRoute{}, types.KubearmorPayload{EventType: LogEventType})
+
+	require.Len(t, o.received, 4)
+	require.Equal(t, "2", stats.Filtered.Get("test").String())
+
+	o = &testOutput{name: "all", received: make(chan types.KubearmorPayload, 10)}
+	d.forward(context.Background(), o, outputRoute{}, alert("1"))
+	d.forward(context.Background(), o, outputRoute{}, types.KubearmorPayload{EventType: LogEventType})
+	require.Len(t, o.received, 2)
+}
+
+func newBenchmarkOutput(b *testing.B, name string) *testOutput {
+	Initvariable(types.BuffersConfig{})
+
+	o := &testOutput{name: name, eventTypes: []string{AlertEventType}, received: make(chan types.KubearmorPayload, 1000)}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewDispatcher(&types.Configuration{}, &types.Statistics{}, &types.PromStatistics{})
+	d.Dispatch(ctx, o)
+	b.Cleanup(func() {
+		cancel()
+		d.Wait()
+	})
+
+	for {
+		AlertLock.RLock()
+		_, ok := AlertStructs[name]
+		AlertLock.RUnlock()
+		if ok {
+			return o
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// idleOutputs is the number of outputs of the idle benchmarks
+const idleOutputs = 10
+
+// pollAlerts is the loop of the outputs before the pipeline blocked on its
+// buffers, kept as the baseline of the idle benchmarks: it wakes up every 10ms
+// to poll the buffer.
+func pollAlerts(ctx context.Context, o Output, conn *Buffer[types.KubearmorPayload]) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case resp := <-conn.C:
+			_ = o.Send(resp)
+		default:
+			time.Sleep(time.Millisecond * 10)
+		}
+	}
+}
+
+// wakeups returns the number of goroutines the scheduler started running, as
+// sampled by the runtime.
+func wakeups() uint64 {
+	s := []metrics.Sample{{Name: "/sched/latencies:seconds"}}
+	metrics.Read(s)
+	var n uint64
+	for _, c := range s[0].Value.Float64Histogram().Counts {
+		n += c
+	}
+	return n
+}
+
+// benchmarkIdle reports the wakeups per second of the pipelines of
+// idleOutputs outputs receiving no events over 100ms periods.
+func benchmarkIdle(b *testing.B, poll bool) {
+	Initvariable(types.BuffersConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewDispatcher(&types.Configuration{}, &types.Statistics{}, &types.PromStatistics{})
+	var wg sync.WaitGroup
+	for i := 0; i < idleOutputs; i++ {
+		o := &testOutput{name: fmt.Sprintf("idle%v", i), eventTypes: []string{AlertEventType}, received: make(chan types.KubearmorPayload, 1)}
+		if !poll {
+			d.Dispatch(ctx, o)
+			continue
+		}
+		conn := d.subscribeAlerts(o)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pollAlerts(ctx, o, conn)
+		}()
+	}
+	b.Cleanup(func() {
+		cancel()
+		d.Wait()
+		wg.Wait()
+	})
+
+	b.ResetTimer()
+	start, elapsed := wakeups(), time.Duration(0)
+	for i := 0; i < b.N; i++ {
+		t := time.Now()
+		time.Sleep(100 * time.Millisecond)
+		elapsed += time.Since(t)
+	}
+	b.ReportMetric(float64(wakeups()-start)/elapsed.Seconds(), "wakeups/s")
+}
+
+// BenchmarkDispatchIdleWakeups measures the wakeups of idle pipelines, blocked
+// on their buffers.
+func BenchmarkDispatchIdleWakeups(b *testing.B) {
+	benchmarkIdle(b, false)
+}
+
+// BenchmarkDispatchIdlePollingWakeups measures the wakeups of idle pipelines
+// polling their buffers, the baseline of BenchmarkDispatchIdleWakeups.
+func BenchmarkDispatchIdlePollingWakeups(b *testing.B) {
+	benchmarkIdle(b, true)
+}
+
+// BenchmarkDispatchIdle measures the latency of an event going through an
+// idle pipeline.
+func BenchmarkDispatchIdle(b *testing.B) {
+	o := newBenchmarkOutput(b, "idle")
+	kubearmorpayload := types.KubearmorPayload{EventType: AlertEventType}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BroadcastAlert(kubearmorpayload)
+		<-o.received
+	}
+}
+
+// BenchmarkDispatchBusy measures the throughput of the pipeline under a
+// continuous flow of events.
+func BenchmarkDispatchBusy(b *testing.B) {
+	o := newBenchmarkOutput(b, "busy")
+	kubearmorpayload := types.KubearmorPayload{EventType: AlertEventType}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < b.N; i++ {
+			<-o.received
+		}
+		close(done)
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BroadcastAlertWait(kubearmorpayload)
+	}
+	<-done
+}
@@ -1,109 +1,164 @@
 package outputs
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Elasticsearch",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Elasticsearch.HostPort != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Elasticsearch.MinimumPriority },
+		New:             newElasticsearchClient,
+		Send:            (*Client).ElasticsearchPost,
+		SendBatch:       (*Client).ElasticsearchBulk,
+	})
+}
+
+func newElasticsearchClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Elasticsearch", config.Elasticsearch.HostPort+"/"+config.Elasticsearch.Index+"/"+config.Elasticsearch.Type, config.Elasticsearch.MutualTLS, config.Elasticsearch.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // ElasticsearchPost posts event to Elasticsearch
-func (c *Client) ElasticsearchPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) ElasticsearchPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Elasticsearch.Add(Total, 1)
 
-	current := time.Now()
-	var eURL string
-	switch c.Config.Elasticsearch.Suffix {
-	case "none":
-		eURL = c.Config.Elasticsearch.HostPort + "/" + c.Config.Elasticsearch.Index + "/" + c.Config.Elasticsearch.Type
-	case "monthly":
-		eURL = c.Config.Elasticsearch.HostPort + "/" + c.Config.Elasticsearch.Index + "-" + current.Format("2006.01") + "/" + c.Config.Elasticsearch.Type
-	case "annually":
-		eURL = c.Config.Elasticsearch.HostPort + "/" + c.Config.Elasticsearch.Index + "-" + current.Format("2006") + "/" + c.Config.Elasticsearch.Type
-	default:
-		eURL = c.Config.Elasticsearch.HostPort + "/" + c.Config.Elasticsearch.Index + "-" + current.Format("2006.01.02") + "/" + c.Config.Elasticsearch.Type
-	}
-
+	eURL := c.Config.Elasticsearch.HostPort + "/" + elasticsearchIndex(c.Config, time.Now()) + "/" + c.Config.Elasticsearch.Type
 	endpointURL, err := url.Parse(eURL)
 	if err != nil {
-		c.setElasticSearchErrorMetrics()
+		c.setElasticSearchErrorMetrics(1)
 		log.Printf("[ERROR] : %v - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
-	c.EndpointURL = endpointURL
-	if c.Config.Elasticsearch.Username != "" && c.Config.Elasticsearch.Password != "" {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.BasicAuth(c.Config.Elasticsearch.Username, c.Config.Elasticsearch.Password)
-	}
-
-	for i, j := range c.Config.Elasticsearch.CustomHeaders {
-		c.AddHeader(i, j)
-	}
-
-	err = c.Post(kubearmorpayload)
+	err = c.Post(kubearmorpayload, append(c.elasticsearchOptions(), WithEndpointURL(endpointURL))...)
 	if err != nil {
-		c.setElasticSearchErrorMetrics()
+		c.setElasticSearchErrorMetrics(1)
 		log.Printf("[ERROR] : ElasticSearch - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:elasticsearch", "status:ok"})
 	c.Stats.Elasticsearch.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "elasticsearch", "status": OK}).Inc()
-}
 
-// setElasticSearchErrorMetrics set the error stats
-func (c *Client) setElasticSearchErrorMetrics() {
-	go c.CountMetric(Outputs, 1, []string{"output:elasticsearch", "status:error"})
-	c.Stats.Elasticsearch.Add(Error, 1)
-	c.PromStats.Outputs.With(map[string]string{"destination": "elasticsearch", "status": Error}).Inc()
+	return nil
 }
 
-func (c *Client) WatchElasticsearchPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
+// ElasticsearchBulk posts events to Elasticsearch with the _bulk API, the
+// events Elasticsearch rejected are returned in a *BatchError.
+func (c *Client) ElasticsearchBulk(events []types.KubearmorPayload) error {
+	c.Stats.Elasticsearch.Add(Total, int64(len(events)))
+
+	action := map[string]string{"_index": elasticsearchIndex(c.Config, time.Now())}
+	if t := c.Config.Elasticsearch.Type; t != "" && t != "_doc" {
+		action["_type"] = t
+	}
+	actionLine, _ := json.Marshal(map[string]interface{}{"index": action})
+	body := new(bytes.Buffer)
+	for _, kubearmorpayload := range events {
+		body.Write(actionLine)
+		body.WriteByte('\n')
+		if err := json.NewEncoder(body).Encode(kubearmorpayload); err != nil {
+			c.setElasticSearchErrorMetrics(len(events))
+			log.Printf("[ERROR] : %v - %v\n", c.OutputType, err.Error())
+			return err
+		}
+	}
+
+	endpointURL, err := url.Parse(c.Config.Elasticsearch.HostPort + "/_bulk")
+	if err != nil {
+		c.setElasticSearchErrorMetrics(len(events))
+		log.Printf("[ERROR] : %v - %v\n", c.OutputType, err.Error())
+		return err
+	}
 
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
+	var response []byte
+	opts := append(c.elasticsearchOptions(), WithEndpointURL(endpointURL), WithContentType("application/x-ndjson"), WithResponseBody(&response), WithoutRetries())
+	if err := c.Post(body.Bytes(), opts...); err != nil {
+		c.setElasticSearchErrorMetrics(len(events))
+		log.Printf("[ERROR] : ElasticSearch - %v\n", err)
+		return err
+	}
 
-	fmt.Println("discord running")
-	for AlertRunning {
-		select {
-		case resp := <-conn:
-			fmt.Println("response \n", resp)
-			c.ElasticsearchPost(resp)
+	var failures []BatchFailure
+	var bulk elasticsearchBulkResponse
+	if err := json.Unmarshal(response, &bulk); err == nil && bulk.Errors {
+		for i, item := range bulk.Items {
+			for _, result := range item {
+				if result.Status < http.StatusMultipleChoices || i >= len(events) {
+					continue
+				}
+				failures = append(failures, BatchFailure{
+					Index:     i,
+					Err:       fmt.Errorf("%v (%v): %s", http.StatusText(result.Status), result.Status, result.Error),
+					Retryable: c.retryable(result.Status, nil),
+				})
+			}
 		}
 	}
-	fmt.Println("discord stopped")
+
+	sent := len(events) - len(failures)
+	go c.CountMetric(Outputs, int64(sent), []string{"output:elasticsearch", "status:ok"})
+	c.Stats.Elasticsearch.Add(OK, int64(sent))
+	c.PromStats.Outputs.With(map[string]string{"destination": "elasticsearch", "status": OK}).Add(float64(sent))
+	if len(failures) != 0 {
+		c.setElasticSearchErrorMetrics(len(failures))
+		batchErr := &BatchError{Failures: failures}
+		log.Printf("[ERROR] : ElasticSearch - %v\n", batchErr)
+		return batchErr
+	}
 	return nil
 }
 
-func (c *Client) WatchElasticsearchPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
+// elasticsearchBulkResponse is the part of the response of the _bulk API
+// reporting the events which failed
+type elasticsearchBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
 
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.ElasticsearchPost(resp)
+// elasticsearchIndex returns the index of the events, with the date suffix
+func elasticsearchIndex(config *types.Configuration, current time.Time) string {
+	switch config.Elasticsearch.Suffix {
+	case "none":
+		return config.Elasticsearch.Index
+	case "monthly":
+		return config.Elasticsearch.Index + "-" + current.Format("2006.01")
+	case "annually":
+		return config.Elasticsearch.Index + "-" + current.Format("2006")
+	}
+	return config.Elasticsearch.Index + "-" + current.Format("2006.01.02")
+}
 
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
+// elasticsearchOptions returns the custom headers and the credentials of the
+// requests
+func (c *Client) elasticsearchOptions() []RequestOption {
+	opts := []RequestOption{WithHeaders(c.Config.Elasticsearch.CustomHeaders)}
+	if c.Config.Elasticsearch.Username != "" && c.Config.Elasticsearch.Password != "" {
+		opts = append(opts, WithBasicAuth(c.Config.Elasticsearch.Username, c.Config.Elasticsearch.Password))
 	}
+	return opts
+}
 
-	return nil
+// setElasticSearchErrorMetrics set the error stats
+func (c *Client) setElasticSearchErrorMetrics(events int) {
+	go c.CountMetric(Outputs, int64(events), []string{"output:elasticsearch", "status:error"})
+	c.Stats.Elasticsearch.Add(Error, int64(events))
+	c.PromStats.Outputs.With(map[string]string{"destination": "elasticsearch", "status": Error}).Add(float64(events))
 }
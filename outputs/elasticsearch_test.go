@@ -0,0 +1,60 @@
+package outputs
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestElasticsearchPostConcurrent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the date of the index is the one of the request
+		index := "/kubearmor-" + time.Now().Format("2006.01.02") + "/_doc"
+		user, password, ok := r.BasicAuth()
+		if r.URL.Path != index || !ok || user != "user" || password != "pass" || len(r.Header.Values("X-Custom")) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	config := &types.Configuration{Elasticsearch: types.ElasticsearchOutputConfig{
+		HostPort:      ts.URL,
+		Index:         "kubearmor",
+		Type:          "_doc",
+		Suffix:        "daily",
+		Username:      "user",
+		Password:      "pass",
+		CustomHeaders: map[string]string{"X-Custom": "value"},
+	}}
+	stats := &types.Statistics{Elasticsearch: new(expvar.Map).Init()}
+	promStats := &types.PromStatistics{Outputs: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_outputs"}, []string{"destination", "status"})}
+	c, err := newElasticsearchClient(config, stats, promStats, nil, nil)
+	require.Nil(t, err)
+	c.Retry.MaxAttempts = 1
+	endpointURL := c.EndpointURL.String()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.ElasticsearchPost(types.KubearmorPayload{})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.Nil(t, err)
+	}
+	require.Equal(t, endpointURL, c.EndpointURL.String(), "the endpoint of the client must not be modified")
+	require.Equal(t, "10", stats.Elasticsearch.Get(OK).String())
+}
@@ -14,6 +14,17 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Fission",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Fission.Function != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Fission.MinimumPriority },
+		New:             NewFissionClient,
+		Send:            (*Client).FissionCall,
+	})
+}
+
 // Some constant strings to use in request headers
 const FissionEventIDKey = "event-id"
 const FissionEventNamespaceKey = "event-namespace"
@@ -57,7 +68,7 @@ func NewFissionClient(config *types.Configuration, stats *types.Statistics, prom
 }
 
 // FissionCall .
-func (c *Client) FissionCall(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) FissionCall(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Fission.Add(Total, 1)
 
 	if c.Config.Fission.KubeConfig != "" {
@@ -77,26 +88,23 @@ func (c *Client) FissionCall(kubearmorpayload types.KubearmorPayload) {
 			c.Stats.Fission.Add(Error, 1)
 			c.PromStats.Outputs.With(map[string]string{"destination": "Fission", "status": Error}).Inc()
 			log.Printf("[ERROR] : %s - %v\n", Fission, err.Error())
-			return
+			return err
 		}
 		log.Printf("[INFO]  : %s - Function Response : %v\n", Fission, string(rawbody))
 	} else {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.AddHeader(FissionEventIDKey, uuid.New().String())
-		c.ContentType = FissionContentType
-
-		err := c.Post(kubearmorpayload)
+		err := c.Post(kubearmorpayload, WithHeader(FissionEventIDKey, uuid.New().String()), WithContentType(FissionContentType))
 		if err != nil {
 			go c.CountMetric(Outputs, 1, []string{"output:Fission", "status:error"})
 			c.Stats.Fission.Add(Error, 1)
 			c.PromStats.Outputs.With(map[string]string{"destination": "Fission", "status": Error}).Inc()
 			log.Printf("[ERROR] : %s - %v\n", Fission, err.Error())
-			return
+			return err
 		}
 	}
 	log.Printf("[INFO]  : %s - Call Function \"%v\" OK\n", Fission, c.Config.Fission.Function)
 	go c.CountMetric(Outputs, 1, []string{"output:Fission", "status:ok"})
 	c.Stats.Fission.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "Fission", "status": OK}).Inc()
+
+	return nil
 }
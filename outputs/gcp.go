@@ -22,6 +22,35 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "GCPPubSub",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.GCP.PubSub.ProjectID != "" && config.GCP.PubSub.Topic != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.GCP.PubSub.MinimumPriority },
+		New:             NewGCPClient,
+		Send:            (*Client).GCPPublishTopic,
+	})
+	RegisterOutput(Registration{
+		Name:            "GCPStorage",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.GCP.Storage.Bucket != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.GCP.Storage.MinimumPriority },
+		New:             NewGCPClient,
+		Send:            (*Client).UploadGCS,
+	})
+	RegisterOutput(Registration{
+		Name:            "GCPCloudFunctions",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.GCP.CloudFunctions.Name != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.GCP.CloudFunctions.MinimumPriority },
+		New:             NewGCPClient,
+		Send:            (*Client).GCPCallCloudFunction,
+	})
+}
+
 // NewGCPClient returns a new output.Client for accessing the GCP API.
 func NewGCPClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 	base64decodedCredentialsData, err := base64.StdEncoding.DecodeString(config.GCP.Credentials)
@@ -106,7 +135,7 @@ func NewGCPClient(config *types.Configuration, stats *types.Statistics, promStat
 }
 
 // GCPCallCloudFunction calls the given Cloud Function
-func (c *Client) GCPCallCloudFunction(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) GCPCallCloudFunction(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.GCPCloudFunctions.Add(Total, 1)
 
 	payload, _ := json.Marshal(kubearmorpayload)
@@ -123,17 +152,18 @@ func (c *Client) GCPCallCloudFunction(kubearmorpayload types.KubearmorPayload) {
 		go c.CountMetric("outputs", 1, []string{"output:gcpcloudfunctions", "status:error"})
 		c.PromStats.Outputs.With(map[string]string{"destination": "gcpcloudfunctions", "status": Error}).Inc()
 
-		return
+		return err
 	}
 
 	log.Printf("[INFO]  : GCPCloudFunctions - Call CloudFunction OK (%v)\n", result.ExecutionId)
 	c.Stats.GCPCloudFunctions.Add(OK, 1)
 	go c.CountMetric("outputs", 1, []string{"output:gcpcloudfunctions", "status:ok"})
 
+	return nil
 }
 
 // GCPPublishTopic sends a message to a GCP PubSub Topic
-func (c *Client) GCPPublishTopic(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) GCPPublishTopic(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.GCPPubSub.Add(Total, 1)
 
 	payload, _ := json.Marshal(kubearmorpayload)
@@ -150,17 +180,19 @@ func (c *Client) GCPPublishTopic(kubearmorpayload types.KubearmorPayload) {
 		go c.CountMetric("outputs", 1, []string{"output:gcppubsub", "status:error"})
 		c.PromStats.Outputs.With(map[string]string{"destination": "gcppubsub", "status": Error}).Inc()
 
-		return
+		return err
 	}
 
 	log.Printf("[INFO]  : GCPPubSub - Send to topic OK (%v)\n", id)
 	c.Stats.GCPPubSub.Add(OK, 1)
 	go c.CountMetric("outputs", 1, []string{"output:gcppubsub", "status:ok"})
 	c.PromStats.Outputs.With(map[string]string{"destination": "gcppubsub", "status": OK}).Inc()
+
+	return nil
 }
 
 // UploadGCS upload payload to
-func (c *Client) UploadGCS(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) UploadGCS(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.GCPStorage.Add(Total, 1)
 
 	payload, _ := json.Marshal(kubearmorpayload)
@@ -180,11 +212,13 @@ func (c *Client) UploadGCS(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.GCPStorage.Add(Error, 1)
 		go c.CountMetric("outputs", 1, []string{"output:gcpstorage", "status:error"})
 		c.PromStats.Outputs.With(map[string]string{"destination": "gcpstorage", "status": Error}).Inc()
-		return
+		return err
 	}
 
 	log.Printf("[INFO]  : GCPStorage - Upload to bucket OK \n")
 	c.Stats.GCPStorage.Add(OK, 1)
 	go c.CountMetric("outputs", 1, []string{"output:gcpstorage", "status:ok"})
 	c.PromStats.Outputs.With(map[string]string{"destination": "gcpstorage", "status": OK}).Inc()
+
+	return nil
 }
@@ -3,30 +3,50 @@ package outputs
 import (
 	"log"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "GCPCloudRun",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.GCP.CloudRun.Endpoint != "" && config.GCP.CloudRun.JWT != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.GCP.CloudRun.MinimumPriority },
+		New:             newGCPCloudRunClient,
+		Send:            (*Client).CloudRunFunctionPost,
+	})
+}
+
+func newGCPCloudRunClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("GCPCloudRun", config.GCP.CloudRun.Endpoint, false, false, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // CloudRunFunctionPost call Cloud Function
-func (c *Client) CloudRunFunctionPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) CloudRunFunctionPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.GCPCloudRun.Add(Total, 1)
 
+	var opts []RequestOption
 	if c.Config.GCP.CloudRun.JWT != "" {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.AddHeader(AuthorizationHeaderKey, "Bearer "+c.Config.GCP.CloudRun.JWT)
+		opts = append(opts, WithHeader(AuthorizationHeaderKey, "Bearer "+c.Config.GCP.CloudRun.JWT))
 	}
 
-	err := c.Post(kubearmorpayload)
+	err := c.Post(kubearmorpayload, opts...)
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:gcpcloudrun", "status:error"})
 		c.Stats.GCPCloudRun.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "gcpcloudrun", "status": Error}).Inc()
 		log.Printf("[ERROR] : GCPCloudRun - %v\n", err.Error())
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:gcpcloudrun", "status:ok"})
 	c.Stats.GCPCloudRun.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "gcpcloudrun", "status": OK}).Inc()
+
+	return nil
 }
@@ -5,9 +5,26 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "GoogleChat",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Googlechat.WebhookURL != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Googlechat.MinimumPriority },
+		New:             newGooglechatClient,
+		Send:            (*Client).GooglechatPost,
+	})
+}
+
+func newGooglechatClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Googlechat", config.Googlechat.WebhookURL, config.Googlechat.MutualTLS, config.Googlechat.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 type header struct {
 	Title    string `json:"title"`
 	SubTitle string `json:"subtitle"`
@@ -65,7 +82,7 @@ func newGooglechatPayload(kubearmorpayload types.KubearmorPayload, config *types
 	}
 
 	widgets = append(widgets, widget{KeyValue: keyValue{"priority", kubearmorpayload.EventType}})
-	widgets = append(widgets, widget{KeyValue: keyValue{"source pod", kubearmorpayload.OutputFields["PodName"].(string)}})
+	widgets = append(widgets, widget{KeyValue: keyValue{"source pod", outputField(kubearmorpayload, "PodName")}})
 
 	if kubearmorpayload.Hostname != "" {
 		widgets = append(widgets, widget{KeyValue: keyValue{Hostname, kubearmorpayload.Hostname}})
@@ -86,7 +103,7 @@ func newGooglechatPayload(kubearmorpayload types.KubearmorPayload, config *types
 }
 
 // GooglechatPost posts event to Google Chat
-func (c *Client) GooglechatPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) GooglechatPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.GoogleChat.Add(Total, 1)
 
 	err := c.Post(newGooglechatPayload(kubearmorpayload, c.Config))
@@ -95,10 +112,12 @@ func (c *Client) GooglechatPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.GoogleChat.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "googlechat", "status": Error}).Inc()
 		log.Printf("[ERROR] : GoogleChat - %v\n", err)
-		return
+		return err
 	}
 
 	go c.CountMetric(Outputs, 1, []string{"output:googlechat", "status:ok"})
 	c.Stats.GoogleChat.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "googlechat", "status": OK}).Inc()
+
+	return nil
 }
@@ -7,9 +7,26 @@ import (
 	"strings"
 	textTemplate "text/template"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Gotify",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Gotify.HostPort != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Gotify.MinimumPriority },
+		New:             newGotifyClient,
+		Send:            (*Client).GotifyPost,
+	})
+}
+
+func newGotifyClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Gotify", config.Gotify.HostPort+"/message", false, config.Gotify.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 var (
 	gotifyMarkdownTmpl = `- **Priority**: {{ .Priority }}
 - **Rule**: {{ .Rule }}
@@ -88,26 +105,27 @@ func newGotifyPayload(kubearmorpayload types.KubearmorPayload, config *types.Con
 }
 
 // GotifyPost posts event to Gotify
-func (c *Client) GotifyPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) GotifyPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Gotify.Add(Total, 1)
 
+	var opts []RequestOption
 	if c.Config.Gotify.Token != "" {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.AddHeader("X-Gotify-Key", c.Config.Gotify.Token)
+		opts = append(opts, WithHeader("X-Gotify-Key", c.Config.Gotify.Token))
 	}
 
-	err := c.Post(newGotifyPayload(kubearmorpayload, c.Config))
+	err := c.Post(newGotifyPayload(kubearmorpayload, c.Config), opts...)
 	if err != nil {
 		c.setGotifyErrorMetrics()
 		log.Printf("[ERROR] : Gotify - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:gotify", "status:ok"})
 	c.Stats.Gotify.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "gotify", "status": OK}).Inc()
+
+	return nil
 }
 
 // setGotifyErrorMetrics set the error stats
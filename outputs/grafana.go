@@ -3,12 +3,41 @@ package outputs
 import (
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "Grafana",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.Grafana.HostPort != "" && config.Grafana.APIKey != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.Grafana.MinimumPriority },
+		New:             newGrafanaClient,
+		Send:            (*Client).GrafanaPost,
+	})
+	RegisterOutput(Registration{
+		Name:            "GrafanaOnCall",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.GrafanaOnCall.WebhookURL != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.GrafanaOnCall.MinimumPriority },
+		New:             newGrafanaOnCallClient,
+		Send:            (*Client).GrafanaOnCallPost,
+	})
+}
+
+func newGrafanaClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Grafana", config.Grafana.HostPort+"/api/annotations", config.Grafana.MutualTLS, config.Grafana.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
+func newGrafanaOnCallClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("GrafanaOnCall", config.GrafanaOnCall.WebhookURL, config.GrafanaOnCall.MutualTLS, config.GrafanaOnCall.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 type grafanaPayload struct {
 	DashboardID int      `json:"dashboardId,omitempty"`
 	PanelID     int      `json:"panelId,omitempty"`
@@ -45,7 +74,7 @@ func newGrafanaPayload(kubearmorpayload types.KubearmorPayload, config *types.Co
 	}
 
 	g := grafanaPayload{
-		Text:    kubearmorpayload.EventType + "for pod" + kubearmorpayload.OutputFields["PodName"].(string),
+		Text:    kubearmorpayload.EventType + "for pod" + outputField(kubearmorpayload, "PodName"),
 		Time:    kubearmorpayload.Timestamp / 1000000,
 		TimeEnd: kubearmorpayload.Timestamp / 1000000,
 		Tags:    tags,
@@ -63,146 +92,51 @@ func newGrafanaPayload(kubearmorpayload types.KubearmorPayload, config *types.Co
 
 func newGrafanaOnCallPayload(kubearmorpayload types.KubearmorPayload, config *types.Configuration) grafanaOnCallPayload {
 	return grafanaOnCallPayload{
-		AlertUID: kubearmorpayload.OutputFields["UID"].(string),
-		Title:    fmt.Sprintf("[%v] %v", kubearmorpayload.EventType, kubearmorpayload.OutputFields["PodName"].(string)),
+		AlertUID: outputField(kubearmorpayload, "UID"),
+		Title:    fmt.Sprintf("[%v] %v", kubearmorpayload.EventType, outputField(kubearmorpayload, "PodName")),
 		State:    "alerting",
 		//Message:  kubearmorpayload.Output,
 	}
 }
 
 // GrafanaPost posts event to grafana
-func (c *Client) GrafanaPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) GrafanaPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Grafana.Add(Total, 1)
-	c.ContentType = GrafanaContentType
-	c.httpClientLock.Lock()
-	defer c.httpClientLock.Unlock()
-	c.AddHeader("Authorization", "Bearer "+c.Config.Grafana.APIKey)
-	for i, j := range c.Config.Grafana.CustomHeaders {
-		c.AddHeader(i, j)
-	}
-
-	err := c.Post(newGrafanaPayload(kubearmorpayload, c.Config))
+	err := c.Post(newGrafanaPayload(kubearmorpayload, c.Config),
+		WithContentType(GrafanaContentType),
+		WithHeader("Authorization", "Bearer "+c.Config.Grafana.APIKey),
+		WithHeaders(c.Config.Grafana.CustomHeaders),
+	)
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:grafana", "status:error"})
 		c.Stats.Grafana.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "grafana", "status": Error}).Inc()
 		log.Printf("[ERROR] : Grafana - %v\n", err)
-		return
+		return err
 	}
 
 	go c.CountMetric(Outputs, 1, []string{"output:grafana", "status:ok"})
 	c.Stats.Grafana.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "grafana", "status": OK}).Inc()
+
+	return nil
 }
 
 // GrafanaOnCallPost posts event to grafana onCall
-func (c *Client) GrafanaOnCallPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) GrafanaOnCallPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.GrafanaOnCall.Add(Total, 1)
-	c.ContentType = GrafanaContentType
-	c.httpClientLock.Lock()
-	defer c.httpClientLock.Unlock()
-	for i, j := range c.Config.GrafanaOnCall.CustomHeaders {
-		c.AddHeader(i, j)
-	}
-
-	err := c.Post(newGrafanaOnCallPayload(kubearmorpayload, c.Config))
+	err := c.Post(newGrafanaOnCallPayload(kubearmorpayload, c.Config), WithContentType(GrafanaContentType), WithHeaders(c.Config.GrafanaOnCall.CustomHeaders))
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:grafanaoncall", "status:error"})
 		c.Stats.Grafana.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "grafanaoncall", "status": Error}).Inc()
 		log.Printf("[ERROR] : Grafana OnCall - %v\n", err)
-		return
+		return err
 	}
 
 	go c.CountMetric(Outputs, 1, []string{"output:grafanaoncall", "status:ok"})
 	c.Stats.Grafana.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "grafanaoncall", "status": OK}).Inc()
-}
-
-func (c *Client) WatchGrafanaPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	Running := true
-	fmt.Println("discord running")
-	for Running {
-		select {
-		case resp := <-conn:
-			fmt.Println("response \n", resp)
-			c.GrafanaPost(resp)
-		}
-	}
-	fmt.Println("discord stopped")
-	return nil
-}
-
-func (c *Client) WatchGrafanaPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	Running := true
-	for Running {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.GrafanaPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchGrafanaOnCallPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	fmt.Println("discord running")
-	for AlertRunning {
-		select {
-		case resp := <-conn:
-			fmt.Println("response \n", resp)
-			c.GrafanaOnCallPost(resp)
-		}
-	}
-	fmt.Println("discord stopped")
-	return nil
-}
-
-func (c *Client) WatchGrafanaOnCallPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.GrafanaOnCallPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
@@ -0,0 +1,155 @@
+package outputs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// mutualTLSCheckInterval is how often the mutual TLS files are checked for
+// changes
+const mutualTLSCheckInterval = 10 * time.Second
+
+// httpClientPolicy returns the HTTP client settings of the output, the default
+// ones if the output has none of its own.
+func httpClientPolicy(httpClient types.HTTPClientConfig, name string) types.HTTPClientConfig {
+	if h, ok := httpClient.Outputs[strings.ToLower(name)]; ok {
+		return h
+	}
+	httpClient.Outputs = nil
+	return httpClient
+}
+
+// mutualTLSFiles are the files of the client certificate, its key and the CA,
+// with their modification times when they were loaded.
+type mutualTLSFiles struct {
+	paths     [3]string
+	modTimes  [3]time.Time
+	checkedAt time.Time
+}
+
+func newMutualTLSFiles(config *types.Configuration) *mutualTLSFiles {
+	f := &mutualTLSFiles{paths: [3]string{
+		config.MutualTLSFilesPath + MutualTLSClientCertFilename,
+		config.MutualTLSFilesPath + MutualTLSClientKeyFilename,
+		config.MutualTLSFilesPath + MutualTLSCacertFilename,
+	}}
+	for i, path := range []string{config.MutualTLSClient.CertFile, config.MutualTLSClient.KeyFile, config.MutualTLSClient.CaCertFile} {
+		if path != "" {
+			f.paths[i] = path
+		}
+	}
+	return f
+}
+
+// changed reports whether one of the files was modified since they were
+// loaded, the files are checked at most every mutualTLSCheckInterval.
+func (f *mutualTLSFiles) changed(now time.Time) bool {
+	if now.Sub(f.checkedAt) < mutualTLSCheckInterval {
+		return false
+	}
+	f.checkedAt = now
+	for i, path := range f.paths {
+		info, err := os.Stat(path)
+		if err == nil && !info.ModTime().Equal(f.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsConfig loads the files and returns the TLS configuration using them
+func (f *mutualTLSFiles) tlsConfig(outputType string) *tls.Config {
+	for i, path := range f.paths {
+		if info, err := os.Stat(path); err == nil {
+			f.modTimes[i] = info.ModTime()
+		}
+	}
+	f.checkedAt = time.Now()
+
+	cert, err := tls.LoadX509KeyPair(f.paths[0], f.paths[1])
+	if err != nil {
+		log.Printf("[ERROR] : %v - %v\n", outputType, err.Error())
+	}
+	caCert, err := os.ReadFile(f.paths[2])
+	if err != nil {
+		log.Printf("[ERROR] : %v - %v\n", outputType, err.Error())
+	}
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      caCertPool,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// getHTTPClient returns the HTTP client of the output, created on first use
+// and kept to reuse its connections. With mutual TLS, the client is created
+// again when the certificate files change.
+func (c *Client) getHTTPClient() *http.Client {
+	c.httpClientInitLock.Lock()
+	defer c.httpClientInitLock.Unlock()
+
+	if c.httpClient != nil {
+		if c.mutualTLSFiles == nil || !c.mutualTLSFiles.changed(time.Now()) {
+			return c.httpClient
+		}
+		log.Printf("[INFO]  : %v - Mutual TLS files changed, reloading them\n", c.OutputType)
+		c.httpClient.CloseIdleConnections()
+	}
+	if c.MutualTLSEnabled && c.mutualTLSFiles == nil {
+		c.mutualTLSFiles = newMutualTLSFiles(c.Config)
+	}
+	c.httpClient = &http.Client{
+		Transport: c.newTransport(),
+		Timeout:   time.Duration(c.HTTPClient.Timeout) * time.Millisecond,
+	}
+	return c.httpClient
+}
+
+// newTransport returns a transport pooling the connections, using HTTP/2 when
+// the server supports it.
+func (c *Client) newTransport() *http.Transport {
+	proxy := http.ProxyFromEnvironment
+	if c.HTTPClient.Proxy != "" {
+		if proxyURL, err := url.Parse(c.HTTPClient.Proxy); err == nil {
+			proxy = http.ProxyURL(proxyURL)
+		} else {
+			log.Printf("[ERROR] : %v - Bad proxy: %v\n", c.OutputType, err)
+		}
+	}
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(c.HTTPClient.DialTimeout) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   c.HTTPClient.MaxIdleConnsPerHost,
+		IdleConnTimeout:       time.Duration(c.HTTPClient.IdleConnTimeout) * time.Millisecond,
+		TLSHandshakeTimeout:   time.Duration(c.HTTPClient.TLSHandshakeTimeout) * time.Millisecond,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	if c.MutualTLSEnabled {
+		transport.TLSClientConfig = c.mutualTLSFiles.tlsConfig(c.OutputType)
+	} else if !c.CheckCert {
+		// With MutualTLS enabled, the check cert flag is ignored
+		// #nosec G402 This is only set as a result of explicit configuration
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	}
+	return transport
+}
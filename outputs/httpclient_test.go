@@ -0,0 +1,81 @@
+package outputs
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestHTTPClientReuse(t *testing.T) {
+	var conns atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	nc, err := NewClient("", ts.URL, false, true, &types.Configuration{}, &types.Statistics{}, &types.PromStatistics{}, nil, nil)
+	require.Nil(t, err)
+	nc.Retry.MaxAttempts = 1
+	nc.HTTPClient = types.HTTPClientConfig{Timeout: 50, MaxIdleConnsPerHost: 2}
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, nc.Post("payload"))
+	}
+	require.EqualValues(t, 1, conns.Load(), "the connection must be reused")
+	require.Same(t, nc.getHTTPClient(), nc.getHTTPClient())
+
+	nc.EndpointURL.Path = "/slow"
+	require.NotNil(t, nc.Post("payload"), "the request must time out")
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+	}))
+	defer proxy.Close()
+
+	nc, err := NewClient("", "http://destination.invalid/path", false, true, &types.Configuration{}, &types.Statistics{}, &types.PromStatistics{}, nil, nil)
+	require.Nil(t, err)
+	nc.Retry.MaxAttempts = 1
+	nc.HTTPClient = httpClientPolicy(types.HTTPClientConfig{Outputs: map[string]types.HTTPClientConfig{"proxied": {Proxy: proxy.URL}}}, "Proxied")
+
+	require.Nil(t, nc.Post("payload"))
+	require.Equal(t, "http://destination.invalid/path", proxied.Load())
+}
+
+func TestMutualTLSFilesChanged(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"client.crt", "client.key", "ca.crt"} {
+		require.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte("invalid"), 0600))
+	}
+	f := newMutualTLSFiles(&types.Configuration{MutualTLSFilesPath: dir})
+	f.tlsConfig("test")
+
+	now := time.Now()
+	require.False(t, f.changed(now), "checked at most every mutualTLSCheckInterval")
+	now = now.Add(mutualTLSCheckInterval)
+	require.False(t, f.changed(now))
+
+	later := time.Now().Add(time.Hour)
+	require.Nil(t, os.Chtimes(filepath.Join(dir, "ca.crt"), later, later))
+	require.False(t, f.changed(now.Add(time.Second)))
+	require.True(t, f.changed(now.Add(mutualTLSCheckInterval)))
+}
@@ -4,16 +4,45 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Influxdb",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Influxdb.HostPort != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Influxdb.MinimumPriority },
+		New:             newInfluxdbClient,
+		Send:            (*Client).InfluxdbPost,
+		SendBatch:       (*Client).InfluxdbPostBatch,
+	})
+}
+
+func newInfluxdbClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	var url string = config.Influxdb.HostPort
+	if config.Influxdb.Organization != "" && config.Influxdb.Bucket != "" {
+		url += "/api/v2/write?org=" + config.Influxdb.Organization + "&bucket=" + config.Influxdb.Bucket
+	} else if config.Influxdb.Database != "" {
+		url += "/write?db=" + config.Influxdb.Database
+	}
+	if config.Influxdb.User != "" && config.Influxdb.Password != "" && config.Influxdb.Token == "" {
+		url += "&u=" + config.Influxdb.User + "&p=" + config.Influxdb.Password
+	}
+	if config.Influxdb.Precision != "" {
+		url += "&precision=" + config.Influxdb.Precision
+	}
+
+	return NewClient("Influxdb", url, config.Influxdb.MutualTLS, config.Influxdb.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 type influxdbPayload string
 
 func newInfluxdbPayload(kubearmorpayload types.KubearmorPayload, config *types.Configuration) influxdbPayload {
-	s := "events,rule=" + strings.Replace(kubearmorpayload.EventType, " ", "_", -1) + ",priority=" + kubearmorpayload.EventType + ",source=" + kubearmorpayload.OutputFields["PodName"].(string)
+	s := "events,rule=" + strings.Replace(kubearmorpayload.EventType, " ", "_", -1) + ",priority=" + kubearmorpayload.EventType + ",source=" + outputField(kubearmorpayload, "PodName")
 
 	for i, j := range kubearmorpayload.OutputFields {
 		switch v := j.(type) {
@@ -34,71 +63,41 @@ func newInfluxdbPayload(kubearmorpayload types.KubearmorPayload, config *types.C
 }
 
 // InfluxdbPost posts event to InfluxDB
-func (c *Client) InfluxdbPost(kubearmorpayload types.KubearmorPayload) {
-	c.Stats.Influxdb.Add(Total, 1)
+func (c *Client) InfluxdbPost(kubearmorpayload types.KubearmorPayload) error {
+	return c.influxdbPost(newInfluxdbPayload(kubearmorpayload, c.Config), 1)
+}
 
-	c.httpClientLock.Lock()
-	defer c.httpClientLock.Unlock()
-	c.AddHeader("Accept", "application/json")
+// InfluxdbPostBatch posts events to InfluxDB, a line of the line protocol per
+// event
+func (c *Client) InfluxdbPostBatch(events []types.KubearmorPayload) error {
+	lines := make([]string, len(events))
+	for i, kubearmorpayload := range events {
+		lines[i] = string(newInfluxdbPayload(kubearmorpayload, c.Config))
+	}
+	return c.influxdbPost(influxdbPayload(strings.Join(lines, "\n")), len(events), WithoutRetries())
+}
+
+func (c *Client) influxdbPost(payload influxdbPayload, events int, opts ...RequestOption) error {
+	c.Stats.Influxdb.Add(Total, int64(events))
 
+	opts = append(opts, WithHeader("Accept", "application/json"))
 	if c.Config.Influxdb.Token != "" {
-		c.AddHeader("Authorization", "Token "+c.Config.Influxdb.Token)
+		opts = append(opts, WithHeader("Authorization", "Token "+c.Config.Influxdb.Token))
 	}
 
-	err := c.Post(newInfluxdbPayload(kubearmorpayload, c.Config))
+	err := c.Post(payload, opts...)
 	if err != nil {
-		go c.CountMetric(Outputs, 1, []string{"output:influxdb", "status:error"})
-		c.Stats.Influxdb.Add(Error, 1)
-		c.PromStats.Outputs.With(map[string]string{"destination": "influxdb", "status": Error}).Inc()
+		go c.CountMetric(Outputs, int64(events), []string{"output:influxdb", "status:error"})
+		c.Stats.Influxdb.Add(Error, int64(events))
+		c.PromStats.Outputs.With(map[string]string{"destination": "influxdb", "status": Error}).Add(float64(events))
 		log.Printf("[ERROR] : InfluxDB - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
-	go c.CountMetric(Outputs, 1, []string{"output:influxdb", "status:ok"})
-	c.Stats.Influxdb.Add(OK, 1)
-	c.PromStats.Outputs.With(map[string]string{"destination": "influxdb", "status": OK}).Inc()
-}
-
-func (c *Client) WatchInfluxdbPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	fmt.Println("discord running")
-	for AlertRunning {
-		select {
-		case resp := <-conn:
-			fmt.Println("response \n", resp)
-			c.InfluxdbPost(resp)
-		}
-	}
-	fmt.Println("discord stopped")
-	return nil
-}
-
-func (c *Client) WatchInfluxdbPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.InfluxdbPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
+	go c.CountMetric(Outputs, int64(events), []string{"output:influxdb", "status:ok"})
+	c.Stats.Influxdb.Add(OK, int64(events))
+	c.PromStats.Outputs.With(map[string]string{"destination": "influxdb", "status": OK}).Add(float64(events))
 
 	return nil
 }
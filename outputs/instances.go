@@ -0,0 +1,89 @@
+package outputs
+
+import (
+	"expvar"
+	"reflect"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// DefaultInstance is the instance of the outputs configured by the blocks of
+// the configuration, the other instances are listed in the Instances block.
+const DefaultInstance = "default"
+
+// InstanceName returns the name of the output of a named instance
+func InstanceName(output, instance string) string {
+	return output + ":" + instance
+}
+
+// splitInstance returns the output and the instance an output name refers to
+func splitInstance(name string) (output, instance string) {
+	if output, instance, found := strings.Cut(name, ":"); found {
+		return output, instance
+	}
+	return name, DefaultInstance
+}
+
+// outputLabels returns the Prometheus labels of the events of an output
+func outputLabels(name, status string) map[string]string {
+	output, instance := splitInstance(name)
+	return map[string]string{"destination": strings.ToLower(output), "instance": instance, "status": status}
+}
+
+// instanceEnables reports whether the output is enabled by the blocks set by
+// the instance, and not only by the settings the instance inherits.
+func instanceEnables(r Registration, instance types.InstanceConfig) bool {
+	if !r.Enabled(instance.Config) {
+		return false
+	}
+	for _, block := range instance.Blocks {
+		config := *instance.Config
+		field := reflect.ValueOf(&config).Elem().FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, block)
+		})
+		if !field.IsValid() {
+			continue
+		}
+		field.Set(reflect.Zero(field.Type()))
+		if !r.Enabled(&config) {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceStats returns the statistics of the output of a named instance, its
+// events are counted in outputs.<output>:<instance> instead of outputs.<output>.
+func instanceStats(stats *types.Statistics, r Registration, name string) *types.Statistics {
+	s := *stats
+	field := reflect.ValueOf(&s).Elem().FieldByNameFunc(func(field string) bool {
+		return strings.EqualFold(field, r.Name)
+	})
+	if !field.IsValid() || field.Type() != reflect.TypeOf((*expvar.Map)(nil)) {
+		return stats
+	}
+	key := "outputs." + strings.ToLower(name)
+	m, ok := expvar.Get(key).(*expvar.Map)
+	if !ok {
+		m = expvar.NewMap(key)
+		m.Add(Total, 0)
+		m.Add(Error, 0)
+		m.Add(OK, 0)
+	}
+	field.Set(reflect.ValueOf(m))
+	return &s
+}
+
+// instancePromStats returns the Prometheus statistics of the outputs of an
+// instance, their events are labeled with the instance.
+func instancePromStats(promStats *types.PromStatistics, instance string) *types.PromStatistics {
+	if promStats == nil || promStats.Outputs == nil {
+		return promStats
+	}
+	s := *promStats
+	s.Outputs = promStats.Outputs.MustCurryWith(prometheus.Labels{"instance": instance})
+	return &s
+}
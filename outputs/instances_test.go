@@ -0,0 +1,59 @@
+package outputs
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestNewOutputsInstances(t *testing.T) {
+	requests := make(chan string, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.Path
+	}))
+	defer ts.Close()
+
+	config := &types.Configuration{
+		Retry:   types.RetryConfig{MaxAttempts: 1},
+		Webhook: types.WebhookOutputConfig{Address: ts.URL + "/default"},
+	}
+	teamA, teamB := *config, *config
+	teamA.Webhook = types.WebhookOutputConfig{Address: ts.URL + "/team-a", MinimumPriority: "warning"}
+	teamB.Slack = types.SlackOutputConfig{WebhookURL: ts.URL + "/team-b"}
+	config.Instances = []types.InstanceConfig{
+		{Name: "team-a", Blocks: []string{"webhook"}, Config: &teamA},
+		{Name: "team-b", Blocks: []string{"slack"}, Config: &teamB},
+	}
+	stats := &types.Statistics{Webhook: new(expvar.Map).Init(), Slack: new(expvar.Map).Init()}
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_outputs"}, []string{"destination", "instance", "status"})
+
+	o := NewOutputs(config, stats, &types.PromStatistics{Outputs: vec}, nil, nil)
+	names := make([]string, len(o))
+	for i := range o {
+		names[i] = o[i].Name()
+	}
+	require.Equal(t, []string{"Webhook", "Webhook:team-a", "Slack:team-b"}, names, "the instances enable only the outputs of their blocks")
+	require.Equal(t, types.Priority("warning"), o[1].MinimumPriority())
+
+	require.Nil(t, o[0].Send(types.KubearmorPayload{}))
+	require.Equal(t, "/default", <-requests)
+	require.Nil(t, o[1].Send(types.KubearmorPayload{}))
+	require.Equal(t, "/team-a", <-requests)
+
+	require.Equal(t, float64(1), testutil.ToFloat64(vec.With(prometheus.Labels{"destination": "webhook", "instance": DefaultInstance, "status": OK})))
+	require.Equal(t, float64(1), testutil.ToFloat64(vec.With(prometheus.Labels{"destination": "webhook", "instance": "team-a", "status": OK})))
+	require.Equal(t, "1", stats.Webhook.Get(OK).String())
+	require.Equal(t, "1", expvar.Get("outputs.webhook:team-a").(*expvar.Map).Get(OK).String())
+}
+
+func TestOutputLabels(t *testing.T) {
+	require.Equal(t, map[string]string{"destination": "slack", "instance": DefaultInstance, "status": OK}, outputLabels("Slack", OK))
+	require.Equal(t, map[string]string{"destination": "slack", "instance": "team-a", "status": OK}, outputLabels(InstanceName("Slack", "team-a"), OK))
+}
@@ -12,7 +12,6 @@ import (
 	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
-	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl/plain"
 	"github.com/segmentio/kafka-go/sasl/scram"
@@ -20,6 +19,18 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Kafka",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Kafka.HostPort != "" && config.Kafka.Topic != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Kafka.MinimumPriority },
+		New:             NewKafkaClient,
+		Send:            (*Client).KafkaProduce,
+		Close:           (*Client).CloseKafka,
+	})
+}
+
 // NewKafkaClient returns a new output.Client for accessing the Apache Kafka.
 func NewKafkaClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 
@@ -144,14 +155,14 @@ func NewKafkaClient(config *types.Configuration, stats *types.Statistics, promSt
 }
 
 // KafkaProduce sends a message to a Apach Kafka Topic
-func (c *Client) KafkaProduce(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) KafkaProduce(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Kafka.Add(Total, 1)
 
 	Msg, err := json.Marshal(kubearmorpayload)
 	if err != nil {
 		c.incrKafkaErrorMetrics(1)
 		log.Printf("[ERROR] : Kafka - %v - %v\n", "failed to marshalling message", err.Error())
-		return
+		return err
 	}
 
 	kafkaMsg := kafka.Message{
@@ -163,11 +174,13 @@ func (c *Client) KafkaProduce(kubearmorpayload types.KubearmorPayload) {
 	if err != nil {
 		c.incrKafkaErrorMetrics(1)
 		log.Printf("[ERROR] : Kafka - %v\n", err.Error())
-		return
+		return err
 	} else {
 		c.incrKafkaSuccessMetrics(1)
 		log.Printf("[INFO]  : Kafka - Publish OK\n")
 	}
+
+	return nil
 }
 
 // handleKafkaCompletion is called when a message is produced
@@ -195,45 +208,7 @@ func (c *Client) incrKafkaErrorMetrics(add int) {
 	c.PromStats.Outputs.With(map[string]string{"destination": "kafka", "status": Error}).Add(float64(add))
 }
 
-func (c *Client) WatchKafkaProduceAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	fmt.Println("discord running")
-	for AlertRunning {
-		select {
-		case resp := <-conn:
-			fmt.Println("response \n", resp)
-			c.KafkaProduce(resp)
-		}
-	}
-	fmt.Println("discord stopped")
-	return nil
-}
-
-func (c *Client) WatchKafkaProduceLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.KafkaProduce(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
-
-	return nil
+// CloseKafka flushes the pending messages and closes the producer
+func (c *Client) CloseKafka(ctx context.Context) error {
+	return c.KafkaProducer.Close()
 }
@@ -6,9 +6,26 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "KafkaRest",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.KafkaRest.Address != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.KafkaRest.MinimumPriority },
+		New:             newKafkaRestClient,
+		Send:            (*Client).KafkaRestPost,
+	})
+}
+
+func newKafkaRestClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("KafkaRest", config.KafkaRest.Address, config.KafkaRest.MutualTLS, config.KafkaRest.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // Records are the items inside the request wrapper
 type Records struct {
 	Value string `json:"value"`
@@ -20,7 +37,7 @@ type KafkaRestPayload struct {
 }
 
 // KafkaRestPost posts event the Kafka Rest Proxy
-func (c *Client) KafkaRestPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) KafkaRestPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.KafkaRest.Add(Total, 1)
 
 	var version int
@@ -37,28 +54,28 @@ func (c *Client) KafkaRestPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.KafkaRest.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "kafkarest", "status": Error}).Inc()
 		log.Printf("[ERROR] : Kafka Rest - %v - %v\n", "failed to marshalling message", err.Error())
-		return
+		return err
 	}
 
-	c.ContentType = fmt.Sprintf("application/vnd.kafka.binary.v%d+json", version)
-
 	payload := KafkaRestPayload{
 		Records: []Records{{
 			Value: base64.StdEncoding.EncodeToString(Msg),
 		}},
 	}
 
-	err = c.Post(payload)
+	err = c.Post(payload, WithContentType(fmt.Sprintf("application/vnd.kafka.binary.v%d+json", version)))
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:kafkarest", "status:error"})
 		c.Stats.KafkaRest.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "kafkarest", "status": Error}).Inc()
 		log.Printf("[ERROR] : Kafka Rest - %v\n", err.Error())
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:kafkarest", "status:ok"})
 	c.Stats.KafkaRest.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "kafkarest", "status": OK}).Inc()
+
+	return nil
 }
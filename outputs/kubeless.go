@@ -14,6 +14,19 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "Kubeless",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.Kubeless.Namespace != "" && config.Kubeless.Function != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.Kubeless.MinimumPriority },
+		New:             NewKubelessClient,
+		Send:            (*Client).KubelessCall,
+	})
+}
+
 // Some constant strings to use in request headers
 const KubelessEventIDKey = "event-id"
 const KubelessUserAgentKey = "User-Agent"
@@ -57,7 +70,7 @@ func NewKubelessClient(config *types.Configuration, stats *types.Statistics, pro
 }
 
 // KubelessCall .
-func (c *Client) KubelessCall(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) KubelessCall(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Kubeless.Add(Total, 1)
 
 	if c.Config.Kubeless.Kubeconfig != "" {
@@ -76,28 +89,28 @@ func (c *Client) KubelessCall(kubearmorpayload types.KubearmorPayload) {
 			c.Stats.Kubeless.Add(Error, 1)
 			c.PromStats.Outputs.With(map[string]string{"destination": "kubeless", "status": Error}).Inc()
 			log.Printf("[ERROR] : Kubeless - %v\n", err)
-			return
+			return err
 		}
 		log.Printf("[INFO]  : Kubeless - Function Response : %v\n", string(rawbody))
 	} else {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.AddHeader(KubelessEventIDKey, uuid.New().String())
-		c.AddHeader(KubelessEventTypeKey, KubelessEventTypeValue)
-		c.AddHeader(KubelessEventNamespaceKey, c.Config.Kubeless.Namespace)
-		c.ContentType = KubelessContentType
-
-		err := c.Post(kubearmorpayload)
+		err := c.Post(kubearmorpayload,
+			WithHeader(KubelessEventIDKey, uuid.New().String()),
+			WithHeader(KubelessEventTypeKey, KubelessEventTypeValue),
+			WithHeader(KubelessEventNamespaceKey, c.Config.Kubeless.Namespace),
+			WithContentType(KubelessContentType),
+		)
 		if err != nil {
 			go c.CountMetric(Outputs, 1, []string{"output:kubeless", "status:error"})
 			c.Stats.Kubeless.Add(Error, 1)
 			c.PromStats.Outputs.With(map[string]string{"destination": "kubeless", "status": Error}).Inc()
 			log.Printf("[ERROR] : Kubeless - %v\n", err)
-			return
+			return err
 		}
 	}
 	log.Printf("[INFO]  : Kubeless - Call Function \"%v\" OK\n", c.Config.Kubeless.Function)
 	go c.CountMetric(Outputs, 1, []string{"output:kubeless", "status:ok"})
 	c.Stats.Kubeless.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "kubeless", "status": OK}).Inc()
+
+	return nil
 }
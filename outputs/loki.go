@@ -3,11 +3,30 @@ package outputs
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Loki",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Loki.HostPort != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Loki.MinimumPriority },
+		New:             newLokiClient,
+		Send:            (*Client).LokiPost,
+		SendBatch:       (*Client).LokiPostBatch,
+	})
+}
+
+func newLokiClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Loki", config.Loki.HostPort+config.Loki.Endpoint, config.Loki.MutualTLS, config.Loki.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 type lokiPayload struct {
 	Streams []lokiStream `json:"streams"`
 }
@@ -24,7 +43,7 @@ const LokiContentType = "application/json"
 
 func newLokiPayload(kubearmorpayload types.KubearmorPayload, config *types.Configuration) lokiPayload {
 	s := make(map[string]string, 3+len(kubearmorpayload.OutputFields)+len(config.Loki.ExtraLabelsList))
-	s["source"] = kubearmorpayload.OutputFields["PodName"].(string)
+	s["source"] = outputField(kubearmorpayload, "PodName")
 	s["priority"] = kubearmorpayload.EventType
 
 	for i, j := range kubearmorpayload.OutputFields {
@@ -68,36 +87,70 @@ func newLokiPayload(kubearmorpayload types.KubearmorPayload, config *types.Confi
 	}}
 }
 
+// newLokiBatchPayload returns the streams of the events, the events with the
+// same labels are values of the same stream.
+func newLokiBatchPayload(events []types.KubearmorPayload, config *types.Configuration) lokiPayload {
+	var payload lokiPayload
+	streams := make(map[string]int)
+	for _, kubearmorpayload := range events {
+		for _, stream := range newLokiPayload(kubearmorpayload, config).Streams {
+			key := lokiStreamKey(stream.Stream)
+			if i, ok := streams[key]; ok {
+				payload.Streams[i].Values = append(payload.Streams[i].Values, stream.Values...)
+				continue
+			}
+			streams[key] = len(payload.Streams)
+			payload.Streams = append(payload.Streams, stream)
+		}
+	}
+	return payload
+}
+
+// lokiStreamKey returns the labels of the stream, sorted
+func lokiStreamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k, v := range labels {
+		keys = append(keys, k+"="+v)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
 // LokiPost posts event to Loki
-func (c *Client) LokiPost(kubearmorpayload types.KubearmorPayload) {
-	c.Stats.Loki.Add(Total, 1)
-	c.ContentType = LokiContentType
+func (c *Client) LokiPost(kubearmorpayload types.KubearmorPayload) error {
+	return c.lokiPost(newLokiPayload(kubearmorpayload, c.Config), 1)
+}
+
+// LokiPostBatch posts events to Loki, grouped in streams
+func (c *Client) LokiPostBatch(events []types.KubearmorPayload) error {
+	return c.lokiPost(newLokiBatchPayload(events, c.Config), len(events), WithoutRetries())
+}
+
+func (c *Client) lokiPost(payload lokiPayload, events int, opts ...RequestOption) error {
+	c.Stats.Loki.Add(Total, int64(events))
+	opts = append(opts, WithContentType(LokiContentType))
 	if c.Config.Loki.Tenant != "" {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.AddHeader("X-Scope-OrgID", c.Config.Loki.Tenant)
+		opts = append(opts, WithHeader("X-Scope-OrgID", c.Config.Loki.Tenant))
 	}
 
 	if c.Config.Loki.User != "" && c.Config.Loki.APIKey != "" {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.BasicAuth(c.Config.Loki.User, c.Config.Loki.APIKey)
+		opts = append(opts, WithBasicAuth(c.Config.Loki.User, c.Config.Loki.APIKey))
 	}
 
-	for i, j := range c.Config.Loki.CustomHeaders {
-		c.AddHeader(i, j)
-	}
+	opts = append(opts, WithHeaders(c.Config.Loki.CustomHeaders))
 
-	err := c.Post(newLokiPayload(kubearmorpayload, c.Config))
+	err := c.Post(payload, opts...)
 	if err != nil {
-		go c.CountMetric(Outputs, 1, []string{"output:loki", "status:error"})
-		c.Stats.Loki.Add(Error, 1)
-		c.PromStats.Outputs.With(map[string]string{"destination": "loki", "status": Error}).Inc()
+		go c.CountMetric(Outputs, int64(events), []string{"output:loki", "status:error"})
+		c.Stats.Loki.Add(Error, int64(events))
+		c.PromStats.Outputs.With(map[string]string{"destination": "loki", "status": Error}).Add(float64(events))
 		log.Printf("[ERROR] : Loki - %v\n", err)
-		return
+		return err
 	}
 
-	go c.CountMetric(Outputs, 1, []string{"output:loki", "status:ok"})
-	c.Stats.Loki.Add(OK, 1)
-	c.PromStats.Outputs.With(map[string]string{"destination": "loki", "status": OK}).Inc()
+	go c.CountMetric(Outputs, int64(events), []string{"output:loki", "status:ok"})
+	c.Stats.Loki.Add(OK, int64(events))
+	c.PromStats.Outputs.With(map[string]string{"destination": "loki", "status": OK}).Add(float64(events))
+
+	return nil
 }
@@ -5,9 +5,26 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Mattermost",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Mattermost.WebhookURL != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Mattermost.MinimumPriority },
+		New:             newMattermostClient,
+		Send:            (*Client).MattermostPost,
+	})
+}
+
+func newMattermostClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Mattermost", config.Mattermost.WebhookURL, config.Mattermost.MutualTLS, config.Mattermost.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 func newMattermostPayload(kubearmorpayload types.KubearmorPayload, config *types.Configuration) slackPayload {
 	var (
 		messageText string
@@ -96,7 +113,7 @@ func newMattermostPayload(kubearmorpayload types.KubearmorPayload, config *types
 }
 
 // MattermostPost posts event to Mattermost
-func (c *Client) MattermostPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) MattermostPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Mattermost.Add(Total, 1)
 
 	err := c.Post(newMattermostPayload(kubearmorpayload, c.Config))
@@ -105,11 +122,13 @@ func (c *Client) MattermostPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Mattermost.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "mattermost", "status": Error}).Inc()
 		log.Printf("[ERROR] : Mattermost - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:mattermost", "status:ok"})
 	c.Stats.Mattermost.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "mattermost", "status": OK}).Inc()
+
+	return nil
 }
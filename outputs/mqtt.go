@@ -1,10 +1,9 @@
 package outputs
 
 import (
+	"context"
 	"crypto/tls"
-	"fmt"
 	"log"
-	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -13,6 +12,18 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "MQTT",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.MQTT.Broker != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.MQTT.MinimumPriority },
+		New:             NewMQTTClient,
+		Send:            (*Client).MQTTPublish,
+		Close:           (*Client).CloseMQTT,
+	})
+}
+
 // NewMQTTClient returns a new output.Client for accessing Kubernetes.
 func NewMQTTClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics,
 	statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
@@ -48,7 +59,7 @@ func NewMQTTClient(config *types.Configuration, stats *types.Statistics, promSta
 }
 
 // MQTTPublish .
-func (c *Client) MQTTPublish(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) MQTTPublish(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.MQTT.Add(Total, 1)
 
 	t := c.MQTTClient.Connect()
@@ -58,7 +69,7 @@ func (c *Client) MQTTPublish(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.MQTT.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "mqtt", "status": err.Error()}).Inc()
 		log.Printf("[ERROR] : %s - %v\n", MQTT, err.Error())
-		return
+		return err
 	}
 	defer c.MQTTClient.Disconnect(100)
 	if err := c.MQTTClient.Publish(c.Config.MQTT.Topic, byte(c.Config.MQTT.QOS), c.Config.MQTT.Retained, kubearmorpayload.String()).Error(); err != nil {
@@ -66,54 +77,22 @@ func (c *Client) MQTTPublish(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.MQTT.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "mqtt", "status": Error}).Inc()
 		log.Printf("[ERROR] : %s - %v\n", MQTT, err.Error())
-		return
+		return err
 	}
 
 	log.Printf("[INFO]  : %s - Message published\n", MQTT)
 	go c.CountMetric(Outputs, 1, []string{"output:mqtt", "status:ok"})
 	c.Stats.MQTT.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "mqtt", "status": OK}).Inc()
-}
 
-func (c *Client) WatchMQTTPublishAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	fmt.Println("discord running")
-	for AlertRunning {
-		select {
-		case resp := <-conn:
-			fmt.Println("response \n", resp)
-			c.MQTTPublish(resp)
-		}
-	}
-	fmt.Println("discord stopped")
 	return nil
 }
 
-func (c *Client) WatchMQTTPublishLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.MQTTPublish(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
+// CloseMQTT disconnects from the broker, waiting up to the quiesce time of
+// 250ms for the messages being published
+func (c *Client) CloseMQTT(ctx context.Context) error {
+	if c.MQTTClient.IsConnected() {
+		c.MQTTClient.Disconnect(250)
 	}
-
 	return nil
 }
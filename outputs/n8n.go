@@ -3,36 +3,52 @@ package outputs
 import (
 	"log"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "n8n",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.N8N.Address != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.N8N.MinimumPriority },
+		New:             newN8NClient,
+		Send:            (*Client).N8NPost,
+	})
+}
+
+func newN8NClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("n8n", config.N8N.Address, false, config.N8N.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // N8NPost posts event to an URL
-func (c *Client) N8NPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) N8NPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.N8N.Add(Total, 1)
 
+	var opts []RequestOption
 	if c.Config.N8N.User != "" && c.Config.N8N.Password != "" {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.BasicAuth(c.Config.N8N.User, c.Config.N8N.Password)
+		opts = append(opts, WithBasicAuth(c.Config.N8N.User, c.Config.N8N.Password))
 	}
 
 	if c.Config.N8N.HeaderAuthName != "" && c.Config.N8N.HeaderAuthValue != "" {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.AddHeader(c.Config.N8N.HeaderAuthName, c.Config.N8N.HeaderAuthValue)
+		opts = append(opts, WithHeader(c.Config.N8N.HeaderAuthName, c.Config.N8N.HeaderAuthValue))
 	}
 
-	err := c.Post(kubearmorpayload)
+	err := c.Post(kubearmorpayload, opts...)
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:n8n", "status:error"})
 		c.Stats.N8N.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "n8n", "status": Error}).Inc()
 		log.Printf("[ERROR] : N8N - %v\n", err.Error())
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:n8n", "status:ok"})
 	c.Stats.N8N.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "n8n", "status": OK}).Inc()
+
+	return nil
 }
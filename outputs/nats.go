@@ -5,25 +5,39 @@ import (
 	"log"
 	"regexp"
 	"strings"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/DataDog/datadog-go/statsd"
 	nats "github.com/nats-io/nats.go"
 
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "NATS",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Nats.HostPort != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Nats.MinimumPriority },
+		New:             newNatsClient,
+		Send:            (*Client).NatsPublish,
+	})
+}
+
+func newNatsClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("NATS", config.Nats.HostPort, config.Nats.MutualTLS, config.Nats.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 var slugRegularExpression = regexp.MustCompile("[^a-z0-9]+")
 
 // NatsPublish publishes event to NATS
-func (c *Client) NatsPublish(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) NatsPublish(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Nats.Add(Total, 1)
 
 	nc, err := nats.Connect(c.EndpointURL.String())
 	if err != nil {
 		c.setNatsErrorMetrics()
 		log.Printf("[ERROR] : NATS - %v\n", err)
-		return
+		return err
 	}
 	defer nc.Flush()
 	defer nc.Close()
@@ -32,20 +46,22 @@ func (c *Client) NatsPublish(kubearmorpayload types.KubearmorPayload) {
 	if err != nil {
 		c.setStanErrorMetrics()
 		log.Printf("[ERROR] : STAN - %v\n", err.Error())
-		return
+		return err
 	}
 
 	err = nc.Publish("kubearmor."+strings.ToLower(kubearmorpayload.EventType), j)
 	if err != nil {
 		c.setNatsErrorMetrics()
 		log.Printf("[ERROR] : NATS - %v\n", err)
-		return
+		return err
 	}
 
 	go c.CountMetric("outputs", 1, []string{"output:nats", "status:ok"})
 	c.Stats.Nats.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "nats", "status": OK}).Inc()
 	log.Printf("[INFO]  : NATS - Publish OK\n")
+
+	return nil
 }
 
 // setNatsErrorMetrics set the error stats
@@ -54,49 +70,3 @@ func (c *Client) setNatsErrorMetrics() {
 	c.Stats.Nats.Add(Error, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "nats", "status": Error}).Inc()
 }
-
-func (c *Client) WatchNatsPublishAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.NatsPublish(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchNatsPublishLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.NatsPublish(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
-
-	return nil
-}
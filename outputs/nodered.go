@@ -1,39 +1,50 @@
 package outputs
 
 import (
-	"encoding/base64"
 	"log"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "NodeRed",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.NodeRed.Address != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.NodeRed.MinimumPriority },
+		New:             newNodeRedClient,
+		Send:            (*Client).NodeRedPost,
+	})
+}
+
+func newNodeRedClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("NodeRed", config.NodeRed.Address, false, config.NodeRed.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // NodeRedPost posts event to Slack
-func (c *Client) NodeRedPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) NodeRedPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.NodeRed.Add(Total, 1)
 
-	c.httpClientLock.Lock()
-	defer c.httpClientLock.Unlock()
+	opts := []RequestOption{WithHeaders(c.Config.NodeRed.CustomHeaders)}
 	if c.Config.NodeRed.User != "" && c.Config.NodeRed.Password != "" {
-		c.AddHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.Config.NodeRed.User+":"+c.Config.NodeRed.Password)))
-	}
-
-	if len(c.Config.NodeRed.CustomHeaders) != 0 {
-		for i, j := range c.Config.NodeRed.CustomHeaders {
-			c.AddHeader(i, j)
-		}
+		opts = append(opts, WithBasicAuth(c.Config.NodeRed.User, c.Config.NodeRed.Password))
 	}
 
-	err := c.Post(kubearmorpayload)
+	err := c.Post(kubearmorpayload, opts...)
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:nodered", "status:error"})
 		c.Stats.NodeRed.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "nodered", "status": Error}).Inc()
 		log.Printf("[ERROR] : NodeRed - %v\n", err.Error())
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:nodered", "status:ok"})
 	c.Stats.NodeRed.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "nodered", "status": OK}).Inc()
+
+	return nil
 }
@@ -14,6 +14,17 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "OpenFaaS",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Openfaas.FunctionName != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Openfaas.MinimumPriority },
+		New:             NewOpenfaasClient,
+		Send:            (*Client).OpenfaasCall,
+	})
+}
+
 // NewOpenfaasClient returns a new output.Client for accessing Kubernetes.
 func NewOpenfaasClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 	if config.Openfaas.Kubeconfig != "" {
@@ -49,7 +60,7 @@ func NewOpenfaasClient(config *types.Configuration, stats *types.Statistics, pro
 }
 
 // OpenfaasCall .
-func (c *Client) OpenfaasCall(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) OpenfaasCall(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Openfaas.Add(Total, 1)
 
 	if c.Config.Openfaas.Kubeconfig != "" {
@@ -66,7 +77,7 @@ func (c *Client) OpenfaasCall(kubearmorpayload types.KubearmorPayload) {
 			c.Stats.Openfaas.Add(Error, 1)
 			c.PromStats.Outputs.With(map[string]string{"destination": "openfaas", "status": Error}).Inc()
 			log.Printf("[ERROR] : %v - %v\n", Openfaas, err)
-			return
+			return err
 		}
 		log.Printf("[INFO]  : %v - Function Response : %v\n", Openfaas, string(rawbody))
 	} else {
@@ -76,11 +87,13 @@ func (c *Client) OpenfaasCall(kubearmorpayload types.KubearmorPayload) {
 			c.Stats.Openfaas.Add(Error, 1)
 			c.PromStats.Outputs.With(map[string]string{"destination": "openfaas", "status": Error}).Inc()
 			log.Printf("[ERROR] : %v - %v\n", Openfaas, err)
-			return
+			return err
 		}
 	}
 	log.Printf("[INFO]  : %v - Call Function \"%v\" OK\n", Openfaas, c.Config.Openfaas.FunctionName+"."+c.Config.Openfaas.FunctionNamespace)
 	go c.CountMetric(Outputs, 1, []string{"output:openfaas", "status:ok"})
 	c.Stats.Openfaas.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "openfaas", "status": OK}).Inc()
+
+	return nil
 }
@@ -1,40 +1,114 @@
 package outputs
 
 import (
+	"bytes"
+	"encoding/json"
 	"log"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "OpenObserve",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.OpenObserve.HostPort != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.OpenObserve.MinimumPriority },
+		New:             newOpenObserveClient,
+		Send:            (*Client).OpenObservePost,
+		SendBatch:       (*Client).OpenObservePostBatch,
+	})
+}
+
+func newOpenObserveClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("OpenObserve", config.OpenObserve.HostPort+"/api/"+config.OpenObserve.OrganizationName+"/"+config.OpenObserve.StreamName+"/_multi", config.OpenObserve.MutualTLS, config.OpenObserve.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // OpenObservePost posts event to OpenObserve
-func (c *Client) OpenObservePost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) OpenObservePost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.OpenObserve.Add(Total, 1)
 
-	if c.Config.OpenObserve.Username != "" && c.Config.OpenObserve.Password != "" {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.BasicAuth(c.Config.OpenObserve.Username, c.Config.OpenObserve.Password)
+	if err := c.Post(kubearmorpayload, c.openObserveOptions()...); err != nil {
+		c.setOpenObserveErrorMetrics(1)
+		log.Printf("[ERROR] : OpenObserve - %v\n", err)
+		return err
 	}
 
-	for i, j := range c.Config.OpenObserve.CustomHeaders {
-		c.AddHeader(i, j)
+	// Setting the success status
+	go c.CountMetric(Outputs, 1, []string{"output:openobserve", "status:ok"})
+	c.Stats.OpenObserve.Add(OK, 1)
+	c.PromStats.Outputs.With(map[string]string{"destination": "openobserve", "status": OK}).Inc()
+
+	return nil
+}
+
+// openObserveResponse is the part of the response of the _multi API counting
+// the events which failed, it doesn't tell which ones
+type openObserveResponse struct {
+	Status []struct {
+		Failed int `json:"failed"`
+	} `json:"status"`
+}
+
+// OpenObservePostBatch posts events to OpenObserve, one JSON document per line
+func (c *Client) OpenObservePostBatch(events []types.KubearmorPayload) error {
+	c.Stats.OpenObserve.Add(Total, int64(len(events)))
+
+	body := new(bytes.Buffer)
+	encoder := json.NewEncoder(body)
+	for _, kubearmorpayload := range events {
+		if err := encoder.Encode(kubearmorpayload); err != nil {
+			c.setOpenObserveErrorMetrics(len(events))
+			log.Printf("[ERROR] : OpenObserve - %v\n", err)
+			return err
+		}
 	}
 
-	if err := c.Post(kubearmorpayload); err != nil {
-		c.setOpenObserveErrorMetrics()
+	var response []byte
+	if err := c.Post(body.Bytes(), append(c.openObserveOptions(), WithResponseBody(&response), WithoutRetries())...); err != nil {
+		c.setOpenObserveErrorMetrics(len(events))
 		log.Printf("[ERROR] : OpenObserve - %v\n", err)
-		return
+		return err
+	}
+
+	failed := 0
+	var r openObserveResponse
+	if err := json.Unmarshal(response, &r); err == nil {
+		for _, status := range r.Status {
+			failed += status.Failed
+		}
+	}
+	if failed > len(events) {
+		failed = len(events)
+	}
+	if failed != 0 {
+		c.setOpenObserveErrorMetrics(failed)
+		log.Printf("[ERROR] : OpenObserve - %v events of the batch failed\n", failed)
 	}
 
 	// Setting the success status
-	go c.CountMetric(Outputs, 1, []string{"output:openobserve", "status:ok"})
-	c.Stats.OpenObserve.Add(OK, 1)
-	c.PromStats.Outputs.With(map[string]string{"destination": "openobserve", "status": OK}).Inc()
+	go c.CountMetric(Outputs, int64(len(events)-failed), []string{"output:openobserve", "status:ok"})
+	c.Stats.OpenObserve.Add(OK, int64(len(events)-failed))
+	c.PromStats.Outputs.With(map[string]string{"destination": "openobserve", "status": OK}).Add(float64(len(events) - failed))
+
+	return nil
+}
+
+// openObserveOptions returns the custom headers and the credentials of the
+// requests
+func (c *Client) openObserveOptions() []RequestOption {
+	opts := []RequestOption{WithHeaders(c.Config.OpenObserve.CustomHeaders)}
+	if c.Config.OpenObserve.Username != "" && c.Config.OpenObserve.Password != "" {
+		opts = append(opts, WithBasicAuth(c.Config.OpenObserve.Username, c.Config.OpenObserve.Password))
+	}
+	return opts
 }
 
 // setOpenObserveErrorMetrics set the error stats
-func (c *Client) setOpenObserveErrorMetrics() {
-	go c.CountMetric(Outputs, 1, []string{"output:openobserve", "status:error"})
-	c.Stats.OpenObserve.Add(Error, 1)
-	c.PromStats.Outputs.With(map[string]string{"destination": "openobserve", "status": Error}).Inc()
+func (c *Client) setOpenObserveErrorMetrics(events int) {
+	go c.CountMetric(Outputs, int64(events), []string{"output:openobserve", "status:error"})
+	c.Stats.OpenObserve.Add(Error, int64(events))
+	c.PromStats.Outputs.With(map[string]string{"destination": "openobserve", "status": Error}).Add(float64(events))
 }
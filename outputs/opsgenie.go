@@ -5,9 +5,31 @@ import (
 	"log"
 	"strings"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Opsgenie",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Opsgenie.APIKey != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Opsgenie.MinimumPriority },
+		New:             newOpsgenieClient,
+		Send:            (*Client).OpsgeniePost,
+	})
+}
+
+func newOpsgenieClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	url := "https://api.opsgenie.com/v2/alerts"
+	if strings.ToLower(config.Opsgenie.Region) == "eu" {
+		url = "https://api.eu.opsgenie.com/v2/alerts"
+	}
+
+	return NewClient("Opsgenie", url, config.Opsgenie.MutualTLS, config.Opsgenie.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 type opsgeniePayload struct {
 	Message     string            `json:"message"`
 	Entity      string            `json:"entity,omitempty"`
@@ -43,7 +65,7 @@ func newOpsgeniePayload(kubearmorpayload types.KubearmorPayload, config *types.C
 	}
 
 	return opsgeniePayload{
-		Message:     kubearmorpayload.EventType + " for " + kubearmorpayload.OutputFields["PodName"].(string),
+		Message:     kubearmorpayload.EventType + " for " + outputField(kubearmorpayload, "PodName"),
 		Entity:      "Kubearmor",
 		Description: kubearmorpayload.EventType,
 		Details:     details,
@@ -52,23 +74,22 @@ func newOpsgeniePayload(kubearmorpayload types.KubearmorPayload, config *types.C
 }
 
 // OpsgeniePost posts event to OpsGenie
-func (c *Client) OpsgeniePost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) OpsgeniePost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Opsgenie.Add(Total, 1)
-	c.httpClientLock.Lock()
-	defer c.httpClientLock.Unlock()
-	c.AddHeader(AuthorizationHeaderKey, "GenieKey "+c.Config.Opsgenie.APIKey)
 
-	err := c.Post(newOpsgeniePayload(kubearmorpayload, c.Config))
+	err := c.Post(newOpsgeniePayload(kubearmorpayload, c.Config), WithHeader(AuthorizationHeaderKey, "GenieKey "+c.Config.Opsgenie.APIKey))
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:opsgenie", "status:error"})
 		c.Stats.Opsgenie.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "opsgenie", "status": Error}).Inc()
 		log.Printf("[ERROR] : OpsGenie - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:opsgenie", "status:ok"})
 	c.Stats.Opsgenie.Add("ok", 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "opsgenie", "status": OK}).Inc()
+
+	return nil
 }
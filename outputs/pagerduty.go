@@ -6,18 +6,34 @@ import (
 	"strings"
 	"time"
 
+	"github.com/DataDog/datadog-go/statsd"
 	"github.com/PagerDuty/go-pagerduty"
 
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Pagerduty",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Pagerduty.RoutingKey != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Pagerduty.MinimumPriority },
+		New:             newPagerdutyClient,
+		Send:            (*Client).PagerdutyPost,
+	})
+}
+
+func newPagerdutyClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Pagerduty", "https://events.pagerduty.com/v2/enqueue", config.Pagerduty.MutualTLS, config.Pagerduty.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 const (
 	USEndpoint string = "https://events.pagerduty.com"
 	EUEndpoint string = "https://events.eu.pagerduty.com"
 )
 
 // PagerdutyPost posts alert event to Pagerduty
-func (c *Client) PagerdutyPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) PagerdutyPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Pagerduty.Add(Total, 1)
 
 	event := createPagerdutyEvent(kubearmorpayload, c.Config.Pagerduty)
@@ -33,19 +49,21 @@ func (c *Client) PagerdutyPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Pagerduty.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "pagerduty", "status": Error}).Inc()
 		log.Printf("[ERROR] : PagerDuty - %v\n", err)
-		return
+		return err
 	}
 
 	go c.CountMetric(Outputs, 1, []string{"output:pagerduty", "status:ok"})
 	c.Stats.Pagerduty.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "pagerduty", "status": OK}).Inc()
 	log.Printf("[INFO]  : Pagerduty - Create Incident OK\n")
+
+	return nil
 }
 
 func createPagerdutyEvent(kubearmorpayload types.KubearmorPayload, config types.PagerdutyConfig) pagerduty.V2Event {
 	details := make(map[string]interface{}, len(kubearmorpayload.OutputFields)+4)
 	details["priority"] = kubearmorpayload.EventType
-	details["source"] = kubearmorpayload.OutputFields["PodName"].(string)
+	details["source"] = outputField(kubearmorpayload, "PodName")
 	if len(kubearmorpayload.Hostname) != 0 {
 		kubearmorpayload.OutputFields[Hostname] = kubearmorpayload.Hostname
 	}
@@ -55,7 +73,7 @@ func createPagerdutyEvent(kubearmorpayload types.KubearmorPayload, config types.
 		Action:     "trigger",
 		Payload: &pagerduty.V2Payload{
 			Source:    "Kubearmor",
-			Summary:   kubearmorpayload.EventType + " for " + kubearmorpayload.OutputFields["PodName"].(string),
+			Summary:   kubearmorpayload.EventType + " for " + outputField(kubearmorpayload, "PodName"),
 			Severity:  "critical",
 			Timestamp: timestamp.Format(time.RFC3339),
 			Details:   kubearmorpayload.OutputFields,
@@ -23,6 +23,17 @@ import (
 	"k8s.io/client-go/util/retry"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "PolicyReport",
+		EventTypes:      []string{AlertEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.PolicyReport.Enabled },
+		MinimumPriority: func(config *types.Configuration) string { return config.PolicyReport.MinimumPriority },
+		New:             NewPolicyReportClient,
+		Send:            (*Client).UpdateOrCreatePolicyReport,
+	})
+}
+
 type resource struct {
 	apiVersion string
 	kind       string
@@ -50,7 +61,6 @@ const (
 )
 
 var (
-	minimumPriority string //nolint: unused
 	//slice of policy reports
 	policyReports = make(map[string]*wgpolicy.PolicyReport)
 	//cluster policy report
@@ -91,7 +101,6 @@ var (
 
 func NewPolicyReportClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 	clusterPolicyReport.ObjectMeta.Name += uuid.NewString()[:8]
-	minimumPriority = config.PolicyReport.MinimumPriority
 
 	clientConfig, err := rest.InClusterConfig()
 	if err != nil {
@@ -133,7 +142,7 @@ func NewPolicyReportClient(config *types.Configuration, stats *types.Statistics,
 }
 
 // UpdateOrCreatePolicyReport creates/updates PolicyReport/ClusterPolicyReport Resource in Kubernetes
-func (c *Client) UpdateOrCreatePolicyReport(payload types.KubearmorPayload) {
+func (c *Client) UpdateOrCreatePolicyReport(payload types.KubearmorPayload) error {
 	c.Stats.PolicyReport.Add(Total, 1)
 
 	event, namespace := newResult(payload)
@@ -154,6 +163,8 @@ func (c *Client) UpdateOrCreatePolicyReport(payload types.KubearmorPayload) {
 		c.Stats.PolicyReport.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "policyreport", "status": Error}).Inc()
 	}
+
+	return err
 }
 
 // newResult creates a new entry for Reports
@@ -432,26 +443,3 @@ func determineResourceName(outputFields map[string]interface{}) string {
 func toString(value interface{}) string {
 	return fmt.Sprintf("%v", value)
 }
-
-func (c *Client) WatchPolicyAlerts() error {
-	uid := "policy"
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.UpdateOrCreatePolicyReport(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
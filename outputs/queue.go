@@ -0,0 +1,581 @@
+package outputs
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// Fsync policies of the queues
+const (
+	// FsyncAlways syncs every write to disk
+	FsyncAlways string = "always"
+	// FsyncInterval syncs the writes at most every FsyncInterval
+	FsyncInterval string = "interval"
+	// FsyncNever leaves the sync to the OS
+	FsyncNever string = "never"
+)
+
+// ErrQueueFull is returned when an event is appended to a queue at its size cap
+var ErrQueueFull = errors.New("queue full")
+
+const (
+	segmentExt = ".seg"
+	cursorName = "cursor"
+	// recordHeaderSize is the size of the length, the checksum and the time
+	// written before every event
+	recordHeaderSize = 16
+)
+
+// Queue is a write-ahead queue on disk made of segment files. The events are
+// appended by the dispatcher and removed once the output acknowledges them,
+// so the events not sent yet survive a restart.
+type Queue struct {
+	dir    string
+	output string
+	config types.QueueConfig
+
+	lock sync.Mutex
+	// ready is signaled when an event is appended
+	ready    chan struct{}
+	segments []*segment
+	writer   *os.File
+	// reader reads the first segment from readOffset
+	reader     *os.File
+	readOffset int64
+	// readSeq is the sequence number of the next event to acknowledge, saved
+	// in the cursor file
+	readSeq uint64
+	cursor  *os.File
+	// size is the size of the events not acknowledged
+	size     int64
+	events   int
+	head     *queueRecord
+	lastSync time.Time
+	// replayPending counts the events found at startup not sent yet
+	replayPending int
+	dropped       atomic.Uint64
+	// corrupted counts the events skipped because they can't be read back
+	corrupted atomic.Uint64
+}
+
+type segment struct {
+	// first is the sequence number of the first event
+	first  uint64
+	events int
+	size   int64
+}
+
+type queueRecord struct {
+	payload types.KubearmorPayload
+	time    time.Time
+	size    int64
+}
+
+// QueueStat is the state of the queue of an output
+type QueueStat struct {
+	Output        string  `json:"output"`
+	Events        int     `json:"events"`
+	Bytes         int64   `json:"bytes"`
+	OldestAge     float64 `json:"oldest_age_seconds"`
+	ReplayPending int     `json:"replay_pending"`
+	Dropped       uint64  `json:"dropped"`
+	Corrupted     uint64  `json:"corrupted"`
+}
+
+var (
+	queuesLock sync.Mutex
+	queues     = make(map[*Queue]struct{})
+)
+
+// OpenQueue opens the queue of the output in config.Directory, the events left
+// by a previous run are sent first. The queue is reported by QueueStats until
+// Close is called.
+func OpenQueue(config types.QueueConfig, output string) (*Queue, error) {
+	q := &Queue{
+		dir:    filepath.Join(config.Directory, strings.ToLower(output)),
+		output: output,
+		config: config,
+		ready:  make(chan struct{}, 1),
+	}
+	if err := os.MkdirAll(q.dir, 0750); err != nil {
+		return nil, err
+	}
+	if err := q.load(); err != nil {
+		q.closeFiles()
+		return nil, err
+	}
+	q.replayPending = q.events
+
+	queuesLock.Lock()
+	defer queuesLock.Unlock()
+	queues[q] = struct{}{}
+	return q, nil
+}
+
+// load reads the cursor and the segments, it truncates the partial event a
+// crash may have left at the end of a segment.
+func (q *Queue) load() error {
+	var err error
+	q.cursor, err = os.OpenFile(filepath.Join(q.dir, cursorName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 8)
+	if n, err := q.cursor.ReadAt(b, 0); err == nil && n == 8 {
+		q.readSeq = binary.BigEndian.Uint64(b)
+	} else if err != nil && err != io.EOF {
+		return err
+	}
+
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), segmentExt) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, &segment{first: first})
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].first < q.segments[j].first })
+
+	segments := q.segments[:0]
+	for _, s := range q.segments {
+		if err := q.scan(s); err != nil {
+			return err
+		}
+		if s.first+uint64(s.events) <= q.readSeq && s != q.segments[len(q.segments)-1] {
+			// every event of the segment was acknowledged
+			if err := os.Remove(q.segmentPath(s)); err != nil {
+				return err
+			}
+			continue
+		}
+		segments = append(segments, s)
+	}
+	q.segments = segments
+
+	if len(q.segments) == 0 {
+		return q.rotate()
+	}
+	last := q.segments[len(q.segments)-1]
+	if q.readSeq < q.segments[0].first {
+		q.readSeq = q.segments[0].first
+	}
+	if end := last.first + uint64(last.events); q.readSeq > end {
+		q.readSeq = end
+	}
+	for _, s := range q.segments {
+		q.size += s.size
+		q.events += s.events
+	}
+
+	if q.reader, err = os.Open(q.segmentPath(q.segments[0])); err != nil {
+		return err
+	}
+	first := q.segments[0]
+	for seq := first.first; seq < q.readSeq && seq < first.first+uint64(first.events); seq++ {
+		r, err := q.readRecord(q.reader, q.readOffset, first.size, false)
+		if r == nil {
+			return err
+		}
+		q.readOffset += r.size
+		q.size -= r.size
+		q.events--
+	}
+
+	q.writer, err = os.OpenFile(q.segmentPath(last), os.O_WRONLY|os.O_APPEND, 0600)
+	return err
+}
+
+// scan counts the events of the segment, the corrupted ones included since
+// they are skipped when read, it truncates the segment after the last
+// complete event. An event with a bad length is counted as corrupted and the
+// segment is truncated before it, the events after it can't be found.
+func (q *Queue) scan(s *segment) error {
+	f, err := os.Open(q.segmentPath(s))
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	var offset int64
+	for {
+		r, err := q.readRecord(f, offset, fi.Size(), false)
+		if r == nil {
+			if errors.Is(err, errCorruptedRecord) {
+				log.Printf("[ERROR] : %v - Truncating a segment of the queue: %v\n", q.output, err)
+				q.corrupted.Add(1)
+			}
+			break
+		}
+		offset += r.size
+		s.events++
+	}
+	f.Close()
+	s.size = offset
+	return os.Truncate(q.segmentPath(s), offset)
+}
+
+// errCorruptedRecord is returned for an event whose checksum or payload is
+// invalid, it can be skipped since its size is known, or whose length is
+// invalid, the rest of the segment can't be read then.
+var errCorruptedRecord = errors.New("corrupted event")
+
+// readRecord reads the event at the offset of the segment of size bytes, the
+// payload is only decoded if decode is true. If the event is complete but
+// can't be read back, it returns the record, to get its size, with
+// errCorruptedRecord. If its length goes past the end of the segment, it
+// returns errCorruptedRecord without record.
+func (q *Queue) readRecord(f *os.File, offset, size int64, decode bool) (*queueRecord, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := f.ReadAt(header, offset); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	// the length is checked before allocating the payload, a corrupted one
+	// could be up to 4GB
+	if int64(length) > size-offset-recordHeaderSize {
+		return nil, fmt.Errorf("%w at offset %v of %v: bad length %v", errCorruptedRecord, offset, f.Name(), length)
+	}
+	data := make([]byte, length)
+	if _, err := f.ReadAt(data, offset+recordHeaderSize); err != nil {
+		return nil, err
+	}
+
+	r := &queueRecord{
+		time: time.Unix(0, int64(binary.BigEndian.Uint64(header[8:16]))),
+		size: recordHeaderSize + int64(length),
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return r, fmt.Errorf("%w at offset %v of %v: bad checksum", errCorruptedRecord, offset, f.Name())
+	}
+	if decode {
+		if err := json.Unmarshal(data, &r.payload); err != nil {
+			return r, fmt.Errorf("%w at offset %v of %v: %v", errCorruptedRecord, offset, f.Name(), err)
+		}
+	}
+	return r, nil
+}
+
+func (q *Queue) segmentPath(s *segment) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", s.first, segmentExt))
+}
+
+// rotate starts a new segment for the next events
+func (q *Queue) rotate() error {
+	first := q.readSeq
+	if len(q.segments) != 0 {
+		last := q.segments[len(q.segments)-1]
+		first = last.first + uint64(last.events)
+	}
+	if q.writer != nil {
+		if err := q.writer.Sync(); err != nil {
+			return err
+		}
+		q.writer.Close()
+	}
+
+	s := &segment{first: first}
+	var err error
+	q.writer, err = os.OpenFile(q.segmentPath(s), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	q.segments = append(q.segments, s)
+	if q.reader == nil {
+		q.reader, err = os.Open(q.segmentPath(s))
+	}
+	return err
+}
+
+// Append writes the event at the end of the queue, it returns ErrQueueFull if
+// the queue reached its size cap. The events which can't be written are
+// counted as dropped.
+func (q *Queue) Append(kubearmorpayload types.KubearmorPayload) error {
+	err := q.append(kubearmorpayload)
+	if err != nil {
+		q.dropped.Add(1)
+	}
+	return err
+}
+
+func (q *Queue) append(kubearmorpayload types.KubearmorPayload) error {
+	data, err := json.Marshal(kubearmorpayload)
+	if err != nil {
+		return err
+	}
+	record := make([]byte, recordHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	binary.BigEndian.PutUint64(record[8:16], uint64(time.Now().UnixNano()))
+	copy(record[recordHeaderSize:], data)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.size+int64(len(record)) > int64(q.config.MaxSize)<<20 {
+		return ErrQueueFull
+	}
+	last := q.segments[len(q.segments)-1]
+	if last.events != 0 && last.size >= int64(q.config.SegmentSize)<<20 {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+	if _, err := q.writer.Write(record); err != nil {
+		return err
+	}
+	last.events++
+	last.size += int64(len(record))
+	q.size += int64(len(record))
+	q.events++
+	if err := q.sync(q.writer); err != nil {
+		return err
+	}
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// sync syncs the file according to the fsync policy
+func (q *Queue) sync(f *os.File) error {
+	switch q.config.Fsync {
+	case FsyncAlways:
+		return f.Sync()
+	case FsyncInterval:
+		if time.Since(q.lastSync) < time.Duration(q.config.FsyncInterval)*time.Millisecond {
+			return nil
+		}
+		q.lastSync = time.Now()
+		if err := q.writer.Sync(); err != nil {
+			return err
+		}
+		return q.cursor.Sync()
+	}
+	return nil
+}
+
+// Peek returns the oldest event not acknowledged, it waits for one until the
+// context is canceled. The same event is returned until Ack is called.
+func (q *Queue) Peek(ctx context.Context) (types.KubearmorPayload, error) {
+	for {
+		q.lock.Lock()
+		if q.head == nil && q.events > 0 {
+			if err := q.readHead(); err != nil {
+				q.lock.Unlock()
+				return types.KubearmorPayload{}, err
+			}
+		}
+		head := q.head
+		q.lock.Unlock()
+		if head != nil {
+			return head.payload, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return types.KubearmorPayload{}, ctx.Err()
+		case <-q.ready:
+		}
+	}
+}
+
+// readHead reads the oldest event, after removing the segments which were
+// entirely acknowledged. The corrupted events are logged, counted and
+// skipped, the head is nil if there are only corrupted events left.
+func (q *Queue) readHead() error {
+	for q.events > 0 {
+		for q.readOffset >= q.segments[0].size && len(q.segments) > 1 {
+			q.reader.Close()
+			if err := os.Remove(q.segmentPath(q.segments[0])); err != nil {
+				return err
+			}
+			q.segments = q.segments[1:]
+			q.readOffset = 0
+			// the events truncated from the segments are not numbered
+			if q.readSeq < q.segments[0].first {
+				q.readSeq = q.segments[0].first
+			}
+			var err error
+			if q.reader, err = os.Open(q.segmentPath(q.segments[0])); err != nil {
+				return err
+			}
+		}
+		s := q.segments[0]
+		r, err := q.readRecord(q.reader, q.readOffset, s.size, true)
+		if err == nil {
+			q.head = r
+			return nil
+		}
+		if r == nil && !errors.Is(err, errCorruptedRecord) {
+			return err
+		}
+		if r == nil {
+			// the events left in the segment can't be found
+			events := int(s.first + uint64(s.events) - q.readSeq)
+			log.Printf("[ERROR] : %v - Skipping %v events of the queue: %v\n", q.output, events, err)
+			q.corrupted.Add(uint64(events))
+			if err := q.skip(s.size-q.readOffset, events); err != nil {
+				return err
+			}
+			continue
+		}
+		log.Printf("[ERROR] : %v - Skipping an event of the queue: %v\n", q.output, err)
+		q.corrupted.Add(1)
+		if err := q.remove(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ack removes the event returned by Peek from the queue
+func (q *Queue) Ack() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.head == nil {
+		return nil
+	}
+	head := q.head
+	q.head = nil
+	return q.remove(head)
+}
+
+// remove moves the cursor past the oldest event, which was acknowledged or
+// skipped.
+func (q *Queue) remove(r *queueRecord) error {
+	return q.skip(r.size, 1)
+}
+
+// skip moves the cursor past the size bytes of the oldest events
+func (q *Queue) skip(size int64, events int) error {
+	q.readOffset += size
+	q.readSeq += uint64(events)
+	q.size -= size
+	q.events -= events
+	q.replayPending -= events
+	if q.replayPending < 0 {
+		q.replayPending = 0
+	}
+
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, q.readSeq)
+	if _, err := q.cursor.WriteAt(b, 0); err != nil {
+		return err
+	}
+	return q.sync(q.cursor)
+}
+
+// Close syncs and closes the files of the queue, the events not acknowledged
+// are sent after the next start.
+func (q *Queue) Close() error {
+	queuesLock.Lock()
+	delete(queues, q)
+	queuesLock.Unlock()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var errs []error
+	for _, f := range []*os.File{q.writer, q.cursor} {
+		if f != nil {
+			errs = append(errs, f.Sync())
+		}
+	}
+	errs = append(errs, q.closeFiles())
+	return errors.Join(errs...)
+}
+
+func (q *Queue) closeFiles() error {
+	var errs []error
+	for _, f := range []*os.File{q.writer, q.reader, q.cursor} {
+		if f != nil {
+			errs = append(errs, f.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (q *Queue) stat(now time.Time) QueueStat {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	s := QueueStat{
+		Output:        q.output,
+		Events:        q.events,
+		Bytes:         q.size,
+		ReplayPending: q.replayPending,
+		Dropped:       q.dropped.Load(),
+		Corrupted:     q.corrupted.Load(),
+	}
+	oldest := q.head
+	if oldest == nil && q.events > 0 && q.readOffset < q.segments[0].size {
+		if r, err := q.readRecord(q.reader, q.readOffset, q.segments[0].size, false); err == nil {
+			oldest = r
+		}
+	}
+	if oldest != nil {
+		s.OldestAge = now.Sub(oldest.time).Seconds()
+	}
+	return s
+}
+
+// QueueStats returns the state of the queues of the outputs, sorted by output.
+func QueueStats() []QueueStat {
+	queuesLock.Lock()
+	l := make([]*Queue, 0, len(queues))
+	for q := range queues {
+		l = append(l, q)
+	}
+	queuesLock.Unlock()
+
+	now := time.Now()
+	s := make([]QueueStat, 0, len(l))
+	for _, q := range l {
+		s = append(s, q.stat(now))
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Output < s[j].Output })
+	return s
+}
+
+// queueBackoff is the delay before sending again an event of a queue which
+// failed, after the retries of the output.
+var queueBackoff = Backoff{Base: time.Second, Max: time.Minute, Jitter: 0.2}
+
+// queuePolicy returns the queue settings of the output, the default ones if
+// the output has none of its own.
+func queuePolicy(queue types.QueueConfig, name string) types.QueueConfig {
+	if q, ok := queue.Outputs[strings.ToLower(name)]; ok {
+		return q
+	}
+	queue.Outputs = nil
+	return queue
+}
@@ -0,0 +1,301 @@
+package outputs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func testQueueConfig(t *testing.T) types.QueueConfig {
+	return types.QueueConfig{Enabled: true, Directory: t.TempDir(), SegmentSize: 1, MaxSize: 4, Fsync: FsyncAlways}
+}
+
+func queueEvent(i int, size int) types.KubearmorPayload {
+	return types.KubearmorPayload{EventType: AlertEventType, Hostname: strconv.Itoa(i), OutputFields: map[string]interface{}{"Data": strings.Repeat("x", size)}}
+}
+
+func TestQueueReplay(t *testing.T) {
+	config := testQueueConfig(t)
+	q, err := OpenQueue(config, "Replay")
+	require.Nil(t, err)
+	// 300KB events, the 1MB segments hold 4 of them
+	for i := 0; i < 6; i++ {
+		require.Nil(t, q.Append(queueEvent(i, 300<<10)))
+	}
+	for i := 0; i < 5; i++ {
+		p, err := q.Peek(context.Background())
+		require.Nil(t, err)
+		require.Equal(t, strconv.Itoa(i), p.Hostname)
+		require.Nil(t, q.Ack())
+	}
+	require.Nil(t, q.Append(queueEvent(6, 10)))
+	require.Nil(t, q.Close())
+
+	q, err = OpenQueue(config, "Replay")
+	require.Nil(t, err)
+	defer q.Close()
+	s := q.stat(time.Now())
+	require.Equal(t, 2, s.Events)
+	require.Equal(t, 2, s.ReplayPending)
+	require.Greater(t, s.OldestAge, 0.0)
+	for i := 5; i < 7; i++ {
+		p, err := q.Peek(context.Background())
+		require.Nil(t, err)
+		require.Equal(t, strconv.Itoa(i), p.Hostname)
+		require.Nil(t, q.Ack())
+	}
+	require.Equal(t, 0, q.stat(time.Now()).ReplayPending)
+
+	segments, err := filepath.Glob(filepath.Join(config.Directory, "replay", "*"+segmentExt))
+	require.Nil(t, err)
+	require.Len(t, segments, 1, "the acknowledged segments must be removed")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = q.Peek(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestQueueFull(t *testing.T) {
+	config := testQueueConfig(t)
+	config.MaxSize = 1
+	q, err := OpenQueue(config, "Full")
+	require.Nil(t, err)
+	defer q.Close()
+
+	require.Nil(t, q.Append(queueEvent(0, 400<<10)))
+	require.Nil(t, q.Append(queueEvent(1, 400<<10)))
+	require.ErrorIs(t, q.Append(queueEvent(2, 400<<10)), ErrQueueFull)
+	require.Equal(t, uint64(1), q.stat(time.Now()).Dropped)
+
+	var stats []QueueStat
+	for _, s := range QueueStats() {
+		if s.Output == "Full" {
+			stats = append(stats, s)
+		}
+	}
+	require.Len(t, stats, 1)
+	require.Equal(t, 2, stats[0].Events)
+}
+
+func TestQueueTornTail(t *testing.T) {
+	config := testQueueConfig(t)
+	q, err := OpenQueue(config, "Torn")
+	require.Nil(t, err)
+	require.Nil(t, q.Append(queueEvent(0, 10)))
+	require.Nil(t, q.Append(queueEvent(1, 10)))
+	require.Nil(t, q.Close())
+
+	segments, err := filepath.Glob(filepath.Join(config.Directory, "torn", "*"+segmentExt))
+	require.Nil(t, err)
+	require.Len(t, segments, 1)
+	f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0600)
+	require.Nil(t, err)
+	_, err = f.Write([]byte{0, 0, 1, 0, 1, 2, 3})
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	q, err = OpenQueue(config, "Torn")
+	require.Nil(t, err)
+	defer q.Close()
+	require.Equal(t, 2, q.stat(time.Now()).Events)
+	require.Nil(t, q.Append(queueEvent(2, 10)))
+	for i := 0; i < 3; i++ {
+		p, err := q.Peek(context.Background())
+		require.Nil(t, err)
+		require.Equal(t, strconv.Itoa(i), p.Hostname)
+		require.Nil(t, q.Ack())
+	}
+}
+
+func TestQueueCorrupted(t *testing.T) {
+	config := testQueueConfig(t)
+	q, err := OpenQueue(config, "Corrupted")
+	require.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		require.Nil(t, q.Append(queueEvent(i, 10)))
+	}
+	require.Nil(t, q.Close())
+
+	// flip the last byte of the payload of the event in the middle
+	segments, err := filepath.Glob(filepath.Join(config.Directory, "corrupted", "*"+segmentExt))
+	require.Nil(t, err)
+	require.Len(t, segments, 1)
+	data, err := os.ReadFile(segments[0])
+	require.Nil(t, err)
+	size := len(data) / 3
+	data[2*size-2] ^= 0xff
+	require.Nil(t, os.WriteFile(segments[0], data, 0600))
+
+	q, err = OpenQueue(config, "Corrupted")
+	require.Nil(t, err)
+	defer q.Close()
+	s := q.stat(time.Now())
+	require.Equal(t, 3, s.Events)
+	require.Equal(t, int64(len(data)), s.Bytes)
+
+	for _, i := range []int{0, 2} {
+		p, err := q.Peek(context.Background())
+		require.Nil(t, err)
+		require.Equal(t, strconv.Itoa(i), p.Hostname)
+		require.Nil(t, q.Ack())
+	}
+	s = q.stat(time.Now())
+	require.Equal(t, uint64(1), s.Corrupted)
+	require.Equal(t, 0, s.Events)
+	require.Zero(t, s.Bytes, "the acknowledged events must not be counted")
+}
+
+func TestQueueBadLength(t *testing.T) {
+	config := testQueueConfig(t)
+	q, err := OpenQueue(config, "Length")
+	require.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		require.Nil(t, q.Append(queueEvent(i, 10)))
+	}
+
+	// set the length of the event in the middle to 4GB
+	segments, err := filepath.Glob(filepath.Join(config.Directory, "length", "*"+segmentExt))
+	require.Nil(t, err)
+	require.Len(t, segments, 1)
+	data, err := os.ReadFile(segments[0])
+	require.Nil(t, err)
+	size := len(data) / 3
+	copy(data[size:], []byte{0xff, 0xff, 0xff, 0xff})
+	require.Nil(t, os.WriteFile(segments[0], data, 0600))
+
+	p, err := q.Peek(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, "0", p.Hostname)
+	require.Nil(t, q.Ack())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = q.Peek(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "the events after the bad length are skipped")
+	s := q.stat(time.Now())
+	require.Equal(t, uint64(2), s.Corrupted)
+	require.Equal(t, 0, s.Events)
+	require.Zero(t, s.Bytes)
+
+	require.Nil(t, q.Append(queueEvent(3, 10)))
+	p, err = q.Peek(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, "3", p.Hostname)
+	require.Nil(t, q.Close())
+
+	// the segment is truncated before the bad length when the queue is opened
+	require.Nil(t, os.WriteFile(segments[0], data, 0600))
+	require.Nil(t, os.Remove(filepath.Join(config.Directory, "length", cursorName)))
+	q, err = OpenQueue(config, "Length")
+	require.Nil(t, err)
+	defer q.Close()
+	s = q.stat(time.Now())
+	require.Equal(t, 1, s.Events)
+	require.Equal(t, uint64(1), s.Corrupted)
+	require.Equal(t, int64(size), s.Bytes)
+}
+
+func TestDispatchQueueFull(t *testing.T) {
+	config := testQueueConfig(t)
+	config.MaxSize = 0
+	q, err := OpenQueue(config, "DeadLetterFull")
+	require.Nil(t, err)
+	defer q.Close()
+
+	deadLetterConfig := types.DeadLetterConfig{Target: DeadLetterFile, File: filepath.Join(t.TempDir(), "deadletter.jsonl"), MaxSize: 1, MaxBackups: 1}
+	deadLetters, err := NewDeadLetterQueue(deadLetterConfig, nil)
+	require.Nil(t, err)
+	d := NewDispatcher(&types.Configuration{}, &types.Statistics{}, &types.PromStatistics{})
+	d.DeadLetters = deadLetters
+
+	o := &testOutput{name: "full"}
+	d.route(o, outputRoute{queue: q}, queueEvent(0, 10))
+	require.Nil(t, deadLetters.Close())
+
+	require.Equal(t, uint64(1), q.stat(time.Now()).Dropped)
+	l := readDeadLetters(t, deadLetterConfig.File)
+	require.Len(t, l, 1)
+	require.Equal(t, ErrQueueFull.Error(), l[0].Error)
+}
+
+func TestDispatchQueue(t *testing.T) {
+	Initvariable(types.BuffersConfig{})
+
+	o := &testOutput{name: "queued", eventTypes: []string{AlertEventType}, received: make(chan types.KubearmorPayload)}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewDispatcher(&types.Configuration{Queue: testQueueConfig(t)}, &types.Statistics{}, &types.PromStatistics{})
+	d.Dispatch(ctx, o)
+
+	require.Eventually(t, func() bool {
+		AlertLock.RLock()
+		defer AlertLock.RUnlock()
+		_, ok := AlertStructs["queued"]
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	BroadcastAlert(queueEvent(0, 10))
+	BroadcastAlert(queueEvent(1, 10))
+	require.Equal(t, "0", (<-o.received).Hostname)
+	require.Equal(t, "1", (<-o.received).Hostname)
+
+	cancel()
+	d.Wait()
+	for _, s := range QueueStats() {
+		require.NotEqual(t, "queued", s.Output, "the queue must be closed")
+	}
+}
+
+func TestDeliverDeadLetter(t *testing.T) {
+	defer func(b Backoff) { queueBackoff = b }(queueBackoff)
+	queueBackoff = Backoff{Base: time.Millisecond, Max: time.Millisecond}
+
+	deliver := func(name string, err error) ([]DeadLetter, QueueStat) {
+		q, qerr := OpenQueue(testQueueConfig(t), name)
+		require.Nil(t, qerr)
+		defer q.Close()
+		require.Nil(t, q.Append(queueEvent(0, 10)))
+
+		config := types.DeadLetterConfig{Target: DeadLetterFile, File: filepath.Join(t.TempDir(), "deadletter.jsonl"), MaxSize: 1, MaxBackups: 1}
+		deadLetters, qerr := NewDeadLetterQueue(config, nil)
+		require.Nil(t, qerr)
+		d := NewDispatcher(&types.Configuration{Retry: types.RetryConfig{MaxAttempts: 3}}, &types.Statistics{}, &types.PromStatistics{})
+		d.DeadLetters = deadLetters
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		d.deliver(ctx, &testOutput{name: name, err: err}, q)
+		require.Nil(t, deadLetters.Close())
+		return readDeadLetters(t, config.File), q.stat(time.Now())
+	}
+
+	// a transient failure is dead-lettered after the attempts of the retry policy
+	l, s := deliver("Transient", ErrBadGateway)
+	require.Len(t, l, 1)
+	require.Equal(t, 3, l[0].Attempts)
+	require.Equal(t, 0, s.Events)
+
+	// the attempts of the retries of the client count
+	l, s = deliver("Retried", &retriesError{err: ErrBadGateway, attempts: 3})
+	require.Len(t, l, 1)
+	require.Equal(t, 3, l[0].Attempts)
+	require.Equal(t, 0, s.Events)
+
+	// an event rejected for good is dead-lettered at once
+	l, s = deliver("Permanent", &permanentError{err: ErrHeaderMissing})
+	require.Len(t, l, 1)
+	require.Equal(t, 1, l[0].Attempts)
+	require.Equal(t, ErrHeaderMissing.Error(), l[0].Error)
+	require.Equal(t, 0, s.Events)
+
+	// the events short-circuited stay in the queue
+	l, s = deliver("Open", ErrCircuitOpen)
+	require.Empty(t, l)
+	require.Equal(t, 1, s.Events)
+}
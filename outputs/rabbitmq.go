@@ -1,16 +1,30 @@
 package outputs
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log"
-	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
 	"github.com/kubearmor/sidekick/types"
 	"github.com/streadway/amqp"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "RabbitMQ",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.Rabbitmq.URL != "" && config.Rabbitmq.Queue != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.Rabbitmq.MinimumPriority },
+		New:             NewRabbitmqClient,
+		Send:            (*Client).Publish,
+		Close:           (*Client).CloseRabbitmq,
+	})
+}
+
 // NewRabbitmqClient returns a new output.Client for accessing the RabbitmMQ API.
 func NewRabbitmqClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 
@@ -41,7 +55,7 @@ func NewRabbitmqClient(config *types.Configuration, stats *types.Statistics, pro
 }
 
 // Publish sends a message to a Rabbitmq
-func (c *Client) Publish(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) Publish(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Rabbitmq.Add(Total, 1)
 
 	payload, _ := json.Marshal(kubearmorpayload)
@@ -57,55 +71,18 @@ func (c *Client) Publish(kubearmorpayload types.KubearmorPayload) {
 		go c.CountMetric("outputs", 1, []string{"output:rabbitmq", "status:error"})
 		c.PromStats.Outputs.With(map[string]string{"destination": "rabbitmq", "status": Error}).Inc()
 
-		return
+		return err
 	}
 
 	log.Printf("[INFO]  : RabbitMQ - Send to message OK \n")
 	c.Stats.Rabbitmq.Add(OK, 1)
 	go c.CountMetric("outputs", 1, []string{"output:rabbitmq", "status:ok"})
 	c.PromStats.Outputs.With(map[string]string{"destination": "rabbitmq", "status": OK}).Inc()
-}
-
-func (c *Client) WatchRabbitmqPublishAlerts() error {
-	uid := "Rabbitmq"
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	Running := true
-	for Running {
-		select {
-		case resp := <-conn:
-			c.Publish(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
 
 	return nil
 }
 
-func (c *Client) WatchRabbitmqPublishLogs() error {
-	uid := "Rabbitmq"
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	Running := true
-	for Running {
-		select {
-		case resp := <-conn:
-			c.Publish(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
+// CloseRabbitmq closes the channel to RabbitMQ
+func (c *Client) CloseRabbitmq(ctx context.Context) error {
+	return c.RabbitmqClient.Close()
 }
@@ -0,0 +1,163 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// What happens to the events over the rate limit of an output
+const (
+	RateLimitQueue   string = "queue"
+	RateLimitDrop    string = "drop"
+	RateLimitSummary string = "summary"
+)
+
+// rateLimitPolicy returns the rate limit settings of the output, the default
+// ones if the output has none of its own.
+func rateLimitPolicy(rateLimit types.RateLimitConfig, name string) types.RateLimitConfig {
+	if r, ok := rateLimit.Outputs[strings.ToLower(name)]; ok {
+		return r
+	}
+	rateLimit.Outputs = nil
+	return rateLimit
+}
+
+// samplingPolicy returns the sampling settings of the output, the default
+// ones if the output has none of its own.
+func samplingPolicy(sampling types.SamplingConfig, name string) types.SamplingConfig {
+	if s, ok := sampling.Outputs[strings.ToLower(name)]; ok {
+		return s
+	}
+	sampling.Outputs = nil
+	return sampling
+}
+
+// RateLimiter limits the events sent to an output with a token bucket. The
+// events over the limit wait for a token, are dropped, or are dropped and
+// counted in a summary sent every SummaryInterval.
+type RateLimiter struct {
+	limiter  *rate.Limiter
+	overflow string
+	interval time.Duration
+	summary  func(kubearmorpayload types.KubearmorPayload)
+	// suppressed counts the events dropped since the last summary
+	suppressed atomic.Int64
+	done       chan struct{}
+	stopped    chan struct{}
+}
+
+// NewRateLimiter returns a RateLimiter calling summary with the summaries of
+// the events dropped, if the overflow of the events is summary.
+func NewRateLimiter(config types.RateLimitConfig, summary func(kubearmorpayload types.KubearmorPayload)) *RateLimiter {
+	l := &RateLimiter{
+		limiter:  rate.NewLimiter(rate.Limit(config.Rate), config.Burst),
+		overflow: config.Overflow,
+		interval: time.Duration(config.SummaryInterval) * time.Second,
+		summary:  summary,
+	}
+	if l.overflow == RateLimitSummary {
+		l.done = make(chan struct{})
+		l.stopped = make(chan struct{})
+		go l.run()
+	}
+	return l
+}
+
+// Allow reports whether the event can be sent. With the queue overflow, it
+// waits for the rate to allow the event, the events over the rate are dropped
+// once the context is canceled.
+func (l *RateLimiter) Allow(ctx context.Context) bool {
+	if l.overflow == RateLimitQueue {
+		return l.limiter.Allow() || l.limiter.Wait(ctx) == nil
+	}
+	if l.limiter.Allow() {
+		return true
+	}
+	if l.overflow == RateLimitSummary {
+		l.suppressed.Add(1)
+	}
+	return false
+}
+
+// Close sends the summary of the events dropped since the last one, no event
+// must be allowed afterwards.
+func (l *RateLimiter) Close() {
+	if l.done == nil {
+		return
+	}
+	close(l.done)
+	<-l.stopped
+}
+
+func (l *RateLimiter) run() {
+	defer close(l.stopped)
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.flush()
+		case <-l.done:
+			l.flush()
+			return
+		}
+	}
+}
+
+// flush sends the summary of the events dropped, if there are some
+func (l *RateLimiter) flush() {
+	suppressed := l.suppressed.Swap(0)
+	if suppressed == 0 {
+		return
+	}
+	l.summary(rateLimitSummary(suppressed, time.Now()))
+}
+
+// rateLimitSummary returns the summary of the events dropped, an alert with
+// none of the fields of the events.
+func rateLimitSummary(suppressed int64, now time.Time) types.KubearmorPayload {
+	return types.KubearmorPayload{
+		EventType: AlertEventType,
+		Timestamp: now.Unix(),
+		OutputFields: map[string]interface{}{
+			"Message":             fmt.Sprintf("%v additional events suppressed", suppressed),
+			"RateLimitSuppressed": suppressed,
+		},
+	}
+}
+
+// Sampler chooses the logs sent to an output, one out of every few logs or
+// each with a probability, the alerts are all sent.
+type Sampler struct {
+	rate  float64
+	every uint64
+	seen  atomic.Uint64
+}
+
+// newSampler returns the sampler of the settings, nil if every log is sent
+func newSampler(config types.SamplingConfig) *Sampler {
+	if config.Every <= 1 && config.Rate >= 1 {
+		return nil
+	}
+	return &Sampler{rate: config.Rate, every: uint64(config.Every)}
+}
+
+// Keep reports whether the event is sent
+func (s *Sampler) Keep(kubearmorpayload types.KubearmorPayload) bool {
+	if s == nil || kubearmorpayload.EventType != LogEventType {
+		return true
+	}
+	if s.every > 1 {
+		return (s.seen.Add(1)-1)%s.every == 0
+	}
+	return rand.Float64() < s.rate
+}
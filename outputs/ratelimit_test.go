@@ -0,0 +1,123 @@
+package outputs
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(types.RateLimitConfig{Rate: 0.001, Burst: 2, Overflow: RateLimitDrop}, nil)
+	require.True(t, l.Allow(context.Background()))
+	require.True(t, l.Allow(context.Background()), "the burst is sent at once")
+	require.False(t, l.Allow(context.Background()), "the events over the limit are dropped")
+	l.Close()
+
+	summaries := make(chan types.KubearmorPayload, 1)
+	l = NewRateLimiter(types.RateLimitConfig{Rate: 0.001, Burst: 1, Overflow: RateLimitSummary, SummaryInterval: 60}, func(kubearmorpayload types.KubearmorPayload) {
+		summaries <- kubearmorpayload
+	})
+	for i := 0; i < 4; i++ {
+		l.Allow(context.Background())
+	}
+	l.Close()
+	s := <-summaries
+	require.Equal(t, "3 additional events suppressed", s.OutputFields["Message"])
+	require.Equal(t, int64(3), s.OutputFields["RateLimitSuppressed"])
+
+	l = NewRateLimiter(types.RateLimitConfig{Rate: 100, Burst: 1, Overflow: RateLimitQueue}, nil)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.True(t, l.Allow(context.Background()), "the events over the limit wait")
+	}
+	require.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+}
+
+func TestSampler(t *testing.T) {
+	require.Nil(t, newSampler(types.SamplingConfig{Rate: 1}), "every log is sent")
+
+	s := newSampler(types.SamplingConfig{Rate: 1, Every: 3})
+	var kept int
+	for i := 0; i < 9; i++ {
+		if s.Keep(types.KubearmorPayload{EventType: LogEventType}) {
+			kept++
+		}
+	}
+	require.Equal(t, 3, kept)
+	require.True(t, s.Keep(types.KubearmorPayload{EventType: AlertEventType}), "the alerts are not sampled")
+
+	s = newSampler(types.SamplingConfig{Rate: 0})
+	require.False(t, s.Keep(types.KubearmorPayload{EventType: LogEventType}))
+}
+
+func TestDispatchRateLimit(t *testing.T) {
+	stats := &types.Statistics{Sampled: new(expvar.Map).Init(), RateLimited: new(expvar.Map).Init()}
+	d := NewDispatcher(&types.Configuration{
+		RateLimit: types.RateLimitConfig{Outputs: map[string]types.RateLimitConfig{"slack": {Rate: 0.001, Burst: 1, Overflow: RateLimitDrop}}},
+		Sampling:  types.SamplingConfig{Rate: 1, Outputs: map[string]types.SamplingConfig{"slack": {Rate: 1, Every: 2}}},
+	}, stats, &types.PromStatistics{})
+	require.Nil(t, d.newRateLimiter(context.Background(), &testOutput{name: "other"}, outputRoute{}), "the rate limiting is disabled")
+
+	o := &testOutput{name: "Slack", received: make(chan types.KubearmorPayload, 4)}
+	r := outputRoute{sampler: newSampler(samplingPolicy(d.Config.Sampling, o.Name()))}
+	r.limiter = d.newRateLimiter(context.Background(), o, r)
+	require.NotNil(t, r.limiter)
+	for i := 0; i < 4; i++ {
+		d.forward(context.Background(), o, r, types.KubearmorPayload{EventType: LogEventType})
+	}
+	require.Len(t, o.received, 1)
+	require.Equal(t, "2", stats.Sampled.Get("Slack").String())
+	require.Equal(t, "1", stats.RateLimited.Get("Slack").String())
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+	l := NewRateLimiter(types.RateLimitConfig{Rate: 0.001, Burst: 1, Overflow: RateLimitQueue}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.True(t, l.Allow(ctx), "the events under the limit are sent")
+	require.False(t, l.Allow(ctx), "the events waiting for the rate are dropped")
+}
+
+func TestRateLimitSummaryPayloads(t *testing.T) {
+	config := &types.Configuration{}
+	summary := func() types.KubearmorPayload { return rateLimitSummary(3, time.Now()) }
+
+	require.NotPanics(t, func() { newLokiPayload(summary(), config) })
+	require.NotPanics(t, func() { createPagerdutyEvent(summary(), config.Pagerduty) })
+	require.NotPanics(t, func() { newOpsgeniePayload(summary(), config) })
+	require.NotPanics(t, func() { newInfluxdbPayload(summary(), config) })
+	require.NotPanics(t, func() { newGrafanaPayload(summary(), config) })
+	require.NotPanics(t, func() { newGrafanaOnCallPayload(summary(), config) })
+	require.NotPanics(t, func() { newGooglechatPayload(summary(), config) })
+	require.NotPanics(t, func() { NewOCSFSecurityFinding(summary()) })
+}
+
+func TestDispatchRateLimitSummary(t *testing.T) {
+	stats := &types.Statistics{Filtered: new(expvar.Map).Init(), RateLimited: new(expvar.Map).Init()}
+	d := NewDispatcher(&types.Configuration{
+		RateLimit: types.RateLimitConfig{Rate: 0.001, Burst: 1, Overflow: RateLimitSummary, SummaryInterval: 60},
+		Sampling:  types.SamplingConfig{Rate: 1},
+		SeverityMapping: types.SeverityMappingConfig{
+			SeveritiesMap:   map[string]types.PriorityType{"10": types.Emergency},
+			DefaultPriority: "warning",
+		},
+	}, stats, &types.PromStatistics{})
+
+	o := &testOutput{name: "test", minimumPriority: types.Critical, received: make(chan types.KubearmorPayload, 4)}
+	r := outputRoute{}
+	r.limiter = d.newRateLimiter(context.Background(), o, r)
+	alert := types.KubearmorPayload{EventType: AlertEventType, OutputFields: map[string]interface{}{"Severity": "10"}}
+	for i := 0; i < 3; i++ {
+		d.forward(context.Background(), o, r, alert)
+	}
+	r.limiter.Close()
+
+	require.Len(t, o.received, 1)
+	require.Equal(t, "2", stats.RateLimited.Get("test").String())
+	require.Equal(t, "1", stats.Filtered.Get("test").String(), "the summary is under the minimum priority of the output")
+}
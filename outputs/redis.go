@@ -3,17 +3,26 @@ package outputs
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"strings"
-	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
-	"github.com/google/uuid"
 	"github.com/kubearmor/sidekick/types"
 	"github.com/redis/go-redis/v9"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Redis",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Redis.Address != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Redis.MinimumPriority },
+		New:             NewRedisClient,
+		Send:            (*Client).RedisPost,
+		Close:           (*Client).CloseRedis,
+	})
+}
+
 func (c *Client) ReportError(err error) {
 	go c.CountMetric(Outputs, 1, []string{"output:redis", "status:error"})
 	c.Stats.Redis.Add(Error, 1)
@@ -48,18 +57,20 @@ func NewRedisClient(config *types.Configuration, stats *types.Statistics, promSt
 	}, nil
 }
 
-func (c *Client) RedisPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) RedisPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Redis.Add(Total, 1)
 	redisPayload, _ := json.Marshal(kubearmorpayload)
 	if strings.ToLower(c.Config.Redis.StorageType) == "hashmap" {
 		_, err := c.RedisClient.HSet(context.Background(), c.Config.Redis.Key, kubearmorpayload.OutputFields["UID"], redisPayload).Result()
 		if err != nil {
 			c.ReportError(err)
+			return err
 		}
 	} else {
 		_, err := c.RedisClient.RPush(context.Background(), c.Config.Redis.Key, redisPayload).Result()
 		if err != nil {
 			c.ReportError(err)
+			return err
 		}
 	}
 
@@ -67,47 +78,11 @@ func (c *Client) RedisPost(kubearmorpayload types.KubearmorPayload) {
 	go c.CountMetric(Outputs, 1, []string{"output:redis", "status:ok"})
 	c.Stats.Redis.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "redis", "status": OK}).Inc()
-}
 
-func (c *Client) WatchRedisPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	fmt.Println("discord running")
-	for AlertRunning {
-		select {
-		case resp := <-conn:
-			fmt.Println("response \n", resp)
-			c.RedisPost(resp)
-		}
-	}
-	fmt.Println("discord stopped")
 	return nil
 }
 
-func (c *Client) WatchRedisPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.RedisPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
-
-	return nil
+// CloseRedis closes the connections to the Redis server
+func (c *Client) CloseRedis(ctx context.Context) error {
+	return c.RedisClient.Close()
 }
@@ -0,0 +1,213 @@
+package outputs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/DataDog/datadog-go/statsd"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// Event types carried in KubearmorPayload.EventType
+const (
+	AlertEventType string = "Alert"
+	LogEventType   string = "Log"
+)
+
+// Output is a destination fed by the dispatcher.
+type Output interface {
+	// Name is the output name, as listed in EnabledOutputs.
+	Name() string
+	// EventTypes lists the event types the output subscribes to.
+	EventTypes() []string
+	// MinimumPriority is the lowest priority of the events the output receives.
+	MinimumPriority() types.PriorityType
+	// Send delivers a single event to the destination, it returns an error if
+	// the event could not be delivered.
+	Send(kubearmorpayload types.KubearmorPayload) error
+	// Close flushes and releases the client of the output, before the
+	// deadline of the context.
+	Close(ctx context.Context) error
+}
+
+// BatchOutput is an output able to send several events in a single request.
+type BatchOutput interface {
+	Output
+	// SendBatch delivers the events at once, it returns a *BatchError if the
+	// destination rejected only some of them.
+	SendBatch(events []types.KubearmorPayload) error
+}
+
+// Constructor returns the Client an output sends its events through.
+type Constructor func(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error)
+
+// Registration declares an output to the registry.
+type Registration struct {
+	Name       string
+	EventTypes []string
+	// Enabled reports whether the output is configured.
+	Enabled func(config *types.Configuration) bool
+	// MinimumPriority returns the configured minimum priority of the output,
+	// nil if the output receives every event.
+	MinimumPriority func(config *types.Configuration) string
+	New             Constructor
+	Send            func(c *Client, kubearmorpayload types.KubearmorPayload) error
+	// SendBatch sends several events with the bulk API of the destination, nil
+	// if it has none.
+	SendBatch func(c *Client, events []types.KubearmorPayload) error
+	// Close releases the client on shutdown, nil if there is nothing to release.
+	Close func(c *Client, ctx context.Context) error
+}
+
+var (
+	registryLock  sync.Mutex
+	registrations []Registration
+)
+
+// RegisterOutput adds an output to the registry. It is meant to be called
+// from the init function of the file implementing the output.
+func RegisterOutput(r Registration) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	for _, i := range registrations {
+		if i.Name == r.Name {
+			log.Fatalf("[ERROR] : Output %v is registered twice\n", r.Name)
+		}
+	}
+	registrations = append(registrations, r)
+}
+
+// Registrations returns the registered outputs.
+func Registrations() []Registration {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	r := make([]Registration, len(registrations))
+	copy(r, registrations)
+	return r
+}
+
+type registeredOutput struct {
+	registration    Registration
+	name            string
+	client          *Client
+	minimumPriority types.PriorityType
+	breaker         *Breaker
+}
+
+func (o *registeredOutput) Name() string {
+	return o.name
+}
+
+func (o *registeredOutput) EventTypes() []string {
+	return o.registration.EventTypes
+}
+
+func (o *registeredOutput) MinimumPriority() types.PriorityType {
+	return o.minimumPriority
+}
+
+// Send sends the event through the circuit breaker of the output, if it has
+// one, ErrCircuitOpen is returned while the breaker is open.
+func (o *registeredOutput) Send(kubearmorpayload types.KubearmorPayload) error {
+	return o.guard(func() error { return o.registration.Send(o.client, kubearmorpayload) })
+}
+
+// guard calls send through the circuit breaker, if the output has one. A
+// batch partly rejected or an event rejected for good counts as a success,
+// the destination is up.
+func (o *registeredOutput) guard(send func() error) error {
+	if o.breaker == nil {
+		return send()
+	}
+	if !o.breaker.Allow() {
+		return ErrCircuitOpen
+	}
+	err := send()
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) || Permanent(err) {
+		o.breaker.Record(nil)
+	} else {
+		o.breaker.Record(err)
+	}
+	return err
+}
+
+func (o *registeredOutput) Close(ctx context.Context) error {
+	if o.registration.Close == nil {
+		return nil
+	}
+	return o.registration.Close(o.client, ctx)
+}
+
+// batchOutput is a registered output with a SendBatch function
+type batchOutput struct {
+	*registeredOutput
+}
+
+// SendBatch sends the events through the circuit breaker of the output, if it
+// has one, ErrCircuitOpen is returned while the breaker is open.
+func (o batchOutput) SendBatch(events []types.KubearmorPayload) error {
+	return o.guard(func() error { return o.registration.SendBatch(o.client, events) })
+}
+
+// NewOutputs creates a client for every configured output, of the default
+// instance and of the named ones, and adds it to EnabledOutputs. Outputs
+// failing to initialize are logged and skipped.
+func NewOutputs(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) []Output {
+	var o []Output
+	for _, r := range Registrations() {
+		if !r.Enabled(config) {
+			continue
+		}
+		if output := newOutput(r, DefaultInstance, config, config, stats, promStats, statsdClient, dogstatsdClient); output != nil {
+			o = append(o, output)
+		}
+	}
+	for _, instance := range config.Instances {
+		for _, r := range Registrations() {
+			if !instanceEnables(r, instance) {
+				continue
+			}
+			if output := newOutput(r, instance.Name, instance.Config, config, stats, promStats, statsdClient, dogstatsdClient); output != nil {
+				o = append(o, output)
+			}
+		}
+	}
+	return o
+}
+
+// newOutput creates the output of an instance from the configuration of the
+// instance, its retry, HTTP client and circuit breaker policies are read from
+// the settings of the default configuration. It returns nil if the client
+// failed to initialize.
+func newOutput(r Registration, instance string, config, defaults *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) Output {
+	name := r.Name
+	if instance != DefaultInstance {
+		name = InstanceName(r.Name, instance)
+		stats = instanceStats(stats, r, name)
+	}
+	c, err := r.New(config, stats, instancePromStats(promStats, instance), statsdClient, dogstatsdClient)
+	if err != nil {
+		log.Printf("[ERROR] : %v - %v\n", name, err)
+		return nil
+	}
+	if instance != DefaultInstance {
+		c.Instance = instance
+	}
+	c.Retry = retryPolicy(defaults.Retry, name)
+	c.HTTPClient = httpClientPolicy(defaults.HTTPClient, name)
+	output := &registeredOutput{registration: r, name: name, client: c, breaker: newBreaker(breakerPolicy(defaults.CircuitBreaker, name), name)}
+	if r.MinimumPriority != nil {
+		output.minimumPriority = types.Priority(r.MinimumPriority(config))
+	}
+	EnabledOutputs = append(EnabledOutputs, name)
+	if r.SendBatch != nil {
+		return batchOutput{output}
+	}
+	return output
+}
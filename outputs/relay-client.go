@@ -1,27 +1,23 @@
 package outputs
 
 import (
+	"context"
 	"fmt"
 	"sync"
-	"time"
 
 	pb "github.com/kubearmor/KubeArmor/protobuf"
 	"github.com/kubearmor/sidekick/types"
 )
 
-// Running bool
-var AlertRunning bool
-var LogRunning bool
+// LogBuffer store incoming data from log stream in buffer
+var LogBuffer *Buffer[*pb.Log]
 
-// LogBufferChannel store incoming data from log stream in buffer
-var LogBufferChannel chan *pb.Log
-
-// AlertBufferChannel store incoming data from msg stream in buffer
-var AlertBufferChannel chan *pb.Alert
+// AlertBuffer store incoming data from msg stream in buffer
+var AlertBuffer *Buffer[*pb.Alert]
 
 // AlertStruct Structure
 type AlertStruct struct {
-	Broadcast chan types.KubearmorPayload
+	Broadcast *Buffer[types.KubearmorPayload]
 }
 
 // AlertStructs Map
@@ -33,7 +29,7 @@ var AlertLock *sync.RWMutex
 // LogStruct Structure
 type LogStruct struct {
 	Filter    string
-	Broadcast chan types.KubearmorPayload
+	Broadcast *Buffer[types.KubearmorPayload]
 }
 
 var LogLock *sync.RWMutex
@@ -41,14 +37,15 @@ var LogLock *sync.RWMutex
 // LogStructs Map
 var LogStructs map[string]LogStruct
 
-func Initvariable(logrunning bool) {
-
-	AlertRunning = true
-	LogRunning = logrunning
+func Initvariable(buffers types.BuffersConfig) {
 
 	//initial buffer struct
-	LogBufferChannel = make(chan *pb.Log, 10000)
-	AlertBufferChannel = make(chan *pb.Alert, 1000)
+	if LogBuffer != nil {
+		LogBuffer.Close()
+		AlertBuffer.Close()
+	}
+	LogBuffer = NewBuffer[*pb.Log](buffers.Logs, DefaultLogBufferSize, RelayLogsStage, "")
+	AlertBuffer = NewBuffer[*pb.Alert](buffers.Alerts, DefaultAlertBufferSize, RelayAlertsStage, "")
 
 	// initialize alert structs
 	AlertStructs = make(map[string]AlertStruct)
@@ -63,181 +60,252 @@ func Initvariable(logrunning bool) {
 // DestroyClient Function
 func (c *Client) DestroyClient() error {
 	c.Running = false
-	LogRunning = false
-	AlertRunning = false
 
 	err := c.Conn.Close()
 
 	return err
 }
 
+// WatchAlerts reads the alert stream into AlertBuffer, it returns the
+// error ending the stream.
 func (c *Client) WatchAlerts() error {
 
 	defer c.WgServer.Done()
 
-	var err error
-
 	for {
-		var res *pb.Alert
-
-		if res, err = c.AlertStream.Recv(); err != nil {
-			break
+		res, err := c.AlertStream.Recv()
+		if err != nil {
+			return err
 		}
+		res.ClusterName = c.clusterName(res.ClusterName)
 
-		select {
-		case AlertBufferChannel <- res:
-		default:
+		if AlertBuffer.Push(res) {
+			c.countRelayEvent(Accepted)
+		} else {
+			c.countRelayEvent(Rejected)
 		}
 
 	}
-
-	return nil
 }
 
-// AddAlertFromBuffChan Adds ALert from AlertBufferChannel into AlertStructs
-func (c *Client) AddAlertFromBuffChan() {
-	for AlertRunning {
+// AddAlertFromBuffChan Adds ALert from AlertBuffer into AlertStructs
+// until the context is canceled, then flushes the buffer to the outputs
+func (c *Client) AddAlertFromBuffChan(ctx context.Context) {
+	for {
 		select {
-		case res := <-AlertBufferChannel:
-
-			alert := types.KubearmorPayload{}
-
-			alert.Timestamp = res.GetTimestamp()
-			alert.UpdatedTime = res.GetUpdatedTime()
-			alert.ClusterName = res.GetClusterName()
-			alert.Hostname = res.GetHostName()
-			alert.EventType = "Alert"
-			alert.OutputFields = make(map[string]interface{})
-
-			alert.OutputFields["OwnerRef"] = res.GetOwner().GetRef()
-			alert.OutputFields["OwnerName"] = res.GetOwner().GetName()
-			alert.OutputFields["OwnerNamespace"] = res.GetOwner().GetNamespace()
-
-			alert.OutputFields["Timestamp"] = fmt.Sprint(res.GetTimestamp())
-			alert.OutputFields["UpdatedTime"] = res.GetUpdatedTime()
-			alert.OutputFields["ClusterName"] = res.GetClusterName()
-			alert.OutputFields["Hostname"] = res.GetHostName()
-			alert.OutputFields["NamespaceName"] = res.GetNamespaceName()
-			alert.OutputFields["PodName"] = res.GetPodName()
-			alert.OutputFields["Labels"] = res.GetLabels()
-			alert.OutputFields["ContainerID"] = res.GetContainerID()
-			alert.OutputFields["ContainerName"] = res.GetContainerName()
-			alert.OutputFields["ContainerImage"] = res.GetContainerImage()
-			alert.OutputFields["HostPPID"] = res.GetHostPPID()
-			alert.OutputFields["HostPID"] = res.GetHostPID()
-			alert.OutputFields["PPID"] = res.GetPPID()
-			alert.OutputFields["PID"] = res.GetPID()
-			alert.OutputFields["UID"] = res.GetUID()
-			alert.OutputFields["ParentProcessName"] = res.GetParentProcessName()
-			alert.OutputFields["ProcessName"] = res.GetProcessName()
-			alert.OutputFields["Source"] = res.GetSource()
-			alert.OutputFields["Operation"] = res.GetOperation()
-			alert.OutputFields["Resource"] = res.GetResource()
-			alert.OutputFields["Data"] = res.GetData()
-			alert.OutputFields["Result"] = res.GetResult()
-			alert.OutputFields["PolicyName"] = res.GetPolicyName()
-			alert.OutputFields["Severity"] = res.GetSeverity()
-			alert.OutputFields["Tags"] = res.GetTags()
-			alert.OutputFields["ATags"] = res.GetATags()
-			alert.OutputFields["Message"] = res.GetMessage()
-			alert.OutputFields["Enforcer"] = res.GetEnforcer()
-
-			AlertLock.RLock()
-			for uid := range AlertStructs {
+		case <-ctx.Done():
+			for {
 				select {
-				case AlertStructs[uid].Broadcast <- (alert):
+				case res := <-AlertBuffer.C:
+					BroadcastAlertWait(NewAlertPayload(res))
 				default:
+					return
 				}
 			}
-			AlertLock.RUnlock()
-
-		default:
-			time.Sleep(time.Millisecond * 10)
+		case res := <-AlertBuffer.C:
+			BroadcastAlert(NewAlertPayload(res))
 		}
+	}
+}
 
+// NewAlertPayload converts an alert received from the relay
+func NewAlertPayload(res *pb.Alert) types.KubearmorPayload {
+	alert := types.KubearmorPayload{}
+
+	alert.Timestamp = res.GetTimestamp()
+	alert.UpdatedTime = res.GetUpdatedTime()
+	alert.ClusterName = res.GetClusterName()
+	alert.Hostname = res.GetHostName()
+	alert.EventType = AlertEventType
+	alert.OutputFields = make(map[string]interface{})
+
+	alert.OutputFields["OwnerRef"] = res.GetOwner().GetRef()
+	alert.OutputFields["OwnerName"] = res.GetOwner().GetName()
+	alert.OutputFields["OwnerNamespace"] = res.GetOwner().GetNamespace()
+
+	alert.OutputFields["Timestamp"] = fmt.Sprint(res.GetTimestamp())
+	alert.OutputFields["UpdatedTime"] = res.GetUpdatedTime()
+	alert.OutputFields["ClusterName"] = res.GetClusterName()
+	alert.OutputFields["Hostname"] = res.GetHostName()
+	alert.OutputFields["NamespaceName"] = res.GetNamespaceName()
+	alert.OutputFields["PodName"] = res.GetPodName()
+	alert.OutputFields["Labels"] = res.GetLabels()
+	alert.OutputFields["ContainerID"] = res.GetContainerID()
+	alert.OutputFields["ContainerName"] = res.GetContainerName()
+	alert.OutputFields["ContainerImage"] = res.GetContainerImage()
+	alert.OutputFields["HostPPID"] = res.GetHostPPID()
+	alert.OutputFields["HostPID"] = res.GetHostPID()
+	alert.OutputFields["PPID"] = res.GetPPID()
+	alert.OutputFields["PID"] = res.GetPID()
+	alert.OutputFields["UID"] = res.GetUID()
+	alert.OutputFields["ParentProcessName"] = res.GetParentProcessName()
+	alert.OutputFields["ProcessName"] = res.GetProcessName()
+	alert.OutputFields["Source"] = res.GetSource()
+	alert.OutputFields["Operation"] = res.GetOperation()
+	alert.OutputFields["Resource"] = res.GetResource()
+	alert.OutputFields["Data"] = res.GetData()
+	alert.OutputFields["Result"] = res.GetResult()
+	alert.OutputFields["PolicyName"] = res.GetPolicyName()
+	alert.OutputFields["Severity"] = res.GetSeverity()
+	alert.OutputFields["Tags"] = res.GetTags()
+	alert.OutputFields["ATags"] = res.GetATags()
+	alert.OutputFields["Message"] = res.GetMessage()
+	alert.OutputFields["Enforcer"] = res.GetEnforcer()
+
+	return alert
+}
+
+// BroadcastAlert sends the alert to the outputs watching alerts, according to
+// the overflow policy of their queues.
+func BroadcastAlert(alert types.KubearmorPayload) {
+	AlertLock.RLock()
+	defer AlertLock.RUnlock()
+
+	for uid := range AlertStructs {
+		AlertStructs[uid].Broadcast.Push(alert)
 	}
 }
 
-// WatchLogs Function
-func (c *Client) WatchLogs() error {
+// BroadcastAlertWait sends the alert to the outputs watching alerts, waiting
+// for room in their queues.
+func BroadcastAlertWait(alert types.KubearmorPayload) {
+	AlertLock.RLock()
+	defer AlertLock.RUnlock()
 
-	defer c.WgServer.Done()
+	for uid := range AlertStructs {
+		AlertStructs[uid].Broadcast.C <- alert
+	}
+}
 
-	var err error
+// WatchLogs reads the log stream into LogBuffer, it returns the
+// error ending the stream.
+func (c *Client) WatchLogs() error {
 
-	for LogRunning {
-		var res *pb.Log
+	defer c.WgServer.Done()
 
-		if res, err = c.LogStream.Recv(); err != nil {
-			fmt.Println("Error streamsing logs err: ", err)
-			break
+	for {
+		res, err := c.LogStream.Recv()
+		if err != nil {
+			return err
 		}
+		res.ClusterName = c.clusterName(res.ClusterName)
 
-		select {
-		case LogBufferChannel <- res:
-		default:
+		if LogBuffer.Push(res) {
+			c.countRelayEvent(Accepted)
+		} else {
 			//not able to add it to Log buffer
+			c.countRelayEvent(Rejected)
 		}
 	}
-
-	return nil
 }
 
-// AddLogFromBuffChan Adds Log from LogBufferChannel into LogStructs
-func (c *Client) AddLogFromBuffChan() {
-
-	for LogRunning {
+// AddLogFromBuffChan Adds Log from LogBuffer into LogStructs until the
+// context is canceled, then flushes the buffer to the outputs
+func (c *Client) AddLogFromBuffChan(ctx context.Context) {
+	for {
 		select {
-		case res := <-LogBufferChannel:
-			log := types.KubearmorPayload{}
-			log.Timestamp = res.GetTimestamp()
-			log.UpdatedTime = res.GetUpdatedTime()
-			log.ClusterName = res.GetClusterName()
-			log.Hostname = res.GetHostName()
-			log.EventType = "Log"
-
-			// Create new Podowner struct
-			log.OutputFields = make(map[string]interface{})
-			log.OutputFields["OwnerRef"] = res.GetOwner().GetRef()
-			log.OutputFields["OwnerName"] = res.GetOwner().GetName()
-			log.OutputFields["OwnerNamespace"] = res.GetOwner().GetNamespace()
-			log.OutputFields["Timestamp"] = res.GetTimestamp()
-			log.OutputFields["UpdatedTime"] = res.GetUpdatedTime()
-			log.OutputFields["ClusterName"] = res.GetClusterName()
-			log.OutputFields["Hostname"] = res.GetHostName()
-			log.OutputFields["NamespaceName"] = res.GetNamespaceName()
-			log.OutputFields["PodName"] = res.GetPodName()
-			log.OutputFields["Labels"] = res.GetLabels()
-			log.OutputFields["ContainerID"] = res.GetContainerID()
-			log.OutputFields["ContainerName"] = res.GetContainerName()
-			log.OutputFields["ContainerImage"] = res.GetContainerImage()
-			log.OutputFields["HostPPID"] = res.GetHostPPID()
-			log.OutputFields["HostPID"] = res.GetHostPID()
-			log.OutputFields["PPID"] = res.GetPPID()
-			log.OutputFields["PID"] = res.GetPID()
-			log.OutputFields["UID"] = res.GetUID()
-			log.OutputFields["ParentProcessName"] = res.GetParentProcessName()
-			log.OutputFields["ProcessName"] = res.GetProcessName()
-			log.OutputFields["Source"] = res.GetSource()
-			log.OutputFields["Operation"] = res.GetOperation()
-			log.OutputFields["Resource"] = res.GetResource()
-			log.OutputFields["Data"] = res.GetData()
-			log.OutputFields["Result"] = res.GetResult()
-
-			for uid := range LogStructs {
+		case <-ctx.Done():
+			for {
 				select {
-				case LogStructs[uid].Broadcast <- (log):
+				case res := <-LogBuffer.C:
+					BroadcastLogWait(NewLogPayload(res))
 				default:
+					return
 				}
 			}
-		default:
-			time.Sleep(time.Millisecond * 10)
+		case res := <-LogBuffer.C:
+			BroadcastLog(NewLogPayload(res))
 		}
+	}
+}
 
+// NewLogPayload converts a log received from the relay
+func NewLogPayload(res *pb.Log) types.KubearmorPayload {
+	log := types.KubearmorPayload{}
+	log.Timestamp = res.GetTimestamp()
+	log.UpdatedTime = res.GetUpdatedTime()
+	log.ClusterName = res.GetClusterName()
+	log.Hostname = res.GetHostName()
+	log.EventType = LogEventType
+
+	// Create new Podowner struct
+	log.OutputFields = make(map[string]interface{})
+	log.OutputFields["OwnerRef"] = res.GetOwner().GetRef()
+	log.OutputFields["OwnerName"] = res.GetOwner().GetName()
+	log.OutputFields["OwnerNamespace"] = res.GetOwner().GetNamespace()
+	log.OutputFields["Timestamp"] = res.GetTimestamp()
+	log.OutputFields["UpdatedTime"] = res.GetUpdatedTime()
+	log.OutputFields["ClusterName"] = res.GetClusterName()
+	log.OutputFields["Hostname"] = res.GetHostName()
+	log.OutputFields["NamespaceName"] = res.GetNamespaceName()
+	log.OutputFields["PodName"] = res.GetPodName()
+	log.OutputFields["Labels"] = res.GetLabels()
+	log.OutputFields["ContainerID"] = res.GetContainerID()
+	log.OutputFields["ContainerName"] = res.GetContainerName()
+	log.OutputFields["ContainerImage"] = res.GetContainerImage()
+	log.OutputFields["HostPPID"] = res.GetHostPPID()
+	log.OutputFields["HostPID"] = res.GetHostPID()
+	log.OutputFields["PPID"] = res.GetPPID()
+	log.OutputFields["PID"] = res.GetPID()
+	log.OutputFields["UID"] = res.GetUID()
+	log.OutputFields["ParentProcessName"] = res.GetParentProcessName()
+	log.OutputFields["ProcessName"] = res.GetProcessName()
+	log.OutputFields["Source"] = res.GetSource()
+	log.OutputFields["Operation"] = res.GetOperation()
+	log.OutputFields["Resource"] = res.GetResource()
+	log.OutputFields["Data"] = res.GetData()
+	log.OutputFields["Result"] = res.GetResult()
+
+	return log
+}
+
+// BroadcastLog sends the log to the outputs watching logs, according to the
+// overflow policy of their queues.
+func BroadcastLog(log types.KubearmorPayload) {
+	LogLock.RLock()
+	defer LogLock.RUnlock()
+
+	for uid := range LogStructs {
+		LogStructs[uid].Broadcast.Push(log)
+	}
+}
+
+// BroadcastLogWait sends the log to the outputs watching logs, waiting for
+// room in their queues.
+func BroadcastLogWait(log types.KubearmorPayload) {
+	LogLock.RLock()
+	defer LogLock.RUnlock()
+
+	for uid := range LogStructs {
+		LogStructs[uid].Broadcast.C <- log
+	}
+}
+
+// clusterName returns the cluster name of an event received from the relay:
+// the ClusterName of the client if set, the one of the event otherwise, or the
+// name of the relay when the event has none.
+func (c *Client) clusterName(clusterName string) string {
+	if c.ClusterName != "" {
+		return c.ClusterName
+	}
+	if clusterName == "" {
+		return c.RelayName
+	}
+	return clusterName
+}
+
+// countRelayEvent counts an event received from the relay
+func (c *Client) countRelayEvent(status string) {
+	if c.RelayStats != nil {
+		c.RelayStats.Add(Total, 1)
+		c.RelayStats.Add(status, 1)
+	}
+	if c.PromStats != nil && c.PromStats.Inputs != nil {
+		c.PromStats.Inputs.With(map[string]string{"source": "relay:" + c.RelayName, "status": status}).Inc()
 	}
 }
 
-func addAlertStruct(uid string, conn chan types.KubearmorPayload) {
+func addAlertStruct(uid string, conn *Buffer[types.KubearmorPayload]) {
 	AlertLock.Lock()
 	defer AlertLock.Unlock()
 
@@ -257,7 +325,7 @@ func removeAlertStruct(uid string) {
 
 }
 
-func addLogStruct(uid string, conn chan types.KubearmorPayload) {
+func addLogStruct(uid string, conn *Buffer[types.KubearmorPayload]) {
 	LogLock.Lock()
 	defer LogLock.Unlock()
 
@@ -0,0 +1,29 @@
+package outputs
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterName(t *testing.T) {
+	c := &Client{RelayName: "relay1"}
+	require.Equal(t, "cluster1", c.clusterName("cluster1"))
+	require.Equal(t, "relay1", c.clusterName(""))
+
+	c.ClusterName = "cluster2"
+	require.Equal(t, "cluster2", c.clusterName("cluster1"))
+	require.Equal(t, "cluster2", c.clusterName(""))
+}
+
+func TestCountRelayEvent(t *testing.T) {
+	c := &Client{RelayName: "relay1", RelayStats: new(expvar.Map).Init()}
+	c.countRelayEvent(Accepted)
+	c.countRelayEvent(Accepted)
+	c.countRelayEvent(Rejected)
+
+	require.Equal(t, "3", c.RelayStats.Get(Total).String())
+	require.Equal(t, "2", c.RelayStats.Get(Accepted).String())
+	require.Equal(t, "1", c.RelayStats.Get(Rejected).String())
+}
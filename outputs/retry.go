@@ -0,0 +1,150 @@
+package outputs
+
+import (
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// Retry is the status counting the retried requests
+const Retry string = "retry"
+
+// retriesError is the error of a request which failed after several attempts
+type retriesError struct {
+	err      error
+	attempts int
+}
+
+func (e *retriesError) Error() string { return e.err.Error() }
+func (e *retriesError) Unwrap() error { return e.err }
+
+// permanentError is the error of a request the destination rejected with a
+// status not worth another attempt, like a malformed payload.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent reports whether the event which failed with the error was
+// rejected by the destination and would fail again if sent again.
+func Permanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// retryableError is the error of a request sent without retries, or of a
+// whole batch call to a client library, which is worth another attempt, the
+// caller retries it with its own policy.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// Retryable reports whether the events which failed with the error, sent
+// without the retries of the client, are worth another attempt.
+func Retryable(err error) bool {
+	var r *retryableError
+	return errors.As(err, &r)
+}
+
+// Attempts returns the number of attempts made to send the event which failed
+// with the error, 1 if it was not retried and 0 if it was short-circuited.
+func Attempts(err error) int {
+	if errors.Is(err, ErrCircuitOpen) {
+		return 0
+	}
+	var r *retriesError
+	if errors.As(err, &r) {
+		return r.attempts
+	}
+	return 1
+}
+
+// retryPolicy returns the retry policy of the output, the default one if the
+// output has none of its own.
+func retryPolicy(retry types.RetryConfig, name string) types.RetryConfig {
+	if r, ok := retry.Outputs[strings.ToLower(name)]; ok {
+		return r
+	}
+	retry.Outputs = nil
+	return retry
+}
+
+// retryable reports whether a request which failed with the status code, 0
+// if there was no response, is worth another attempt.
+func (c *Client) retryable(status int, err error) bool {
+	if status == 0 {
+		var urlErr *url.Error
+		return c.Retry.NetworkErrors && errors.As(err, &urlErr)
+	}
+	for _, code := range c.Retry.RetryableCodesList {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay returns the delay before the given retry attempt, starting at 1,
+// the delay asked by the server with Retry-After if any, within MaxBackoff.
+func (c *Client) retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	maxBackoff := time.Duration(c.Retry.MaxBackoff) * time.Millisecond
+	if retryAfter > 0 {
+		if retryAfter > maxBackoff {
+			return maxBackoff
+		}
+		return retryAfter
+	}
+	return Backoff{
+		Base:   time.Duration(c.Retry.BaseBackoff) * time.Millisecond,
+		Max:    maxBackoff,
+		Jitter: c.Retry.Jitter,
+	}.Delay(attempt)
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, in seconds or as
+// an HTTP date, 0 if there is none.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(header); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// countRetry counts a retried request in the metrics of the output
+func (c *Client) countRetry() {
+	output := strings.ToLower(c.OutputType)
+	name := output
+	if c.Instance != "" {
+		name = InstanceName(output, c.Instance)
+	}
+	if m, ok := expvar.Get("outputs." + name).(*expvar.Map); ok {
+		m.Add(Retry, 1)
+	}
+	if c.PromStats != nil && c.PromStats.Outputs != nil {
+		c.PromStats.Outputs.With(map[string]string{"destination": output, "status": Retry}).Inc()
+	}
+	go c.CountMetric(Outputs, 1, []string{"output:" + output, "status:" + Retry})
+}
+
+// logRetry logs a failed attempt about to be retried
+func (c *Client) logRetry(attempt int, delay time.Duration, err error) {
+	log.Printf("[WARN]  : %v - %v, retrying in %v (attempt %v/%v)\n", c.OutputType, err, delay, attempt, c.Retry.MaxAttempts)
+}
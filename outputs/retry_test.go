@@ -0,0 +1,104 @@
+package outputs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestPostRetry(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.Equal(t, "\"payload\"\n", string(body))
+		switch r.URL.EscapedPath() {
+		case "/flaky":
+			if requests.Add(1) < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/400":
+			requests.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		case "/500":
+			requests.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	retry := types.RetryConfig{MaxAttempts: 3, BaseBackoff: 1, MaxBackoff: 10, RetryableCodesList: []int{500, 503}}
+	post := func(path string) error {
+		nc, err := NewClient("", ts.URL+path, false, true, &types.Configuration{}, &types.Statistics{}, &types.PromStatistics{}, nil, nil)
+		require.Nil(t, err)
+		nc.Retry = retry
+		requests.Store(0)
+		return nc.Post("payload")
+	}
+
+	require.Nil(t, post("/flaky"))
+	require.EqualValues(t, 3, requests.Load())
+
+	err := post("/400")
+	require.ErrorIs(t, err, ErrHeaderMissing)
+	require.True(t, Permanent(err))
+	require.EqualValues(t, 1, requests.Load())
+
+	err = post("/500")
+	require.ErrorIs(t, err, ErrInternalServer)
+	require.False(t, Permanent(err))
+	require.Equal(t, 3, Attempts(err))
+	require.EqualValues(t, 3, requests.Load())
+
+	retry.MaxAttempts = 1
+	require.Equal(t, ErrInternalServer, post("/500"))
+	require.EqualValues(t, 1, requests.Load())
+}
+
+func TestRetryable(t *testing.T) {
+	c := &Client{Retry: types.RetryConfig{RetryableCodesList: []int{429}}}
+	require.True(t, c.retryable(http.StatusTooManyRequests, ErrTooManyRequest))
+	require.False(t, c.retryable(http.StatusNotFound, ErrNotFound))
+
+	req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:1", nil)
+	require.Nil(t, err)
+	_, _, err = c.doRequest(http.DefaultClient, req)
+	require.NotNil(t, err)
+	require.False(t, c.retryable(0, err))
+	c.Retry.NetworkErrors = true
+	require.True(t, c.retryable(0, err))
+}
+
+func TestRetryDelay(t *testing.T) {
+	c := &Client{Retry: types.RetryConfig{BaseBackoff: 100, MaxBackoff: 1000}}
+	require.Equal(t, 100*time.Millisecond, c.retryDelay(1, 0))
+	require.Equal(t, 400*time.Millisecond, c.retryDelay(3, 0))
+	require.Equal(t, time.Second, c.retryDelay(10, 0))
+	require.Equal(t, 500*time.Millisecond, c.retryDelay(1, 500*time.Millisecond))
+	require.Equal(t, time.Second, c.retryDelay(1, time.Minute))
+
+	now := time.Now()
+	require.Zero(t, parseRetryAfter("", now))
+	require.Zero(t, parseRetryAfter("soon", now))
+	require.Equal(t, 2*time.Second, parseRetryAfter("2", now))
+	require.Equal(t, 3*time.Second, parseRetryAfter(now.Add(3*time.Second).UTC().Format(http.TimeFormat), now.Truncate(time.Second)))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	retry := types.RetryConfig{
+		MaxAttempts: 3,
+		Outputs:     map[string]types.RetryConfig{"slack": {MaxAttempts: 5}},
+	}
+	require.Equal(t, 5, retryPolicy(retry, "Slack").MaxAttempts)
+	require.Equal(t, 3, retryPolicy(retry, "Loki").MaxAttempts)
+	require.Nil(t, retryPolicy(retry, "Loki").Outputs)
+}
@@ -4,12 +4,27 @@ import (
 	"bytes"
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Rocketchat",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Rocketchat.WebhookURL != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Rocketchat.MinimumPriority },
+		New:             newRocketchatClient,
+		Send:            (*Client).RocketchatPost,
+	})
+}
+
+func newRocketchatClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Rocketchat", config.Rocketchat.WebhookURL, config.Rocketchat.MutualTLS, config.Rocketchat.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 func newRocketchatPayload(kubearmorpayload types.KubearmorPayload, config *types.Configuration) slackPayload {
 	var (
 		messageText string
@@ -25,7 +40,7 @@ func newRocketchatPayload(kubearmorpayload types.KubearmorPayload, config *types
 		field.Short = true
 		fields = append(fields, field)
 		field.Title = Source
-		field.Value = kubearmorpayload.OutputFields["PodName"].(string)
+		field.Value = outputField(kubearmorpayload, "PodName")
 		field.Short = true
 		fields = append(fields, field)
 
@@ -103,7 +118,7 @@ func newRocketchatPayload(kubearmorpayload types.KubearmorPayload, config *types
 }
 
 // RocketchatPost posts event to Rocketchat
-func (c *Client) RocketchatPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) RocketchatPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Rocketchat.Add(Total, 1)
 
 	err := c.Post(newRocketchatPayload(kubearmorpayload, c.Config))
@@ -112,57 +127,13 @@ func (c *Client) RocketchatPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Rocketchat.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "rocketchat", "status": Error}).Inc()
 		log.Printf("[ERROR] : RocketChat - %v\n", err.Error())
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:rocketchat", "status:ok"})
 	c.Stats.Rocketchat.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "rocketchat", "status": OK}).Inc()
-}
-
-func (c *Client) WatchRocketchatPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.RocketchatPost(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchRocketchatPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.RocketchatPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
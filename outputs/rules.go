@@ -0,0 +1,166 @@
+package outputs
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	celtypes "github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// ruleFields are the fields of the events declared as variables of the rules,
+// the other fields are read from the Detail map.
+var ruleFields = []string{
+	"ATags", "ClusterName", "ContainerID", "ContainerImage", "ContainerName", "Data", "Enforcer",
+	"HostPID", "HostPPID", "Hostname", "Labels", "Message", "NamespaceName", "Operation", "OwnerName",
+	"OwnerNamespace", "OwnerRef", "PID", "PPID", "ParentProcessName", "PodName", "PolicyName",
+	"ProcessName", "Resource", "Result", "Severity", "Source", "Tags", "Timestamp", "UID", "UpdatedTime",
+}
+
+// Rules are the compiled include and exclude rules of an output
+type Rules struct {
+	include cel.Program
+	exclude cel.Program
+}
+
+var rulesEnv = newRulesEnv()
+
+// newRulesEnv returns the CEL environment of the rules: the fields of the
+// events, the string extensions and the glob function.
+func newRulesEnv() *cel.Env {
+	opts := []cel.EnvOption{
+		cel.Variable("EventType", cel.StringType),
+		cel.Variable("Detail", cel.MapType(cel.StringType, cel.DynType)),
+		ext.Strings(),
+		cel.Function("glob",
+			cel.MemberOverload("string_glob_string", []*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(func(value, pattern ref.Val) ref.Val {
+					matched, err := path.Match(pattern.Value().(string), value.Value().(string))
+					if err != nil {
+						return celtypes.NewErr("glob: %v", err)
+					}
+					return celtypes.Bool(matched)
+				}))),
+	}
+	for _, field := range ruleFields {
+		opts = append(opts, cel.Variable(field, cel.DynType))
+	}
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}
+
+// rulesPolicy returns the rules of the output, the default ones if the output
+// has none of its own.
+func rulesPolicy(rules types.RulesConfig, name string) types.RulesConfig {
+	if r, ok := rules.Outputs[strings.ToLower(name)]; ok {
+		return r
+	}
+	rules.Outputs = nil
+	return rules
+}
+
+// CompileRules compiles the include and exclude rules, it returns nil if there
+// is none.
+func CompileRules(config types.RulesConfig) (*Rules, error) {
+	if config.Include == "" && config.Exclude == "" {
+		return nil, nil
+	}
+	var (
+		r   Rules
+		err error
+	)
+	if r.include, err = compileRule(config.Include); err != nil {
+		return nil, fmt.Errorf("bad include rule: %v", err)
+	}
+	if r.exclude, err = compileRule(config.Exclude); err != nil {
+		return nil, fmt.Errorf("bad exclude rule: %v", err)
+	}
+	return &r, nil
+}
+
+func compileRule(rule string) (cel.Program, error) {
+	if rule == "" {
+		return nil, nil
+	}
+	ast, issues := rulesEnv.Compile(rule)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, fmt.Errorf("%q returns a %v instead of a bool", rule, t)
+	}
+	return rulesEnv.Program(ast)
+}
+
+// Match reports whether the event matches the include rule and not the
+// exclude rule. An event is not included if its include rule fails, and not
+// excluded if its exclude rule fails, the error is returned.
+func (r *Rules) Match(kubearmorpayload types.KubearmorPayload) (bool, error) {
+	if r == nil {
+		return true, nil
+	}
+	vars := ruleVariables(kubearmorpayload)
+	if r.include != nil {
+		included, err := evalRule(r.include, vars)
+		if err != nil || !included {
+			return false, err
+		}
+	}
+	if r.exclude != nil {
+		excluded, err := evalRule(r.exclude, vars)
+		return !excluded, err
+	}
+	return true, nil
+}
+
+func evalRule(rule cel.Program, vars map[string]interface{}) (bool, error) {
+	out, _, err := rule.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("the rule returned a %v instead of a bool", out.Type())
+	}
+	return matched, nil
+}
+
+// ruleVariables returns the variables of the rules for the event, the fields
+// missing from its Detail map are the fields of the event of the same name or
+// empty strings.
+func ruleVariables(kubearmorpayload types.KubearmorPayload) map[string]interface{} {
+	detail := kubearmorpayload.OutputFields
+	if detail == nil {
+		detail = map[string]interface{}{}
+	}
+	vars := make(map[string]interface{}, len(ruleFields)+2)
+	vars["EventType"] = kubearmorpayload.EventType
+	vars["Detail"] = detail
+	for _, field := range ruleFields {
+		if v, ok := detail[field]; ok && v != nil {
+			vars[field] = v
+			continue
+		}
+		switch field {
+		case "ClusterName":
+			vars[field] = kubearmorpayload.ClusterName
+		case "Hostname":
+			vars[field] = kubearmorpayload.Hostname
+		case "Timestamp":
+			vars[field] = kubearmorpayload.Timestamp
+		case "UpdatedTime":
+			vars[field] = kubearmorpayload.UpdatedTime
+		default:
+			vars[field] = ""
+		}
+	}
+	return vars
+}
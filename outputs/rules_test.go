@@ -0,0 +1,97 @@
+package outputs
+
+import (
+	"context"
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestRules(t *testing.T) {
+	alert := types.KubearmorPayload{
+		EventType: AlertEventType,
+		Hostname:  "node",
+		OutputFields: map[string]interface{}{
+			"NamespaceName": "prod",
+			"PolicyName":    "ksp-block-shell",
+			"Operation":     "Process",
+			"Result":        "Permission denied",
+			"Tags":          "MITRE,T1059",
+			"HostPID":       int32(42),
+			"Custom":        "value",
+		},
+	}
+
+	for rule, matched := range map[string]bool{
+		`NamespaceName in ["default", "prod"]`: true,
+		`Result == "Permission denied"`:        true,
+		`PolicyName.glob("ksp-*")`:             true,
+		`PolicyName.glob("hsp-*")`:             false,
+		`Operation == "Network"`:               false,
+		`Tags.contains("MITRE")`:               true,
+		`"T1059" in Tags.split(",")`:           true,
+		`HostPID == 42 && Hostname == "node"`:  true,
+		`Detail["Custom"] == "value"`:          true,
+		`EventType == "Log"`:                   false,
+		`ContainerName == ""`:                  true,
+	} {
+		rules, err := CompileRules(types.RulesConfig{Include: rule})
+		require.Nil(t, err, rule)
+		m, err := rules.Match(alert)
+		require.Nil(t, err, rule)
+		require.Equal(t, matched, m, rule)
+	}
+
+	rules, err := CompileRules(types.RulesConfig{Include: `Operation == "Process"`, Exclude: `NamespaceName == "prod"`})
+	require.Nil(t, err)
+	m, err := rules.Match(alert)
+	require.Nil(t, err)
+	require.False(t, m, "the event is excluded")
+
+	rules, err = CompileRules(types.RulesConfig{})
+	require.Nil(t, err)
+	require.Nil(t, rules)
+	m, err = rules.Match(alert)
+	require.Nil(t, err)
+	require.True(t, m, "no rules match every event")
+}
+
+func TestRulesErrors(t *testing.T) {
+	_, err := CompileRules(types.RulesConfig{Include: `Unknown == "x"`})
+	require.ErrorContains(t, err, "bad include rule")
+	require.ErrorContains(t, err, "undeclared reference to 'Unknown'")
+
+	_, err = CompileRules(types.RulesConfig{Exclude: `1 + 2`})
+	require.ErrorContains(t, err, "bad exclude rule")
+	require.ErrorContains(t, err, "instead of a bool")
+
+	rules, err := CompileRules(types.RulesConfig{Include: `PolicyName > 1`, Exclude: `PolicyName > 1`})
+	require.Nil(t, err)
+	m, err := rules.Match(types.KubearmorPayload{OutputFields: map[string]interface{}{"PolicyName": "ksp"}})
+	require.NotNil(t, err)
+	require.False(t, m, "an event is not included if its include rule fails")
+}
+
+func TestDispatchRules(t *testing.T) {
+	stats := &types.Statistics{Filtered: new(expvar.Map).Init()}
+	d := NewDispatcher(&types.Configuration{Rules: types.RulesConfig{
+		Exclude: `NamespaceName == "kube-system"`,
+		Outputs: map[string]types.RulesConfig{"network": {Include: `Operation == "Network"`}},
+	}}, stats, &types.PromStatistics{})
+
+	o := &testOutput{name: "Network", received: make(chan types.KubearmorPayload, 2)}
+	r := outputRoute{rules: d.compileRules(o)}
+	d.forward(context.Background(), o, r, types.KubearmorPayload{OutputFields: map[string]interface{}{"Operation": "Network"}})
+	d.forward(context.Background(), o, r, types.KubearmorPayload{OutputFields: map[string]interface{}{"Operation": "File"}})
+	require.Len(t, o.received, 1)
+	require.Equal(t, "1", stats.Filtered.Get("Network").String())
+
+	o = &testOutput{name: "other", received: make(chan types.KubearmorPayload, 2)}
+	r = outputRoute{rules: d.compileRules(o)}
+	d.forward(context.Background(), o, r, types.KubearmorPayload{OutputFields: map[string]interface{}{"NamespaceName": "kube-system"}})
+	d.forward(context.Background(), o, r, types.KubearmorPayload{OutputFields: map[string]interface{}{"NamespaceName": "default"}})
+	require.Len(t, o.received, 1)
+}
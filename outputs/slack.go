@@ -4,12 +4,27 @@ import (
 	"bytes"
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Slack",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Slack.WebhookURL != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Slack.MinimumPriority },
+		New:             newSlackClient,
+		Send:            (*Client).SlackPost,
+	})
+}
+
+func newSlackClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Slack", config.Slack.WebhookURL, config.Slack.MutualTLS, config.Slack.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // Field
 type slackAttachmentField struct {
 	Title string `json:"title"`
@@ -50,7 +65,7 @@ func newSlackPayload(kubearmorpayload types.KubearmorPayload, config *types.Conf
 		field.Short = true
 		fields = append(fields, field)
 		field.Title = Source
-		field.Value = kubearmorpayload.OutputFields["PodName"].(string)
+		field.Value = outputField(kubearmorpayload, "PodName")
 		field.Short = true
 		fields = append(fields, field)
 		if kubearmorpayload.Hostname != "" {
@@ -131,7 +146,7 @@ func newSlackPayload(kubearmorpayload types.KubearmorPayload, config *types.Conf
 }
 
 // SlackPost posts event to Slack
-func (c *Client) SlackPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) SlackPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Slack.Add(Total, 1)
 
 	err := c.Post(newSlackPayload(kubearmorpayload, c.Config))
@@ -140,57 +155,13 @@ func (c *Client) SlackPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Slack.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "slack", "status": Error}).Inc()
 		log.Printf("[ERROR] : Slack - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:slack", "status:ok"})
 	c.Stats.Slack.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "slack", "status": OK}).Inc()
-}
-
-func (c *Client) WatchSlackAlerts() error {
-	uid := "slack"
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.SlackPost(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchSlackLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.SlackPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
@@ -15,11 +15,23 @@ import (
 	"github.com/DataDog/datadog-go/statsd"
 	sasl "github.com/emersion/go-sasl"
 	smtp "github.com/emersion/go-smtp"
-	"github.com/google/uuid"
 
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "SMTP",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.SMTP.HostPort != "" && config.SMTP.From != "" && config.SMTP.To != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.SMTP.MinimumPriority },
+		New:             NewSMTPClient,
+		Send:            (*Client).SendMail,
+	})
+}
+
 const rfc2822 = "Mon Jan 02 15:04:05 -0700 2006"
 
 // SMTPPayload is payload for SMTP Output
@@ -127,7 +139,7 @@ func (c *Client) GetAuth() (sasl.Client, error) {
 }
 
 // SendMail sends email to SMTP server
-func (c *Client) SendMail(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) SendMail(kubearmorpayload types.KubearmorPayload) error {
 	sp := newSMTPPayload(kubearmorpayload, c.Config)
 
 	to := strings.Split(strings.ReplaceAll(c.Config.SMTP.To, " ", ""), ",")
@@ -135,7 +147,7 @@ func (c *Client) SendMail(kubearmorpayload types.KubearmorPayload) {
 	smtpClient, err := smtp.Dial(c.Config.SMTP.HostPort)
 	if err != nil {
 		c.ReportErr("Client error", err)
-		return
+		return err
 	}
 	if c.Config.SMTP.TLS {
 		tlsCfg := &tls.Config{
@@ -144,14 +156,14 @@ func (c *Client) SendMail(kubearmorpayload types.KubearmorPayload) {
 		}
 		if err := smtpClient.StartTLS(tlsCfg); err != nil {
 			c.ReportErr("TLS error", err)
-			return
+			return err
 		}
 	}
 	if c.Config.SMTP.AuthMechanism != "none" {
 		auth, err := c.GetAuth()
 		if err != nil {
 			c.ReportErr("SASL Authentication mechanisms", err)
-			return
+			return err
 		}
 		smtpClient.Auth(auth)
 	}
@@ -171,52 +183,12 @@ func (c *Client) SendMail(kubearmorpayload types.KubearmorPayload) {
 	err = smtpClient.SendMail(c.Config.SMTP.From, to, strings.NewReader(body))
 	if err != nil {
 		c.ReportErr("Send Mail failure", err)
-		return
+		return err
 	}
 
 	log.Printf("[INFO]  : SMTP - Sent OK\n")
 	go c.CountMetric("outputs", 1, []string{"output:smtp", "status:ok"})
 	c.Stats.SMTP.Add(OK, 1)
-}
-
-func (c *Client) WatchSendMailAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		case resp := <-conn:
-			c.SendMail(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchSendMailLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		case resp := <-conn:
-			c.SendMail(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
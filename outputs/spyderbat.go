@@ -15,6 +15,17 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Spyderbat",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Spyderbat.OrgUID != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Spyderbat.MinimumPriority },
+		New:             NewSpyderbatClient,
+		Send:            (*Client).SpyderbatPost,
+	})
+}
+
 func isSourcePresent(config *types.Configuration) (bool, error) {
 
 	client := &http.Client{}
@@ -134,8 +145,9 @@ func newSpyderbatPayload(kubearmorpayload types.KubearmorPayload) (spyderbatPayl
 	eventTime := float64(kubearmorpayload.Timestamp / 1000000000.0)
 
 	level := PriorityMap[kubearmorpayload.EventType]
-	arguments := kubearmorpayload.OutputFields["proc.cmdline"].(string)
-	container := kubearmorpayload.OutputFields["container.id"].(string)
+	arguments := outputField(kubearmorpayload, "proc.cmdline")
+	container := outputField(kubearmorpayload, "container.id")
+	pid, _ := kubearmorpayload.OutputFields["PID"].(int32)
 
 	return spyderbatPayload{
 		Schema:        Schema,
@@ -143,7 +155,7 @@ func newSpyderbatPayload(kubearmorpayload types.KubearmorPayload) (spyderbatPayl
 		MonotonicTime: time.Now().Nanosecond(),
 		OrcTime:       nowTime,
 		Time:          eventTime,
-		PID:           int32(kubearmorpayload.OutputFields["PID"].(int32)),
+		PID:           pid,
 		Level:         level,
 		Arguments:     arguments,
 		Container:     container,
@@ -192,27 +204,24 @@ func NewSpyderbatClient(config *types.Configuration, stats *types.Statistics, pr
 	}, nil
 }
 
-func (c *Client) SpyderbatPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) SpyderbatPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Spyderbat.Add(Total, 1)
 
-	c.httpClientLock.Lock()
-	defer c.httpClientLock.Unlock()
-	c.AddHeader("Authorization", "Bearer "+c.Config.Spyderbat.APIKey)
-	c.AddHeader("Content-Encoding", "gzip")
-
 	payload, err := newSpyderbatPayload(kubearmorpayload)
 	if err == nil {
-		err = c.Post(payload)
+		err = c.Post(payload, WithHeader("Authorization", "Bearer "+c.Config.Spyderbat.APIKey), WithHeader("Content-Encoding", "gzip"))
 	}
 	if err != nil {
 		go c.CountMetric(Outputs, 1, []string{"output:spyderbat", "status:error"})
 		c.Stats.Spyderbat.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "spyderbat", "status": Error}).Inc()
 		log.Printf("[ERROR] : Spyderbat - %v\n", err.Error())
-		return
+		return err
 	}
 
 	go c.CountMetric(Outputs, 1, []string{"output:spyderbat", "status:ok"})
 	c.Stats.Spyderbat.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "spyderbat", "status": OK}).Inc()
+
+	return nil
 }
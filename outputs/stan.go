@@ -5,20 +5,38 @@ import (
 	"log"
 	"strings"
 
+	"github.com/DataDog/datadog-go/statsd"
 	stan "github.com/nats-io/stan.go"
 
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "STAN",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.Stan.HostPort != "" && config.Stan.ClusterID != "" && config.Stan.ClientID != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.Stan.MinimumPriority },
+		New:             newStanClient,
+		Send:            (*Client).StanPublish,
+	})
+}
+
+func newStanClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("STAN", config.Stan.HostPort, config.Stan.MutualTLS, config.Stan.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // StanPublish publishes event to NATS Streaming
-func (c *Client) StanPublish(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) StanPublish(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Stan.Add(Total, 1)
 
 	nc, err := stan.Connect(c.Config.Stan.ClusterID, c.Config.Stan.ClientID, stan.NatsURL(c.EndpointURL.String()))
 	if err != nil {
 		c.setStanErrorMetrics()
 		log.Printf("[ERROR] : STAN - %v\n", err.Error())
-		return
+		return err
 	}
 	defer nc.Close()
 
@@ -26,14 +44,14 @@ func (c *Client) StanPublish(kubearmorpayload types.KubearmorPayload) {
 	if err != nil {
 		c.setStanErrorMetrics()
 		log.Printf("[ERROR] : STAN - %v\n", err.Error())
-		return
+		return err
 	}
 
 	err = nc.Publish("kubearmor."+strings.ToLower(kubearmorpayload.EventType)+".", j)
 	if err != nil {
 		c.setStanErrorMetrics()
 		log.Printf("[ERROR] : STAN - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
@@ -41,6 +59,8 @@ func (c *Client) StanPublish(kubearmorpayload types.KubearmorPayload) {
 	c.Stats.Stan.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "stan", "status": OK}).Inc()
 	log.Printf("[INFO]  : STAN - Publish OK\n")
+
+	return nil
 }
 
 // setStanErrorMetrics set the error stats
@@ -32,6 +32,9 @@ func NewStatsdClient(outputType string, config *types.Configuration, stats *type
 
 // CountMetric sends metrics to StatsD/DogStatsD.
 func (c *Client) CountMetric(metric string, value int64, tags []string) {
+	if c.Instance != "" {
+		tags = append(tags, "instance:"+c.Instance)
+	}
 	if c.StatsdClient != nil {
 		c.Stats.Statsd.Add("total", 1)
 		t := ""
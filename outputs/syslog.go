@@ -12,6 +12,17 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Syslog",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Syslog.Host != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Syslog.MinimumPriority },
+		New:             NewSyslogClient,
+		Send:            (*Client).SyslogPost,
+	})
+}
+
 func NewSyslogClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 	ok := isValidProtocolString(strings.ToLower(config.Syslog.Protocol))
 	if !ok {
@@ -43,7 +54,7 @@ func getCEFSeverity(priority string) string {
 	}
 }
 
-func (c *Client) SyslogPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) SyslogPost(kubearmorpayload types.KubearmorPayload) error {
 	//c.Stats.Syslog.Add(Total, 1)
 	endpoint := fmt.Sprintf("%s:%s", c.Config.Syslog.Host, c.Config.Syslog.Port)
 	fmt.Println("endpoint ", endpoint)
@@ -61,7 +72,7 @@ func (c *Client) SyslogPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Syslog.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "syslog", "status": Error}).Inc()
 		log.Printf("[ERROR] : Syslog - %v\n", err)
-		return
+		return err
 	}
 	fmt.Println("syslog - ", sysLog)
 
@@ -100,57 +111,12 @@ func (c *Client) SyslogPost(kubearmorpayload types.KubearmorPayload) {
 		// c.Stats.Syslog.Add(Error, 1)
 		// c.PromStats.Outputs.With(map[string]string{"destination": "syslog", "status": Error}).Inc()
 		log.Printf("[ERROR] : Syslog - %v\n", err)
-		return
+		return err
 	}
 
 	go c.CountMetric(Outputs, 1, []string{"output:syslog", "status:ok"})
 	c.Stats.Syslog.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "syslog", "status": OK}).Inc()
-}
-
-func (c *Client) WatchSyslogsAlerts() error {
-	uid := "syslog"
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			fmt.Println("got it ", resp)
-			c.SyslogPost(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchSyslogLogs() error {
-	uid := "syslog"
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.SyslogPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
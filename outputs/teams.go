@@ -3,12 +3,27 @@ package outputs
 import (
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Teams",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Teams.WebhookURL != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Teams.MinimumPriority },
+		New:             newTeamsClient,
+		Send:            (*Client).TeamsPost,
+	})
+}
+
+func newTeamsClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Teams", config.Teams.WebhookURL, config.Teams.MutualTLS, config.Teams.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 type teamsFact struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
@@ -65,7 +80,7 @@ func newTeamsPayload(kubearmorpayload types.KubearmorPayload, config *types.Conf
 		fact.Value = kubearmorpayload.EventType
 		facts = append(facts, fact)
 		fact.Name = Source
-		fact.Value = kubearmorpayload.OutputFields["PodName"].(string)
+		fact.Value = outputField(kubearmorpayload, "PodName")
 		facts = append(facts, fact)
 		if kubearmorpayload.Hostname != "" {
 			fact.Name = Hostname
@@ -96,7 +111,7 @@ func newTeamsPayload(kubearmorpayload types.KubearmorPayload, config *types.Conf
 }
 
 // TeamsPost posts event to Teams
-func (c *Client) TeamsPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) TeamsPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Teams.Add(Total, 1)
 
 	err := c.Post(newTeamsPayload(kubearmorpayload, c.Config))
@@ -105,57 +120,13 @@ func (c *Client) TeamsPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Teams.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "teams", "status": Error}).Inc()
 		log.Printf("[ERROR] : Teams - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:teams", "status:ok"})
 	c.Stats.Teams.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "teams", "status": OK}).Inc()
-}
-
-func (c *Client) WatchTeamsPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
-
-	for AlertRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.TeamsPost(resp)
-		default:
-			time.Sleep(time.Millisecond * 10)
-
-		}
-	}
-
-	return nil
-}
-
-func (c *Client) WatchTeamsPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
-
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
-
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.TeamsPost(resp)
-
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
 
 	return nil
 }
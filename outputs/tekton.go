@@ -3,11 +3,28 @@ package outputs
 import (
 	"log"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Tekton",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Tekton.EventListener != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Tekton.MinimumPriority },
+		New:             newTektonClient,
+		Send:            (*Client).TektonPost,
+	})
+}
+
+func newTektonClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Tekton", config.Tekton.EventListener, false, config.Tekton.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // TektonPost posts event to EventListner
-func (c *Client) TektonPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) TektonPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Tekton.Add(Total, 1)
 
 	err := c.Post(kubearmorpayload)
@@ -16,11 +33,13 @@ func (c *Client) TektonPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Tekton.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "tekton", "status": Error}).Inc()
 		log.Printf("[ERROR] : Tekton - %v\n", err.Error())
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:tekton", "status:ok"})
 	c.Stats.Tekton.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "tekton", "status": OK}).Inc()
+
+	return nil
 }
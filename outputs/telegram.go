@@ -7,9 +7,28 @@ import (
 	"strings"
 	textTemplate "text/template"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "Telegram",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.Telegram.ChatID != "" && config.Telegram.Token != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.Telegram.MinimumPriority },
+		New:             newTelegramClient,
+		Send:            (*Client).TelegramPost,
+	})
+}
+
+func newTelegramClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Telegram", fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", config.Telegram.Token), false, config.Telegram.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 func markdownV2EscapeText(text interface{}) string {
 
 	replacer := strings.NewReplacer(
@@ -70,7 +89,7 @@ func newTelegramPayload(kubearmorpayload types.KubearmorPayload, config *types.C
 }
 
 // TelegramPost posts event to Telegram
-func (c *Client) TelegramPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) TelegramPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Telegram.Add(Total, 1)
 
 	err := c.Post(newTelegramPayload(kubearmorpayload, c.Config))
@@ -79,11 +98,13 @@ func (c *Client) TelegramPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Telegram.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "telegram", "status": Error}).Inc()
 		log.Printf("[ERROR] : Telegram - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:telegram", "status:ok"})
 	c.Stats.Telegram.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "telegram", "status": OK}).Inc()
+
+	return nil
 }
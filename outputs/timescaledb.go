@@ -4,15 +4,28 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
-	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
-	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "TimescaleDB",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.TimescaleDB.Host != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.TimescaleDB.MinimumPriority },
+		New:             NewTimescaleDBClient,
+		Send:            (*Client).TimescaleDBPost,
+		SendBatch:       (*Client).TimescaleDBCopy,
+		Close:           (*Client).CloseTimescaleDB,
+	})
+}
+
 type timescaledbPayload struct {
 	SQL    string `json:"sql"`
 	Values []any  `json:"values"`
@@ -47,11 +60,13 @@ func NewTimescaleDBClient(config *types.Configuration, stats *types.Statistics,
 	}, nil
 }
 
-func newTimescaleDBPayload(kubearmorpayload types.KubearmorPayload, config *types.Configuration) timescaledbPayload {
+// newTimescaleDBValues returns the values of the columns of the event, "null"
+// strings are NULL values
+func newTimescaleDBValues(kubearmorpayload types.KubearmorPayload, config *types.Configuration) map[string]any {
 	vals := make(map[string]any, 7+len(config.Customfields)+len(config.Templatedfields))
 	vals[Time] = kubearmorpayload.Timestamp
 	vals[Priority] = kubearmorpayload.EventType
-	vals["Source Pod"] = kubearmorpayload.OutputFields["PodName"].(string)
+	vals["Source Pod"] = outputField(kubearmorpayload, "PodName")
 
 	if kubearmorpayload.Hostname != "" {
 		vals[Hostname] = kubearmorpayload.Hostname
@@ -75,6 +90,17 @@ func newTimescaleDBPayload(kubearmorpayload types.KubearmorPayload, config *type
 		}
 	}
 
+	for k, v := range vals {
+		if str, isString := v.(string); isString && strings.ToLower(str) == "null" {
+			vals[k] = nil
+		}
+	}
+	return vals
+}
+
+func newTimescaleDBPayload(kubearmorpayload types.KubearmorPayload, config *types.Configuration) timescaledbPayload {
+	vals := newTimescaleDBValues(kubearmorpayload, config)
+
 	i := 0
 	retVals := make([]any, len(vals))
 	var cols strings.Builder
@@ -86,13 +112,7 @@ func newTimescaleDBPayload(kubearmorpayload types.KubearmorPayload, config *type
 			cols.WriteString(",")
 			args.WriteString(",")
 		}
-
-		str, isString := v.(string)
-		if isString && (strings.ToLower(str) == "null") {
-			retVals[i] = nil
-		} else {
-			retVals[i] = v
-		}
+		retVals[i] = v
 		i++
 	}
 
@@ -104,7 +124,7 @@ func newTimescaleDBPayload(kubearmorpayload types.KubearmorPayload, config *type
 	return timescaledbPayload{SQL: sql, Values: retVals}
 }
 
-func (c *Client) TimescaleDBPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) TimescaleDBPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.TimescaleDB.Add(Total, 1)
 
 	var ctx = context.Background()
@@ -115,7 +135,7 @@ func (c *Client) TimescaleDBPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.TimescaleDB.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "timescaledb", "status": Error}).Inc()
 		log.Printf("[ERROR] : TimescaleDB - %v\n", err)
-		return
+		return err
 	}
 
 	go c.CountMetric(Outputs, 1, []string{"output:timescaledb", "status:ok"})
@@ -125,47 +145,61 @@ func (c *Client) TimescaleDBPost(kubearmorpayload types.KubearmorPayload) {
 	if c.Config.Debug {
 		log.Printf("[DEBUG] : TimescaleDB payload : %v\n", tsdbPayload)
 	}
-}
-
-func (c *Client) WatchTimescaleDBPostAlerts() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
 
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addAlertStruct(uid, conn)
-	defer removeAlertStruct(uid)
+	return nil
+}
 
-	fmt.Println("discord running")
-	for AlertRunning {
-		select {
-		case resp := <-conn:
-			fmt.Println("response \n", resp)
-			c.TimescaleDBPost(resp)
+// TimescaleDBCopy inserts events in TimescaleDB with a single COPY, the
+// columns missing from an event are NULL. A failed COPY is retryable.
+func (c *Client) TimescaleDBCopy(events []types.KubearmorPayload) error {
+	c.Stats.TimescaleDB.Add(Total, int64(len(events)))
+
+	rows := make([]map[string]any, len(events))
+	columns := make(map[string]bool)
+	for i, kubearmorpayload := range events {
+		rows[i] = newTimescaleDBValues(kubearmorpayload, c.Config)
+		for k := range rows[i] {
+			columns[k] = true
 		}
 	}
-	fmt.Println("discord stopped")
-	return nil
-}
+	cols := make([]string, 0, len(columns))
+	for k := range columns {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
 
-func (c *Client) WatchTimescaleDBPostLogs() error {
-	uid := uuid.Must(uuid.NewRandom()).String()
+	values := make([][]any, len(rows))
+	for i, row := range rows {
+		values[i] = make([]any, len(cols))
+		for j, k := range cols {
+			values[i][j] = row[k]
+		}
+	}
+	// the columns are quoted by COPY, they are lowercased like the unquoted
+	// columns of the INSERT statements
+	for i := range cols {
+		cols[i] = strings.ToLower(cols[i])
+	}
 
-	conn := make(chan types.KubearmorPayload, 1000)
-	defer close(conn)
-	addLogStruct(uid, conn)
-	defer removeLogStruct(uid)
+	table := pgx.Identifier(strings.Split(c.Config.TimescaleDB.HypertableName, "."))
+	_, err := c.TimescaleDBClient.CopyFrom(context.Background(), table, cols, pgx.CopyFromRows(values))
+	if err != nil {
+		go c.CountMetric(Outputs, int64(len(events)), []string{"output:timescaledb", "status:error"})
+		c.Stats.TimescaleDB.Add(Error, int64(len(events)))
+		c.PromStats.Outputs.With(map[string]string{"destination": "timescaledb", "status": Error}).Add(float64(len(events)))
+		log.Printf("[ERROR] : TimescaleDB - %v\n", err)
+		return &retryableError{err: err}
+	}
 
-	for LogRunning {
-		select {
-		// case <-Context().Done():
-		// 	return nil
-		case resp := <-conn:
-			c.TimescaleDBPost(resp)
+	go c.CountMetric(Outputs, int64(len(events)), []string{"output:timescaledb", "status:ok"})
+	c.Stats.TimescaleDB.Add(OK, int64(len(events)))
+	c.PromStats.Outputs.With(map[string]string{"destination": "timescaledb", "status": OK}).Add(float64(len(events)))
 
-		default:
-			time.Sleep(time.Millisecond * 10)
-		}
-	}
+	return nil
+}
 
+// CloseTimescaleDB waits for the running queries and closes the connection pool
+func (c *Client) CloseTimescaleDB(ctx context.Context) error {
+	c.TimescaleDBClient.Close()
 	return nil
 }
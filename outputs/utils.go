@@ -1,6 +1,10 @@
 package outputs
 
-import "sort"
+import (
+	"sort"
+
+	"github.com/kubearmor/sidekick/types"
+)
 
 func getSortedStringKeys(m map[string]interface{}) []string {
 	var keys []string
@@ -15,3 +19,10 @@ func getSortedStringKeys(m map[string]interface{}) []string {
 	sort.Strings(keys)
 	return keys
 }
+
+// outputField returns the field of the event, empty if the event has no such
+// field or if it is not a string, like in the summaries of the events.
+func outputField(kubearmorpayload types.KubearmorPayload, name string) string {
+	v, _ := kubearmorpayload.OutputFields[name].(string)
+	return v
+}
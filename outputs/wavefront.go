@@ -9,6 +9,19 @@ import (
 	wavefront "github.com/wavefronthq/wavefront-sdk-go/senders"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "Wavefront",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled: func(config *types.Configuration) bool {
+			return config.Wavefront.EndpointType != "" && config.Wavefront.EndpointHost != ""
+		},
+		MinimumPriority: func(config *types.Configuration) string { return config.Wavefront.MinimumPriority },
+		New:             NewWavefrontClient,
+		Send:            (*Client).WavefrontPost,
+	})
+}
+
 // NewWavefrontClient returns a new output.Client for accessing the Wavefront API.
 func NewWavefrontClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 
@@ -58,11 +71,11 @@ func NewWavefrontClient(config *types.Configuration, stats *types.Statistics, pr
 }
 
 // WavefrontPost sends metrics to WaveFront.
-func (c *Client) WavefrontPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) WavefrontPost(kubearmorpayload types.KubearmorPayload) error {
 
 	tags := make(map[string]string)
 	tags["severity"] = kubearmorpayload.EventType
-	tags["source"] = kubearmorpayload.OutputFields["PodName"].(string)
+	tags["source"] = outputField(kubearmorpayload, "PodName")
 
 	if kubearmorpayload.Hostname != "" {
 		tags[Hostname] = kubearmorpayload.Hostname
@@ -85,14 +98,16 @@ func (c *Client) WavefrontPost(kubearmorpayload types.KubearmorPayload) {
 		if err := sender.SendMetric(c.Config.Wavefront.MetricName, 1, kubearmorpayload.Timestamp, "kubearmor", tags); err != nil {
 			c.Stats.Wavefront.Add(Error, 1)
 			c.PromStats.Outputs.With(map[string]string{"destination": "wavefront", "status": Error}).Inc()
-			return
+			return err
 		}
 		if err := sender.Flush(); err != nil {
 			c.Stats.Wavefront.Add(Error, 1)
 			c.PromStats.Outputs.With(map[string]string{"destination": "wavefront", "status": Error}).Inc()
-			return
+			return err
 		}
 		c.Stats.Wavefront.Add(OK, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "wavefront", "status": OK}).Inc()
 	}
+
+	return nil
 }
@@ -4,25 +4,35 @@ import (
 	"log"
 	"strings"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Webhook",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Webhook.Address != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Webhook.MinimumPriority },
+		New:             newWebhookClient,
+		Send:            (*Client).WebhookPost,
+	})
+}
+
+func newWebhookClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Webhook", config.Webhook.Address, config.Webhook.MutualTLS, config.Webhook.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // WebhookPost posts event to an URL
-func (c *Client) WebhookPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) WebhookPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Webhook.Add(Total, 1)
 
-	if len(c.Config.Webhook.CustomHeaders) != 0 {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		for i, j := range c.Config.Webhook.CustomHeaders {
-			c.AddHeader(i, j)
-		}
-	}
 	var err error
 	if strings.ToUpper(c.Config.Webhook.Method) == HttpPut {
-		err = c.Put(kubearmorpayload)
+		err = c.Put(kubearmorpayload, WithHeaders(c.Config.Webhook.CustomHeaders))
 	} else {
-		err = c.Post(kubearmorpayload)
+		err = c.Post(kubearmorpayload, WithHeaders(c.Config.Webhook.CustomHeaders))
 	}
 
 	if err != nil {
@@ -30,11 +40,13 @@ func (c *Client) WebhookPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.Webhook.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "webhook", "status": Error}).Inc()
 		log.Printf("[ERROR] : WebHook - %v\n", err.Error())
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:webhook", "status:ok"})
 	c.Stats.Webhook.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "webhook", "status": OK}).Inc()
+
+	return nil
 }
@@ -3,9 +3,25 @@ package outputs
 import (
 	"log"
 
+	"github.com/DataDog/datadog-go/statsd"
+
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:       "WebUI",
+		EventTypes: []string{AlertEventType, LogEventType},
+		Enabled:    func(config *types.Configuration) bool { return config.WebUI.URL != "" },
+		New:        newWebUIClient,
+		Send:       (*Client).WebUIPost,
+	})
+}
+
+func newWebUIClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("WebUI", config.WebUI.URL, config.WebUI.MutualTLS, config.WebUI.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 type WebUIPayload struct {
 	Event   types.KubearmorPayload `json:"event"`
 	Outputs []string               `json:"outputs"`
@@ -19,7 +35,7 @@ func newWebUIPayload(kubearmorpayload types.KubearmorPayload, config *types.Conf
 }
 
 // WebUIPost posts event to Slack
-func (c *Client) WebUIPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) WebUIPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.WebUI.Add(Total, 1)
 
 	err := c.Post(newWebUIPayload(kubearmorpayload, c.Config))
@@ -28,11 +44,13 @@ func (c *Client) WebUIPost(kubearmorpayload types.KubearmorPayload) {
 		c.Stats.WebUI.Add(Error, 1)
 		c.PromStats.Outputs.With(map[string]string{"destination": "webui", "status": Error}).Inc()
 		log.Printf("[ERROR] : WebUI - %v\n", err.Error())
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:webui", "status:ok"})
 	c.Stats.WebUI.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "webui", "status": OK}).Inc()
+
+	return nil
 }
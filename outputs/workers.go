@@ -0,0 +1,119 @@
+package outputs
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// Ordering keys of the workers, the events with the same key are sent in order
+const (
+	OrderingPod       = "pod"
+	OrderingNamespace = "namespace"
+	OrderingHost      = "host"
+)
+
+// workerLaneSize is the number of events waiting for a worker before the
+// events of the output wait in their buffer
+const workerLaneSize = 64
+
+// workersPolicy returns the workers settings of the output, the default ones
+// if the output has none of its own.
+func workersPolicy(workers types.WorkersConfig, name string) types.WorkersConfig {
+	if w, ok := workers.Outputs[strings.ToLower(name)]; ok {
+		return w
+	}
+	workers.Outputs = nil
+	return workers
+}
+
+// orderingKey returns the function computing the ordering key of an event,
+// nil for no ordering.
+func orderingKey(key string) func(kubearmorpayload types.KubearmorPayload) string {
+	switch key {
+	case OrderingPod:
+		return func(kubearmorpayload types.KubearmorPayload) string {
+			return fmt.Sprintf("%v/%v", kubearmorpayload.OutputFields["NamespaceName"], kubearmorpayload.OutputFields["PodName"])
+		}
+	case OrderingNamespace:
+		return func(kubearmorpayload types.KubearmorPayload) string {
+			return fmt.Sprint(kubearmorpayload.OutputFields["NamespaceName"])
+		}
+	case OrderingHost:
+		return func(kubearmorpayload types.KubearmorPayload) string {
+			return kubearmorpayload.Hostname
+		}
+	}
+	return nil
+}
+
+// workerPool sends the events of an output from several workers. Without
+// ordering key, the workers share a single lane; with one, every worker has
+// its own lane and the events with the same key always go to the same lane.
+type workerPool struct {
+	lanes    []chan types.KubearmorPayload
+	key      func(kubearmorpayload types.KubearmorPayload) string
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newWorkerPool starts the workers calling send, it returns nil with a single
+// worker, the events are then sent by the goroutine receiving them.
+func newWorkerPool(config types.WorkersConfig, send func(kubearmorpayload types.KubearmorPayload)) *workerPool {
+	if config.Workers <= 1 {
+		return nil
+	}
+	p := &workerPool{key: orderingKey(config.OrderingKey)}
+	if config.MaxInFlight > 0 && config.MaxInFlight < config.Workers {
+		p.inFlight = make(chan struct{}, config.MaxInFlight)
+	}
+	lanes := 1
+	if p.key != nil {
+		lanes = config.Workers
+	}
+	p.lanes = make([]chan types.KubearmorPayload, lanes)
+	for i := range p.lanes {
+		p.lanes[i] = make(chan types.KubearmorPayload, workerLaneSize)
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		lane := p.lanes[i%lanes]
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for kubearmorpayload := range lane {
+				if p.inFlight != nil {
+					p.inFlight <- struct{}{}
+				}
+				send(kubearmorpayload)
+				if p.inFlight != nil {
+					<-p.inFlight
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// Submit hands the event to the workers, it blocks while its lane is full
+func (p *workerPool) Submit(kubearmorpayload types.KubearmorPayload) {
+	if len(p.lanes) == 1 {
+		p.lanes[0] <- kubearmorpayload
+		return
+	}
+	h := fnv.New32a()
+	h.Write([]byte(p.key(kubearmorpayload)))
+	p.lanes[h.Sum32()%uint32(len(p.lanes))] <- kubearmorpayload
+}
+
+// Close waits for the workers to send the events submitted, no event must be
+// submitted afterwards.
+func (p *workerPool) Close() {
+	for _, lane := range p.lanes {
+		close(lane)
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,133 @@
+package outputs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+func podEvent(pod string, seq int) types.KubearmorPayload {
+	return types.KubearmorPayload{OutputFields: map[string]interface{}{"NamespaceName": "default", "PodName": pod, "Seq": seq}}
+}
+
+func TestWorkerPoolOrdering(t *testing.T) {
+	require.Nil(t, newWorkerPool(types.WorkersConfig{Workers: 1}, func(types.KubearmorPayload) {}))
+
+	var lock sync.Mutex
+	received := make(map[string][]int)
+	p := newWorkerPool(types.WorkersConfig{Workers: 4, OrderingKey: OrderingPod}, func(kubearmorpayload types.KubearmorPayload) {
+		time.Sleep(time.Duration(kubearmorpayload.OutputFields["Seq"].(int)%3) * time.Millisecond)
+		lock.Lock()
+		defer lock.Unlock()
+		pod := kubearmorpayload.OutputFields["PodName"].(string)
+		received[pod] = append(received[pod], kubearmorpayload.OutputFields["Seq"].(int))
+	})
+	for seq := 0; seq < 20; seq++ {
+		for pod := 0; pod < 5; pod++ {
+			p.Submit(podEvent(fmt.Sprintf("pod-%v", pod), seq))
+		}
+	}
+	p.Close()
+
+	require.Len(t, received, 5)
+	for pod, seqs := range received {
+		require.Len(t, seqs, 20, pod)
+		for i, seq := range seqs {
+			require.Equal(t, i, seq, "the events of %v must be sent in order", pod)
+		}
+	}
+}
+
+func TestWorkerPoolMaxInFlight(t *testing.T) {
+	var inFlight, maxInFlight, sent atomic.Int32
+	p := newWorkerPool(types.WorkersConfig{Workers: 8, MaxInFlight: 2}, func(types.KubearmorPayload) {
+		n := inFlight.Add(1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		inFlight.Add(-1)
+		sent.Add(1)
+	})
+	for i := 0; i < 50; i++ {
+		p.Submit(types.KubearmorPayload{})
+	}
+	p.Close()
+
+	require.EqualValues(t, 50, sent.Load())
+	require.EqualValues(t, 2, maxInFlight.Load())
+}
+
+func TestDispatchWorkers(t *testing.T) {
+	d := NewDispatcher(&types.Configuration{Workers: types.WorkersConfig{Workers: 1, Outputs: map[string]types.WorkersConfig{"slow": {Workers: 4}}}}, &types.Statistics{}, &types.PromStatistics{})
+	o := &testOutput{name: "Slow", received: make(chan types.KubearmorPayload, 4)}
+	pool := d.newWorkerPool(o, outputRoute{})
+	require.NotNil(t, pool)
+	for i := 0; i < 4; i++ {
+		d.forward(context.Background(), o, outputRoute{pool: pool}, types.KubearmorPayload{})
+	}
+	pool.Close()
+	require.Len(t, o.received, 4)
+
+	require.Nil(t, d.newWorkerPool(&testOutput{name: "other"}, outputRoute{}))
+}
+
+// BenchmarkWorkers sends events to a destination answering in a millisecond
+func BenchmarkWorkers(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	}))
+	defer ts.Close()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, config := range []types.WorkersConfig{
+		{Workers: 1},
+		{Workers: 8},
+		{Workers: 32},
+		{Workers: 32, MaxInFlight: 8},
+		{Workers: 32, OrderingKey: OrderingPod},
+	} {
+		b.Run(fmt.Sprintf("workers=%v,maxinflight=%v,ordering=%v", config.Workers, config.MaxInFlight, config.OrderingKey), func(b *testing.B) {
+			nc, err := NewClient("", ts.URL, false, true, &types.Configuration{}, &types.Statistics{}, &types.PromStatistics{}, nil, nil)
+			require.Nil(b, err)
+			nc.HTTPClient = types.HTTPClientConfig{MaxIdleConnsPerHost: config.Workers}
+			send := func(kubearmorpayload types.KubearmorPayload) {
+				if err := nc.Post(kubearmorpayload); err != nil {
+					b.Error(err)
+				}
+			}
+			p := newWorkerPool(config, send)
+
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				event := podEvent(fmt.Sprintf("pod-%v", i%64), i)
+				if p == nil {
+					send(event)
+				} else {
+					p.Submit(event)
+				}
+			}
+			if p != nil {
+				p.Close()
+			}
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "events/s")
+		})
+	}
+}
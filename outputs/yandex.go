@@ -20,6 +20,25 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "YandexS3",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Yandex.S3.Bucket != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Yandex.S3.MinimumPriority },
+		New:             NewYandexClient,
+		Send:            (*Client).UploadYandexS3,
+	})
+	RegisterOutput(Registration{
+		Name:            "YandexDataStreams",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Yandex.DataStreams.StreamName != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Yandex.DataStreams.MinimumPriority },
+		New:             NewYandexClient,
+		Send:            (*Client).UploadYandexDataStreams,
+	})
+}
+
 // NewYandexClient returns a new output.Client for accessing the Yandex API.
 func NewYandexClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
 	resolverFn := func(service, region string, optFns ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
@@ -62,7 +81,7 @@ func NewYandexClient(config *types.Configuration, stats *types.Statistics, promS
 }
 
 // UploadYandexS3 uploads payload to Yandex S3
-func (c *Client) UploadYandexS3(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) UploadYandexS3(kubearmorpayload types.KubearmorPayload) error {
 	f, _ := json.Marshal(kubearmorpayload)
 	prefix := ""
 	t := time.Now()
@@ -79,17 +98,19 @@ func (c *Client) UploadYandexS3(kubearmorpayload types.KubearmorPayload) {
 		go c.CountMetric("outputs", 1, []string{"output:yandexs3", "status:error"})
 		c.PromStats.Outputs.With(map[string]string{"destination": "yandexs3", "status": Error}).Inc()
 		log.Printf("[ERROR] : %v S3 - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
 	log.Printf("[INFO]  : %v S3 - Upload payload OK\n", c.OutputType)
 
 	go c.CountMetric("outputs", 1, []string{"output:yandexs3", "status:ok"})
 	c.PromStats.Outputs.With(map[string]string{"destination": "yandexs3", "status": "ok"}).Inc()
+
+	return nil
 }
 
 // UploadYandexDataStreams uploads payload to Yandex Data Streams
-func (c *Client) UploadYandexDataStreams(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) UploadYandexDataStreams(kubearmorpayload types.KubearmorPayload) error {
 	svc := kinesis.New(c.AWSSession)
 
 	f, _ := json.Marshal(kubearmorpayload)
@@ -104,11 +125,13 @@ func (c *Client) UploadYandexDataStreams(kubearmorpayload types.KubearmorPayload
 		go c.CountMetric("outputs", 1, []string{"output:yandexdatastreams", "status:error"})
 		c.PromStats.Outputs.With(map[string]string{"destination": "yandexdatastreams", "status": Error}).Inc()
 		log.Printf("[ERROR] : %v Data Streams - %v\n", c.OutputType, err.Error())
-		return
+		return err
 	}
 
 	log.Printf("[INFO] : %v Data Streams - Put Record OK (%v)\n", c.OutputType, resp.SequenceNumber)
 	go c.CountMetric("outputs", 1, []string{"output:yandexdatastreams", "status:ok"})
 	c.Stats.YandexDataStreams.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "yandexdatastreams", "status": "ok"}).Inc()
+
+	return nil
 }
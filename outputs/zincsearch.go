@@ -3,37 +3,88 @@ package outputs
 import (
 	"fmt"
 	"log"
+	"net/url"
+
+	"github.com/DataDog/datadog-go/statsd"
 
 	"github.com/kubearmor/sidekick/types"
 )
 
+func init() {
+	RegisterOutput(Registration{
+		Name:            "Zincsearch",
+		EventTypes:      []string{AlertEventType, LogEventType},
+		Enabled:         func(config *types.Configuration) bool { return config.Zincsearch.HostPort != "" },
+		MinimumPriority: func(config *types.Configuration) string { return config.Zincsearch.MinimumPriority },
+		New:             newZincsearchClient,
+		Send:            (*Client).ZincsearchPost,
+		SendBatch:       (*Client).ZincsearchBulk,
+	})
+}
+
+func newZincsearchClient(config *types.Configuration, stats *types.Statistics, promStats *types.PromStatistics, statsdClient, dogstatsdClient *statsd.Client) (*Client, error) {
+	return NewClient("Zincsearch", config.Zincsearch.HostPort+"/api/"+config.Zincsearch.Index+"/_doc", false, config.Zincsearch.CheckCert, config, stats, promStats, statsdClient, dogstatsdClient)
+}
+
 // ZincsearchPost posts event to Zincsearch
-func (c *Client) ZincsearchPost(kubearmorpayload types.KubearmorPayload) {
+func (c *Client) ZincsearchPost(kubearmorpayload types.KubearmorPayload) error {
 	c.Stats.Zincsearch.Add(Total, 1)
 
-	if c.Config.Zincsearch.Username != "" && c.Config.Zincsearch.Password != "" {
-		c.httpClientLock.Lock()
-		defer c.httpClientLock.Unlock()
-		c.BasicAuth(c.Config.Zincsearch.Username, c.Config.Zincsearch.Password)
-	}
-
 	fmt.Println(c.EndpointURL)
-	err := c.Post(kubearmorpayload)
+	err := c.Post(kubearmorpayload, c.zincsearchOptions()...)
 	if err != nil {
-		c.setZincsearchErrorMetrics()
+		c.setZincsearchErrorMetrics(1)
 		log.Printf("[ERROR] : Zincsearch - %v\n", err)
-		return
+		return err
 	}
 
 	// Setting the success status
 	go c.CountMetric(Outputs, 1, []string{"output:zincsearch", "status:ok"})
 	c.Stats.Zincsearch.Add(OK, 1)
 	c.PromStats.Outputs.With(map[string]string{"destination": "zincsearch", "status": OK}).Inc()
+
+	return nil
+}
+
+type zincsearchBulkPayload struct {
+	Index   string                   `json:"index"`
+	Records []types.KubearmorPayload `json:"records"`
+}
+
+// ZincsearchBulk posts events to Zincsearch with the _bulkv2 API
+func (c *Client) ZincsearchBulk(events []types.KubearmorPayload) error {
+	c.Stats.Zincsearch.Add(Total, int64(len(events)))
+
+	endpointURL, err := url.Parse(c.Config.Zincsearch.HostPort + "/api/_bulkv2")
+	if err == nil {
+		err = c.Post(zincsearchBulkPayload{Index: c.Config.Zincsearch.Index, Records: events}, append(c.zincsearchOptions(), WithEndpointURL(endpointURL), WithoutRetries())...)
+	}
+	if err != nil {
+		c.setZincsearchErrorMetrics(len(events))
+		log.Printf("[ERROR] : Zincsearch - %v\n", err)
+		return err
+	}
+
+	// Setting the success status
+	go c.CountMetric(Outputs, int64(len(events)), []string{"output:zincsearch", "status:ok"})
+	c.Stats.Zincsearch.Add(OK, int64(len(events)))
+	c.PromStats.Outputs.With(map[string]string{"destination": "zincsearch", "status": OK}).Add(float64(len(events)))
+
+	return nil
+}
+
+// zincsearchOptions returns the credentials of the requests
+func (c *Client) zincsearchOptions() []RequestOption {
+	var opts []RequestOption
+	if c.Config.Zincsearch.Username != "" && c.Config.Zincsearch.Password != "" {
+		opts = append(opts, WithBasicAuth(c.Config.Zincsearch.Username, c.Config.Zincsearch.Password))
+	}
+	return opts
 }
 
 // setZincsearchErrorMetrics set the error stats
-func (c *Client) setZincsearchErrorMetrics() {
-	go c.CountMetric(Outputs, 1, []string{"output:zincsearch", "status:error"})
-	c.Stats.Zincsearch.Add(Error, 1)
-	c.PromStats.Outputs.With(map[string]string{"destination": "zincsearch", "status": Error}).Inc()
+func (c *Client) setZincsearchErrorMetrics(events int) {
+	go c.CountMetric(Outputs, int64(events), []string{"output:zincsearch", "status:error"})
+	c.Stats.Zincsearch.Add(Error, int64(events))
+	c.PromStats.Outputs.With(map[string]string{"destination": "zincsearch", "status": Error}).Add(float64(events))
 }
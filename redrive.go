@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kubearmor/sidekick/outputs"
+)
+
+// redriveFile is the dead-letter file given with -redrive, its events are sent
+// again to their outputs instead of starting the daemon.
+var redriveFile string
+
+// redrive sends the events of the dead-letter file to the outputs which could
+// not deliver them. The file is rewritten with the events failing again, or
+// whose output is not enabled.
+func redrive(path string, enabled []outputs.Output) (sent, failed int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var deadLetters []outputs.DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxReplayLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var deadLetter outputs.DeadLetter
+		if err := json.Unmarshal(line, &deadLetter); err != nil {
+			f.Close()
+			return 0, 0, fmt.Errorf("bad dead letter in %v: %w", path, err)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	byName := make(map[string]outputs.Output, len(enabled))
+	for _, o := range enabled {
+		byName[strings.ToLower(o.Name())] = o
+	}
+	var left []outputs.DeadLetter
+	for _, deadLetter := range deadLetters {
+		o, ok := byName[strings.ToLower(deadLetter.Output)]
+		if !ok {
+			log.Printf("[WARN]  : Redrive - Output %v is not enabled, the event is kept\n", deadLetter.Output)
+			left = append(left, deadLetter)
+			continue
+		}
+		if err := o.Send(deadLetter.Event); err != nil {
+			deadLetter.Error = err.Error()
+			deadLetter.Attempts += outputs.Attempts(err)
+			deadLetter.Time = time.Now().UTC()
+			left = append(left, deadLetter)
+			continue
+		}
+		sent++
+	}
+
+	tmp := path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return sent, len(left), err
+	}
+	w := bufio.NewWriter(out)
+	for _, deadLetter := range left {
+		line, _ := json.Marshal(deadLetter)
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return sent, len(left), err
+	}
+	if err := out.Close(); err != nil {
+		return sent, len(left), err
+	}
+	return sent, len(left), os.Rename(tmp, path)
+}
+
+// redriveEvents runs the -redrive command, it closes the outputs once the
+// events are sent.
+func redriveEvents(path string) error {
+	sent, failed, err := redrive(path, enabledOutputs)
+	log.Printf("[INFO]  : Redrive - %v events sent, %v left in %v\n", sent, failed, path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeout)*time.Second)
+	defer cancel()
+	for _, o := range enabledOutputs {
+		if cerr := o.Close(ctx); cerr != nil {
+			log.Printf("[ERROR] : %v - %v\n", o.Name(), cerr)
+		}
+	}
+	if err == nil && failed != 0 {
+		err = fmt.Errorf("%v events could not be sent", failed)
+	}
+	return err
+}
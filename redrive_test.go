@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/outputs"
+	"github.com/kubearmor/sidekick/types"
+)
+
+type redriveOutput struct {
+	name string
+	sent []types.KubearmorPayload
+}
+
+func (o *redriveOutput) Name() string                        { return o.name }
+func (o *redriveOutput) EventTypes() []string                { return []string{outputs.AlertEventType} }
+func (o *redriveOutput) MinimumPriority() types.PriorityType { return types.Default }
+func (o *redriveOutput) Close(ctx context.Context) error     { return nil }
+func (o *redriveOutput) Send(kubearmorpayload types.KubearmorPayload) error {
+	if kubearmorpayload.Hostname == "bad" {
+		return errors.New("rejected")
+	}
+	o.sent = append(o.sent, kubearmorpayload)
+	return nil
+}
+
+func TestRedrive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletter.jsonl")
+	var lines []string
+	for _, d := range []outputs.DeadLetter{
+		{Output: "slack", Attempts: 3, Event: types.KubearmorPayload{Hostname: "good"}},
+		{Output: "Slack", Attempts: 1, Event: types.KubearmorPayload{Hostname: "bad"}},
+		{Output: "Kafka", Attempts: 1, Event: types.KubearmorPayload{Hostname: "good"}},
+	} {
+		line, err := json.Marshal(d)
+		require.Nil(t, err)
+		lines = append(lines, string(line))
+	}
+	require.Nil(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+
+	o := &redriveOutput{name: "Slack"}
+	sent, failed, err := redrive(path, []outputs.Output{o})
+	require.Nil(t, err)
+	require.Equal(t, 1, sent)
+	require.Equal(t, 2, failed)
+	require.Len(t, o.sent, 1)
+	require.Equal(t, "good", o.sent[0].Hostname)
+
+	b, err := os.ReadFile(path)
+	require.Nil(t, err)
+	lines = strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Len(t, lines, 2)
+	var d outputs.DeadLetter
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &d))
+	require.Equal(t, "bad", d.Event.Hostname)
+	require.Equal(t, "rejected", d.Error)
+	require.Equal(t, 2, d.Attempts)
+	require.Nil(t, json.Unmarshal([]byte(lines[1]), &d))
+	require.Equal(t, "Kafka", d.Output)
+}
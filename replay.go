@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kubearmor/sidekick/outputs"
+	"github.com/kubearmor/sidekick/types"
+)
+
+// Modes of the replay
+const (
+	ReplayModeOriginal string = "original"
+	ReplayModeFixed    string = "fixed"
+	ReplayModeFast     string = "fast"
+)
+
+// maxReplayLineSize is the maximum size of an event in a replayed file
+const maxReplayLineSize = 10 << 20
+
+// replayPacer spaces out the replayed events according to the mode of the replay.
+type replayPacer struct {
+	mode     string
+	interval time.Duration
+	// time of the previous event, as recorded in the event with the original
+	// mode, or when it was sent with the fixed mode
+	last time.Time
+}
+
+func newReplayPacer(replay types.ReplayConfig) *replayPacer {
+	p := &replayPacer{mode: replay.Mode}
+	if replay.Mode == ReplayModeFixed {
+		p.interval = time.Duration(float64(time.Second) / replay.Rate)
+	}
+	return p
+}
+
+// delay returns how long to wait before sending the event.
+func (p *replayPacer) delay(kubearmorpayload types.KubearmorPayload, now time.Time) time.Duration {
+	switch p.mode {
+	case ReplayModeOriginal:
+		t := eventTime(kubearmorpayload)
+		if t.IsZero() {
+			return 0
+		}
+		var d time.Duration
+		if !p.last.IsZero() && t.After(p.last) {
+			d = t.Sub(p.last)
+		}
+		if p.last.IsZero() || t.After(p.last) {
+			p.last = t
+		}
+		return d
+	case ReplayModeFixed:
+		next := p.last.Add(p.interval)
+		if p.last.IsZero() || !next.After(now) {
+			p.last = now
+			return 0
+		}
+		p.last = next
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// eventTime returns when the event happened, zero if unknown.
+func eventTime(kubearmorpayload types.KubearmorPayload) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, kubearmorpayload.UpdatedTime); err == nil {
+		return t
+	}
+	if kubearmorpayload.Timestamp > 0 {
+		return time.Unix(kubearmorpayload.Timestamp, 0)
+	}
+	return time.Time{}
+}
+
+// replayEvents sends the events recorded in the files of replay.Path, or read
+// from stdin, to the outputs, until the context is canceled.
+func replayEvents(ctx context.Context, replay types.ReplayConfig) error {
+	files, err := replayFiles(replay.Path)
+	if err != nil {
+		return err
+	}
+
+	pacer := newReplayPacer(replay)
+	for _, f := range files {
+		if err := replayFile(ctx, f, pacer); err != nil {
+			return err
+		}
+	}
+	log.Printf("[INFO]  : Replay - Done, %v events accepted, %v rejected\n", stats.Replay.Get(outputs.Accepted), stats.Replay.Get(outputs.Rejected))
+	return nil
+}
+
+// replayFiles lists the files to replay, sorted by name for a directory.
+func replayFiles(path string) ([]string, error) {
+	if path == "-" {
+		return []string{path}, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		files = append(files, filepath.Join(path, e.Name()))
+	}
+	return files, nil
+}
+
+// replayFile replays a JSONL file, gzipped if its name ends with .gz, or stdin
+// for "-". Malformed events are logged and skipped.
+func replayFile(ctx context.Context, path string, pacer *replayPacer) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+		if strings.HasSuffix(path, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return err
+			}
+			defer gz.Close()
+			r = gz
+		}
+	}
+	log.Printf("[INFO]  : Replay - Replaying %v\n", path)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxReplayLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		raw := scanner.Bytes()
+		if len(strings.TrimSpace(string(raw))) == 0 {
+			continue
+		}
+
+		stats.Replay.Add(outputs.Total, 1)
+		kubearmorpayload, err := newKubearmorPayload(raw)
+		if err == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pacer.delay(kubearmorpayload, time.Now())):
+			}
+			err = sendEvent(kubearmorpayload, true)
+		}
+		if err != nil {
+			log.Printf("[ERROR] : Replay - %v:%v - %v\n", path, line, err)
+			stats.Replay.Add(outputs.Rejected, 1)
+			promStats.Inputs.With(map[string]string{"source": "replay", "status": outputs.Rejected}).Inc()
+			continue
+		}
+		stats.Replay.Add(outputs.Accepted, 1)
+		promStats.Inputs.With(map[string]string{"source": "replay", "status": outputs.Accepted}).Inc()
+	}
+	return scanner.Err()
+}
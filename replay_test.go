@@ -0,0 +1,126 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"expvar"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubearmor/sidekick/outputs"
+	"github.com/kubearmor/sidekick/types"
+)
+
+func TestReplayPacer(t *testing.T) {
+	now := time.Now()
+
+	p := newReplayPacer(types.ReplayConfig{Mode: ReplayModeFast})
+	require.Zero(t, p.delay(types.KubearmorPayload{Timestamp: 1}, now))
+	require.Zero(t, p.delay(types.KubearmorPayload{Timestamp: 100}, now))
+
+	p = newReplayPacer(types.ReplayConfig{Mode: ReplayModeOriginal})
+	require.Zero(t, p.delay(types.KubearmorPayload{UpdatedTime: "2023-09-13T15:35:02.5Z"}, now))
+	require.Equal(t, 1500*time.Millisecond, p.delay(types.KubearmorPayload{UpdatedTime: "2023-09-13T15:35:04Z"}, now))
+	require.Zero(t, p.delay(types.KubearmorPayload{UpdatedTime: "2023-09-13T15:35:03Z"}, now))
+	require.Equal(t, 2*time.Second, p.delay(types.KubearmorPayload{Timestamp: time.Date(2023, 9, 13, 15, 35, 6, 0, time.UTC).Unix()}, now))
+
+	p = newReplayPacer(types.ReplayConfig{Mode: ReplayModeFixed, Rate: 4})
+	require.Zero(t, p.delay(types.KubearmorPayload{}, now))
+	require.Equal(t, 250*time.Millisecond, p.delay(types.KubearmorPayload{}, now))
+	require.Equal(t, 500*time.Millisecond, p.delay(types.KubearmorPayload{}, now))
+	require.Zero(t, p.delay(types.KubearmorPayload{}, now.Add(time.Second)))
+}
+
+func TestReplayEvents(t *testing.T) {
+	config = &types.Configuration{Log: true}
+	stats = &types.Statistics{Replay: new(expvar.Map).Init()}
+	promStats = &types.PromStatistics{Inputs: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_inputs"}, []string{"source", "status"})}
+	defer func() { config, stats, promStats = nil, nil, nil }()
+
+	outputs.Initvariable(types.BuffersConfig{})
+	alerts := outputs.NewBuffer[types.KubearmorPayload](types.BufferConfig{Size: 10}, 0, outputs.OutputAlertsStage, "test")
+	defer alerts.Close()
+	logs := outputs.NewBuffer[types.KubearmorPayload](types.BufferConfig{Size: 10}, 0, outputs.OutputLogsStage, "test")
+	defer logs.Close()
+	outputs.AlertStructs["test"] = outputs.AlertStruct{Broadcast: alerts}
+	outputs.LogStructs["test"] = outputs.LogStruct{Broadcast: logs}
+
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "1.jsonl"), []byte(
+		`{"Timestamp": 1631542902, "HostName": "node1", "PolicyName": "policy1", "Type": "MatchedPolicy"}
+not json
+
+{"Timestamp": 1631542903, "HostName": "node1", "Type": "ContainerLog"}
+`), 0600))
+	f, err := os.Create(filepath.Join(dir, "2.jsonl.gz"))
+	require.Nil(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(`{"Timestamp": 1631542904, "HostName": "node1", "PolicyName": "policy2", "Type": "MatchedPolicy"}` + "\n"))
+	require.Nil(t, err)
+	require.Nil(t, gz.Close())
+	require.Nil(t, f.Close())
+	require.Nil(t, os.WriteFile(filepath.Join(dir, ".hidden"), []byte("not json\n"), 0600))
+
+	require.Nil(t, replayEvents(context.Background(), types.ReplayConfig{Path: dir, Mode: ReplayModeFast}))
+
+	require.Equal(t, "policy1", (<-alerts.C).OutputFields["PolicyName"])
+	require.Equal(t, "policy2", (<-alerts.C).OutputFields["PolicyName"])
+	require.Equal(t, outputs.LogEventType, (<-logs.C).EventType)
+	require.Equal(t, "4", stats.Replay.Get(outputs.Total).String())
+	require.Equal(t, "3", stats.Replay.Get(outputs.Accepted).String())
+	require.Equal(t, "1", stats.Replay.Get(outputs.Rejected).String())
+
+	require.NotNil(t, replayEvents(context.Background(), types.ReplayConfig{Path: filepath.Join(dir, "missing.jsonl")}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, replayEvents(ctx, types.ReplayConfig{Path: dir, Mode: ReplayModeFast}), context.Canceled)
+}
+
+type replayOutput struct {
+	received chan types.KubearmorPayload
+}
+
+func (o *replayOutput) Name() string { return "replay" }
+func (o *replayOutput) EventTypes() []string {
+	return []string{outputs.AlertEventType, outputs.LogEventType}
+}
+func (o *replayOutput) MinimumPriority() types.PriorityType { return types.Default }
+func (o *replayOutput) Send(kubearmorpayload types.KubearmorPayload) error {
+	o.received <- kubearmorpayload
+	return nil
+}
+func (o *replayOutput) Close(ctx context.Context) error { return nil }
+
+func TestReplayEventsToOutputs(t *testing.T) {
+	config = &types.Configuration{Log: true, Sampling: types.SamplingConfig{Rate: 1}}
+	stats = &types.Statistics{Replay: new(expvar.Map).Init()}
+	promStats = &types.PromStatistics{Inputs: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_inputs"}, []string{"source", "status"})}
+	o := &replayOutput{received: make(chan types.KubearmorPayload, 10)}
+	enabledOutputs = []outputs.Output{o}
+	dispatcher = outputs.NewDispatcher(config, stats, promStats)
+	defer func() { config, stats, promStats, enabledOutputs, dispatcher = nil, nil, nil, nil, nil }()
+	outputs.Initvariable(types.BuffersConfig{})
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.Nil(t, os.WriteFile(path, []byte(
+		`{"Timestamp": 1631542902, "HostName": "node1", "PolicyName": "policy1", "Type": "MatchedPolicy"}
+{"Timestamp": 1631542903, "HostName": "node1", "Type": "ContainerLog"}
+{"Timestamp": 1631542904, "HostName": "node1", "PolicyName": "policy2", "Type": "MatchedPolicy"}
+`), 0600))
+
+	// the events are replayed as soon as the outputs are dispatched
+	ctx, cancel := context.WithCancel(context.Background())
+	createReceiveBuffer(ctx)
+	require.Nil(t, replayEvents(context.Background(), types.ReplayConfig{Path: path, Mode: ReplayModeFast}))
+	cancel()
+	dispatcher.Wait()
+
+	require.Equal(t, "3", stats.Replay.Get(outputs.Accepted).String())
+	require.Len(t, o.received, 3)
+}
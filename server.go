@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/kubearmor/sidekick/types"
+)
+
+// newRoutes returns the handlers served by the listener, by path
+func newRoutes() map[string]http.Handler {
+	return map[string]http.Handler{
+		"/events":     http.HandlerFunc(eventsHandler),
+		"/ping":       http.HandlerFunc(pingHandler),
+		"/healthz":    http.HandlerFunc(healthHandler),
+		"/ready":      http.HandlerFunc(readyHandler),
+		"/metrics":    promhttp.Handler(),
+		"/debug/vars": expvar.Handler(),
+	}
+}
+
+// newServers returns the main server, listening on ListenAddress:ListenPort,
+// and when TLS is deployed with NoTLSPaths, the plain HTTP server serving
+// those paths on NoTLSPort (nil otherwise).
+func newServers(config *types.Configuration) (*http.Server, *http.Server) {
+	routes := newRoutes()
+
+	var noTLSServeMux *http.ServeMux
+	if config.TLSServer.Deploy && len(config.TLSServer.NoTLSPaths) != 0 {
+		noTLSServeMux = http.NewServeMux()
+		for _, p := range config.TLSServer.NoTLSPaths {
+			handler, ok := routes[p]
+			if !ok {
+				log.Printf("[WARN]  : TLSServer.NoTLSPaths has unknown path '%v'\n", p)
+				continue
+			}
+			delete(routes, p)
+			if config.Debug {
+				log.Printf("[DEBUG] : %v is served on http\n", p)
+			}
+			noTLSServeMux.Handle(p, handler)
+		}
+	}
+
+	mainServeMux := http.NewServeMux()
+	for p, handler := range routes {
+		mainServeMux.Handle(p, handler)
+	}
+
+	server := newServer(fmt.Sprintf("%s:%d", config.ListenAddress, config.ListenPort), mainServeMux)
+	if config.TLSServer.Deploy && config.TLSServer.MutualTLS {
+		caCert, err := os.ReadFile(config.TLSServer.CaCertFile)
+		if err != nil {
+			log.Fatalf("[ERROR] : TLSServer - %v\n", err.Error())
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			log.Fatalf("[ERROR] : TLSServer - No certificate found in %v\n", config.TLSServer.CaCertFile)
+		}
+		server.TLSConfig = &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  caCertPool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	if noTLSServeMux == nil {
+		return server, nil
+	}
+	return server, newServer(fmt.Sprintf("%s:%d", config.ListenAddress, config.TLSServer.NoTLSPort), noTLSServeMux)
+}
+
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       60 * time.Second,
+		ReadHeaderTimeout: 60 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+}
+
+// startServers serves the HTTP listener in the background, it exits if the
+// listener can't be started. It returns the servers to shut down.
+func startServers(config *types.Configuration) []*http.Server {
+	server, noTLSServer := newServers(config)
+	servers := []*http.Server{server}
+
+	if noTLSServer != nil {
+		servers = append(servers, noTLSServer)
+		go func() {
+			log.Printf("[INFO]  : Sidekick is up and listening on %v for non-TLS paths\n", noTLSServer.Addr)
+			if err := noTLSServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("[ERROR] : %v\n", err.Error())
+			}
+		}()
+	}
+
+	go func() {
+		var err error
+		if config.TLSServer.Deploy {
+			if config.TLSServer.MutualTLS {
+				log.Printf("[INFO]  : Sidekick is up and listening on %v (mTLS)\n", server.Addr)
+			} else {
+				log.Printf("[INFO]  : Sidekick is up and listening on %v (TLS)\n", server.Addr)
+			}
+			err = server.ListenAndServeTLS(config.TLSServer.CertFile, config.TLSServer.KeyFile)
+		} else {
+			log.Printf("[INFO]  : Sidekick is up and listening on %v\n", server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("[ERROR] : %v\n", err.Error())
+		}
+	}()
+	return servers
+}
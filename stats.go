@@ -9,18 +9,35 @@ import (
 	"github.com/kubearmor/sidekick/types"
 )
 
-func getInitStats() *types.Statistics {
+func getInitStats(config *types.Configuration) *types.Statistics {
 	expvar.Publish("goroutines", expvar.Func(func() interface{} {
 		return fmt.Sprintf("%d", runtime.NumGoroutine())
 	}))
 	expvar.Publish("cpu", expvar.Func(func() interface{} {
 		return fmt.Sprintf("%d", runtime.NumCPU())
 	}))
+	expvar.Publish("buffers", expvar.Func(func() interface{} {
+		return outputs.BufferStats()
+	}))
+	expvar.Publish("queues", expvar.Func(func() interface{} {
+		return outputs.QueueStats()
+	}))
+	expvar.Publish("breakers", expvar.Func(func() interface{} {
+		return outputs.BreakerStats()
+	}))
 
 	stats = &types.Statistics{
 		Requests:          getInputNewMap("requests"),
+		Filtered:          expvar.NewMap("filtered"),
+		DeadLetter:        expvar.NewMap("deadletter"),
+		Deduplicated:      expvar.NewMap("deduplicated"),
+		Sampled:           expvar.NewMap("sampled"),
+		RateLimited:       expvar.NewMap("ratelimited"),
+		ShortCircuited:    expvar.NewMap("shortcircuited"),
 		FIFO:              getInputNewMap("fifo"),
 		GRPC:              getInputNewMap("grpc"),
+		Relays:            make(map[string]*expvar.Map),
+		Replay:            getInputNewMap("replay"),
 		Falco:             expvar.NewMap("falco.priority"),
 		Slack:             getOutputNewMap("slack"),
 		Cliq:              getOutputNewMap("cliq"),
@@ -82,6 +99,10 @@ func getInitStats() *types.Statistics {
 		OpenObserve:       getOutputNewMap("openobserve"),
 		Dynatrace:         getOutputNewMap("dynatrace"),
 	}
+	for _, relay := range config.Relays {
+		stats.Relays[relay.Name] = getRelayNewMap(relay.Name)
+	}
+
 	stats.Falco.Add(outputs.Emergency, 0)
 	stats.Falco.Add(outputs.Alert, 0)
 	stats.Falco.Add(outputs.Critical, 0)
@@ -103,6 +124,16 @@ func getInputNewMap(s string) *expvar.Map {
 	return e
 }
 
+func getRelayNewMap(s string) *expvar.Map {
+	e := expvar.NewMap("inputs.relay." + s)
+	e.Add(outputs.Total, 0)
+	e.Add(outputs.Rejected, 0)
+	e.Add(outputs.Accepted, 0)
+	e.Add("connected", 0)
+	e.Add("reconnects", 0)
+	return e
+}
+
 func getOutputNewMap(s string) *expvar.Map {
 	e := expvar.NewMap("outputs." + s)
 	e.Add(outputs.Total, 0)
@@ -8,18 +8,93 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
+	"github.com/kubearmor/sidekick/outputs"
 	"github.com/kubearmor/sidekick/types"
 )
 
 func getInitPromStats(config *types.Configuration) *types.PromStatistics {
 	promStats = &types.PromStatistics{
-		Falco:   getFalcoNewCounterVec(config),
-		Inputs:  getInputNewCounterVec(),
-		Outputs: getOutputNewCounterVec(),
+		Falco:           getFalcoNewCounterVec(config),
+		Inputs:          getInputNewCounterVec(),
+		Outputs:         getOutputNewCounterVec(),
+		RelayReconnects: getRelayReconnectsNewCounterVec(),
+		RelayConnected:  getRelayConnectedNewGauge(),
 	}
+	prometheus.MustRegister(bufferCollector{}, queueCollector{}, breakerCollector{})
 	return promStats
 }
 
+var (
+	bufferDroppedDesc = prometheus.NewDesc("falcosidekick_buffer_dropped", "", []string{"stage", "output"}, nil)
+	bufferDepthDesc   = prometheus.NewDesc("falcosidekick_buffer_depth", "", []string{"stage", "output"}, nil)
+)
+
+// bufferCollector exports the state of the buffers of the pipeline when
+// scraped, the buffers of the outputs come and go with their watchers.
+type bufferCollector struct{}
+
+func (bufferCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bufferDroppedDesc
+	ch <- bufferDepthDesc
+}
+
+func (bufferCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range outputs.BufferStats() {
+		ch <- prometheus.MustNewConstMetric(bufferDroppedDesc, prometheus.CounterValue, float64(s.Dropped), s.Stage, strings.ToLower(s.Output))
+		ch <- prometheus.MustNewConstMetric(bufferDepthDesc, prometheus.GaugeValue, float64(s.Depth), s.Stage, strings.ToLower(s.Output))
+	}
+}
+
+var (
+	queueEventsDesc        = prometheus.NewDesc("falcosidekick_queue_events", "", []string{"output"}, nil)
+	queueBytesDesc         = prometheus.NewDesc("falcosidekick_queue_bytes", "", []string{"output"}, nil)
+	queueOldestAgeDesc     = prometheus.NewDesc("falcosidekick_queue_oldest_age_seconds", "", []string{"output"}, nil)
+	queueReplayPendingDesc = prometheus.NewDesc("falcosidekick_queue_replay_pending", "", []string{"output"}, nil)
+	queueDroppedDesc       = prometheus.NewDesc("falcosidekick_queue_dropped", "", []string{"output"}, nil)
+	queueCorruptedDesc     = prometheus.NewDesc("falcosidekick_queue_corrupted", "", []string{"output"}, nil)
+)
+
+// queueCollector exports the state of the queues on disk of the outputs when
+// scraped.
+type queueCollector struct{}
+
+func (queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueEventsDesc
+	ch <- queueBytesDesc
+	ch <- queueOldestAgeDesc
+	ch <- queueReplayPendingDesc
+	ch <- queueDroppedDesc
+	ch <- queueCorruptedDesc
+}
+
+func (queueCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range outputs.QueueStats() {
+		output := strings.ToLower(s.Output)
+		ch <- prometheus.MustNewConstMetric(queueEventsDesc, prometheus.GaugeValue, float64(s.Events), output)
+		ch <- prometheus.MustNewConstMetric(queueBytesDesc, prometheus.GaugeValue, float64(s.Bytes), output)
+		ch <- prometheus.MustNewConstMetric(queueOldestAgeDesc, prometheus.GaugeValue, s.OldestAge, output)
+		ch <- prometheus.MustNewConstMetric(queueReplayPendingDesc, prometheus.GaugeValue, float64(s.ReplayPending), output)
+		ch <- prometheus.MustNewConstMetric(queueDroppedDesc, prometheus.CounterValue, float64(s.Dropped), output)
+		ch <- prometheus.MustNewConstMetric(queueCorruptedDesc, prometheus.CounterValue, float64(s.Corrupted), output)
+	}
+}
+
+var breakerStateDesc = prometheus.NewDesc("falcosidekick_circuit_breaker_state", "", []string{"output"}, nil)
+
+// breakerCollector exports the state of the circuit breakers of the outputs
+// when scraped, 0 closed, 1 half-open and 2 open.
+type breakerCollector struct{}
+
+func (breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerStateDesc
+}
+
+func (breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range outputs.BreakerStats() {
+		ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, float64(s.State), strings.ToLower(s.Output))
+	}
+}
+
 func getInputNewCounterVec() *prometheus.CounterVec {
 	return promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -34,7 +109,25 @@ func getOutputNewCounterVec() *prometheus.CounterVec {
 		prometheus.CounterOpts{
 			Name: "falcosidekick_outputs",
 		},
-		[]string{"destination", "status"},
+		[]string{"destination", "instance", "status"},
+	)
+}
+
+func getRelayReconnectsNewCounterVec() *prometheus.CounterVec {
+	return promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "falcosidekick_relay_reconnects",
+		},
+		[]string{"relay", "status"},
+	)
+}
+
+func getRelayConnectedNewGauge() *prometheus.GaugeVec {
+	return promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "falcosidekick_relay_connected",
+		},
+		[]string{"relay"},
 	)
 }
 
@@ -2,6 +2,7 @@ package types
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 )
 
@@ -69,6 +70,22 @@ func Priority(p string) PriorityType {
 	}
 }
 
+// GetPriority returns the priority of a KubeArmor event. Alerts are mapped
+// from their Severity, logs get the configured LogPriority.
+func (m SeverityMappingConfig) GetPriority(kubearmorpayload KubearmorPayload) PriorityType {
+	if kubearmorpayload.EventType != "Alert" {
+		return Priority(m.LogPriority)
+	}
+	severity := strings.ToLower(strings.TrimSpace(fmt.Sprint(kubearmorpayload.OutputFields["Severity"])))
+	if p, ok := m.SeveritiesMap[severity]; ok {
+		return p
+	}
+	if p := Priority(severity); p != Default {
+		return p
+	}
+	return Priority(m.DefaultPriority)
+}
+
 func (p *PriorityType) UnmarshalJSON(b []byte) error {
 	var s string
 	if err := json.Unmarshal(b, &s); err != nil {
@@ -37,12 +37,30 @@ type Configuration struct {
 	MutualTLSFilesPath string
 	MutualTLSClient    MutualTLSClient
 	TLSServer          TLSServer
+	Relay              RelayConfig
+	Relays             []RelayConfig
+	Replay             ReplayConfig
+	Buffers            BuffersConfig
+	Retry              RetryConfig
+	Queue              QueueConfig
+	DeadLetter         DeadLetterConfig
+	CircuitBreaker     BreakerConfig
+	HTTPClient         HTTPClientConfig
+	Workers            WorkersConfig
+	Batch              BatchConfig
+	Rules              RulesConfig
+	Dedup              DedupConfig
+	RateLimit          RateLimitConfig
+	Sampling           SamplingConfig
+	Instances          []InstanceConfig
 	Debug              bool
+	ShutdownTimeout    int
 	ListenAddress      string
 	ListenPort         int
 	BracketReplacer    string
 	Customfields       map[string]string
 	Templatedfields    map[string]string
+	SeverityMapping    SeverityMappingConfig
 	Prometheus         prometheusOutputConfig
 	Slack              SlackOutputConfig
 	Cliq               CliqOutputConfig
@@ -113,6 +131,260 @@ type TLSServer struct {
 	NoTLSPaths []string
 }
 
+// RelayConfig represents parameters for the connection to the KubeArmor relay
+// Name: name of the relay in the metrics, also the cluster name of the events
+// which have none.
+// ClusterName: if not empty, overrides the cluster name of the events of the relay.
+// Address: host or IP of the relay, if empty the relay is reached through Service,
+// or discovered from the relay pods when Service is empty too.
+// TLS: if true, the connection is secured with TLS, and with mutual TLS when
+// CertFile and KeyFile are set.
+// AlertFilter, LogFilter: filter of the alert and log streams sent to the relay,
+// "all", "policy" or "system", "none" disables the stream.
+type RelayConfig struct {
+	Name        string
+	ClusterName string
+	Address     string
+	Service     string
+	Namespace   string
+	Port        int
+	Kubeconfig  string
+	TLS         bool
+	CaCertFile  string
+	CertFile    string
+	KeyFile     string
+	ServerName  string
+	CheckCert   bool
+	AlertFilter string
+	LogFilter   string
+}
+
+// ReplayConfig represents parameters for replaying recorded events
+// Path: a JSONL file, a directory of JSONL files or "-" for stdin, if not empty
+// the events are replayed instead of streamed from the relays.
+// Mode: "original" keeps the timing of the events, "fixed" sends Rate events
+// per second, "fast" sends them as fast as the outputs accept them.
+type ReplayConfig struct {
+	Path string
+	Mode string
+	Rate float64
+}
+
+// BuffersConfig represents the buffers between the stages of the pipeline
+// Alerts, Logs: the buffers of the events received from the relays.
+// Outputs: the queue of every output, one for alerts and one for logs.
+type BuffersConfig struct {
+	Alerts  BufferConfig
+	Logs    BufferConfig
+	Outputs BufferConfig
+}
+
+// BufferConfig represents parameters for a buffer
+// Size: the number of events held by the buffer.
+// Overflow: what to do when the buffer is full, "block" waits for room,
+// "drop-newest" drops the incoming event and "drop-oldest" the oldest one.
+type BufferConfig struct {
+	Size     int
+	Overflow string
+}
+
+// RetryConfig represents the retry policy of the HTTP outputs
+// MaxAttempts: the number of attempts to send an event, 1 disables the retries.
+// BaseBackoff, MaxBackoff: the delay before the first retry and the cap of the
+// exponentially growing delays, in milliseconds. A Retry-After header replaces
+// the delay, within MaxBackoff.
+// Jitter: the randomized fraction of the delays, between 0 and 1.
+// RetryableCodes: comma separated list of the HTTP status codes to retry.
+// NetworkErrors: if true, the requests failing without a response are retried.
+// Outputs: the policies of the outputs by lowercase name, inheriting the
+// settings they don't set.
+type RetryConfig struct {
+	MaxAttempts        int
+	BaseBackoff        int
+	MaxBackoff         int
+	Jitter             float64
+	RetryableCodes     string
+	RetryableCodesList []int
+	NetworkErrors      bool
+	Outputs            map[string]RetryConfig
+}
+
+// QueueConfig represents the write-ahead queue on disk of the outputs
+// Enabled: if true, the events are written to the queue before being sent and
+// removed once sent, the events left are sent after a restart.
+// Directory: the directory of the queues, one subdirectory per output.
+// SegmentSize, MaxSize: the size of the segment files and the cap of the
+// events not acknowledged of a queue, in MB. The events are dropped when the
+// queue is full.
+// Fsync: when the writes are synced to disk, "always", "interval" or "never".
+// FsyncInterval: the delay between two syncs with "interval", in milliseconds.
+// Outputs: the settings of the outputs by lowercase name, inheriting the
+// settings they don't set.
+type QueueConfig struct {
+	Enabled       bool
+	Directory     string
+	SegmentSize   int
+	MaxSize       int
+	Fsync         string
+	FsyncInterval int
+	Outputs       map[string]QueueConfig
+}
+
+// DeadLetterConfig represents where the events the outputs could not deliver
+// are written, once their retries are exhausted
+// Target: "file" to write them to File, or the name of an output to send them
+// to, empty disables it.
+// File: the path of the dead-letter file, as JSON lines.
+// MaxSize: the size in MB at which the file is rotated.
+// MaxBackups: the number of rotated files kept.
+type DeadLetterConfig struct {
+	Target     string
+	File       string
+	MaxSize    int
+	MaxBackups int
+}
+
+// HTTPClientConfig represents the HTTP client of the outputs, kept to reuse
+// its connections
+// Timeout: the timeout of a request, response body included, in milliseconds.
+// DialTimeout, TLSHandshakeTimeout: the timeouts of the connection and of the
+// TLS handshake, in milliseconds.
+// IdleConnTimeout: how long an idle connection is kept, in milliseconds.
+// MaxIdleConnsPerHost: the number of idle connections kept per host.
+// Proxy: the URL of the HTTP(S) proxy, if empty the HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY env vars are used.
+// Outputs: the settings of the outputs by lowercase name, inheriting the
+// settings they don't set.
+type HTTPClientConfig struct {
+	Timeout             int
+	DialTimeout         int
+	TLSHandshakeTimeout int
+	IdleConnTimeout     int
+	MaxIdleConnsPerHost int
+	Proxy               string
+	Outputs             map[string]HTTPClientConfig
+}
+
+// BreakerConfig represents the circuit breaker of the outputs
+// Failures: the number of consecutive failures opening the breaker, the events
+// are then short-circuited to the queue or the dead letters. 0 disables it.
+// OpenTimeout: the delay before probing the destination with an event, in
+// milliseconds.
+// Outputs: the settings of the outputs by lowercase name, inheriting the
+// settings they don't set.
+type BreakerConfig struct {
+	Failures    int
+	OpenTimeout int
+	Outputs     map[string]BreakerConfig
+}
+
+// WorkersConfig represents the workers sending the events of the outputs
+// Workers: the number of goroutines sending the events of an output, 1 sends
+// them from the goroutine receiving them.
+// MaxInFlight: the number of events the workers send at once, 0 for Workers.
+// OrderingKey: "pod", "namespace" or "host" to send the events with the same
+// key in order, always from the same worker, empty for no ordering.
+// Outputs: the settings of the outputs by lowercase name, inheriting the
+// settings they don't set.
+type WorkersConfig struct {
+	Workers     int
+	MaxInFlight int
+	OrderingKey string
+	Outputs     map[string]WorkersConfig
+}
+
+// BatchConfig represents the batches of the outputs with a bulk API
+// MaxSize: the number of events of a batch, 1 sends the events one at a time.
+// MaxBytes: the size of the events of a batch as JSON, in bytes, 0 for no cap.
+// Linger: how long a batch waits for more events before being sent, in
+// milliseconds.
+// Outputs: the settings of the outputs by lowercase name, inheriting the
+// settings they don't set.
+type BatchConfig struct {
+	MaxSize  int
+	MaxBytes int
+	Linger   int
+	Outputs  map[string]BatchConfig
+}
+
+// RulesConfig represents the rules choosing the events sent to the outputs,
+// CEL expressions over the fields of the events
+// Include: the events must match it, empty to include every event.
+// Exclude: the events must not match it, empty to exclude none.
+// Outputs: the rules of the outputs by lowercase name, inheriting the rules
+// they don't set.
+type RulesConfig struct {
+	Include string
+	Exclude string
+	Outputs map[string]RulesConfig
+}
+
+// DedupConfig represents the deduplication of the events of the outputs
+// Window: how long the repeats of an event are suppressed after it is sent, in
+// seconds, 0 disables the deduplication.
+// Fields: comma separated list of the fields identifying the repeats of an
+// event.
+// Outputs: the settings of the outputs by lowercase name, inheriting the
+// settings they don't set.
+type DedupConfig struct {
+	Window     int
+	Fields     string
+	FieldsList []string
+	Outputs    map[string]DedupConfig
+}
+
+// RateLimitConfig represents the rate limiting of the events of the outputs
+// Rate: the events sent per second, 0 disables the rate limiting.
+// Burst: the events sent at once above the rate.
+// Overflow: what happens to the events over the limit, queue waits for the
+// rate to allow them, drop drops them, summary drops them and sends the number
+// of events dropped every SummaryInterval.
+// SummaryInterval: the interval of the summaries, in seconds.
+// Outputs: the settings of the outputs by lowercase name, inheriting the
+// settings they don't set.
+type RateLimitConfig struct {
+	Rate            float64
+	Burst           int
+	Overflow        string
+	SummaryInterval int
+	Outputs         map[string]RateLimitConfig
+}
+
+// SamplingConfig represents the sampling of the logs sent to the outputs
+// Rate: the probability a log is sent, 1 sends every log.
+// Every: one log out of Every is sent, 0 or 1 sends every log, it can't be
+// set with Rate.
+// Outputs: the settings of the outputs by lowercase name, inheriting the
+// settings they don't set.
+type SamplingConfig struct {
+	Rate    float64
+	Every   int
+	Outputs map[string]SamplingConfig
+}
+
+// InstanceConfig represents a named instance of the outputs
+// Name: the name of the instance, the outputs it enables are named after it,
+// e.g. Slack:team-a
+// Blocks: the blocks of the configuration set by the instance, e.g. slack
+// Config: the configuration of the instance, the settings it doesn't set are
+// inherited from the configuration
+type InstanceConfig struct {
+	Name   string
+	Blocks []string
+	Config *Configuration
+}
+
+// SeverityMappingConfig represents the mapping of KubeArmor severities onto priorities
+// Severities: comma separated list of "severity:priority" pairs, e.g. "1:debug, 10:emergency".
+// LogPriority: the priority given to logs, which carry no severity.
+// DefaultPriority: the priority given to alerts with a severity missing from the mapping.
+type SeverityMappingConfig struct {
+	Severities      string
+	SeveritiesMap   map[string]PriorityType
+	LogPriority     string
+	DefaultPriority string
+}
+
 // SlackOutputConfig represents parameters for Slack
 type SlackOutputConfig struct {
 	WebhookURL            string
@@ -192,6 +464,7 @@ type teamsOutputConfig struct {
 type datadogOutputConfig struct {
 	APIKey          string
 	Host            string
+	LogsHost        string
 	MinimumPriority string
 	CheckCert       bool
 	MutualTLS       bool
@@ -224,6 +497,7 @@ type AlertmanagerOutputConfig struct {
 	DropEventThresholds      string
 	DropEventThresholdsList  []ThresholdConfig
 	DropEventDefaultPriority string
+	DropEventWindow          int
 }
 
 type ElasticsearchOutputConfig struct {
@@ -719,8 +993,16 @@ type OpenObserveConfig struct {
 // Statistics is a struct to store stastics
 type Statistics struct {
 	Requests          *expvar.Map
+	Filtered          *expvar.Map
+	DeadLetter        *expvar.Map
+	Deduplicated      *expvar.Map
+	Sampled           *expvar.Map
+	RateLimited       *expvar.Map
+	ShortCircuited    *expvar.Map
 	FIFO              *expvar.Map
 	GRPC              *expvar.Map
+	Relays            map[string]*expvar.Map
+	Replay            *expvar.Map
 	Falco             *expvar.Map
 	Slack             *expvar.Map
 	Mattermost        *expvar.Map
@@ -785,7 +1067,9 @@ type Statistics struct {
 
 // PromStatistics is a struct to store prometheus metrics
 type PromStatistics struct {
-	Falco   *prometheus.CounterVec
-	Inputs  *prometheus.CounterVec
-	Outputs *prometheus.CounterVec
+	Falco           *prometheus.CounterVec
+	Inputs          *prometheus.CounterVec
+	Outputs         *prometheus.CounterVec
+	RelayReconnects *prometheus.CounterVec
+	RelayConnected  *prometheus.GaugeVec
 }